@@ -0,0 +1,260 @@
+package stride
+
+import (
+	"iter"
+	"math"
+	"time"
+)
+
+// HeartRateAccumulator is the streaming counterpart of CalculateAverageHeartRate and
+// CalculateMaxHeartRate. Entries are added in offset order and memory stays constant,
+// whatever the length of the activity. Pauses are not known to a stream: feed it
+// ts.ExcludePauses().Entries() to match the in-memory analyses on paused timeseries.
+type HeartRateAccumulator struct {
+	avgConfig AvgHeartRateAnalysisConfig
+	maxConfig MaxHeartRateAnalysisConfig
+
+	entries int
+	prev    ActivityTimeseriesEntry
+
+	simpleSum, simpleCount int
+	weightedSum            float64
+	weightedDuration       float64
+
+	maxHR     int
+	histogram [256]int // valid readings per bpm, for percentiles
+}
+
+func NewHeartRateAccumulator(avgConfig AvgHeartRateAnalysisConfig, maxConfig MaxHeartRateAnalysisConfig) *HeartRateAccumulator {
+	return &HeartRateAccumulator{
+		avgConfig: avgConfig,
+		maxConfig: maxConfig,
+	}
+}
+
+func (a *HeartRateAccumulator) Add(entry ActivityTimeseriesEntry) {
+	// A sample is weighted by the time until the next one, so the previous entry is
+	// only complete now
+	if a.entries > 0 && a.prev.HeartRate.Valid {
+		hr := int(a.prev.HeartRate.Value)
+		duration := entry.Offset - a.prev.Offset
+		if isValidHeartRate(hr, a.avgConfig) && duration > 0 {
+			weight := 1.0
+			if a.avgConfig.Method == HeartRateMethodZoneWeighted {
+				weight = getHeartRateZoneWeight(hr, a.avgConfig.MaxHeartRate)
+			}
+			a.weightedSum += float64(hr) * float64(duration) * weight
+			a.weightedDuration += float64(duration) * weight
+		}
+	}
+
+	if entry.HeartRate.Valid {
+		hr := int(entry.HeartRate.Value)
+
+		if isValidHeartRate(hr, a.avgConfig) {
+			a.simpleSum += hr
+			a.simpleCount++
+		}
+
+		if isValidMaxHeartRate(hr, a.maxConfig) {
+			a.histogram[hr]++
+			a.maxHR = max(a.maxHR, hr)
+		}
+	}
+
+	a.prev = entry
+	a.entries++
+}
+
+// AverageHeartRate returns the average with the configured method.
+func (a *HeartRateAccumulator) AverageHeartRate() (float64, error) {
+	if a.entries == 0 {
+		return 0, ErrEmptyTimeseriesData
+	}
+
+	if a.avgConfig.Method == HeartRateMethodSimple || a.entries < 2 {
+		if a.simpleCount == 0 {
+			return 0, ErrNoValidData
+		}
+		return float64(a.simpleSum) / float64(a.simpleCount), nil
+	}
+
+	if a.weightedDuration == 0 {
+		return 0, ErrNoValidData
+	}
+
+	return a.weightedSum / a.weightedDuration, nil
+}
+
+// MaxHeartRate returns the maximum with the configured method. The rolling window
+// maximum of CalculateMaxHeartRate always equals the peak, so both use the same value.
+func (a *HeartRateAccumulator) MaxHeartRate() (int, error) {
+	if a.entries == 0 {
+		return 0, ErrEmptyTimeseriesData
+	}
+
+	var count int
+	for _, n := range a.histogram {
+		count += n
+	}
+	if count == 0 {
+		return 0, ErrNoValidData
+	}
+
+	if a.maxConfig.Method != MaxHeartRateMethodPercentile {
+		return a.maxHR, nil
+	}
+
+	percentile := a.maxConfig.PercentileValue
+	if percentile <= 0 || percentile > 100 {
+		percentile = 95.0
+	}
+
+	index := float64(count-1) * (percentile / 100.0)
+	lowerIndex := int(index)
+	upperIndex := lowerIndex + 1
+
+	if upperIndex >= count {
+		return a.maxHR, nil
+	}
+
+	weight := index - float64(lowerIndex)
+	result := float64(a.nthHeartRate(lowerIndex))*(1-weight) + float64(a.nthHeartRate(upperIndex))*weight
+
+	return int(result + 0.5), nil
+}
+
+// nthHeartRate returns the n-th smallest valid reading.
+func (a *HeartRateAccumulator) nthHeartRate(n int) int {
+	for hr, count := range a.histogram {
+		if n < count {
+			return hr
+		}
+		n -= count
+	}
+	return a.maxHR
+}
+
+// SummaryAccumulator is the streaming counterpart of RecomputeSummary, for activities
+// too large to hold in memory. Entries must be added in offset order.
+type SummaryAccumulator struct {
+	config AugmentConfig
+	hr     *HeartRateAccumulator
+
+	entries     int
+	prev        ActivityTimeseriesEntry
+	firstOffset int
+	maxOffset   int
+
+	gpsDist, maxDist float64
+	hasDistance      bool
+	movingSeconds    uint32
+
+	elevation    elevationTracker
+	hasHeartRate bool
+}
+
+func NewSummaryAccumulator(config AugmentConfig) *SummaryAccumulator {
+	acc := &SummaryAccumulator{
+		config: config.ApplyDefaults(),
+		hr:     NewHeartRateAccumulator(hrMetricsAvgConfig, hrMetricsMaxConfig),
+	}
+	acc.elevation.hysteresisM = acc.config.ElevationHysteresisM
+	return acc
+}
+
+func (a *SummaryAccumulator) Add(entry ActivityTimeseriesEntry) {
+	a.hr.Add(entry)
+	if entry.HeartRate.Valid {
+		a.hasHeartRate = true
+	}
+
+	if a.entries == 0 {
+		a.firstOffset = entry.Offset
+	}
+	a.maxOffset = max(a.maxOffset, entry.Offset)
+
+	if entry.Distance.Valid {
+		a.hasDistance = true
+		a.maxDist = math.Max(a.maxDist, float64(entry.Distance.Value))
+	}
+
+	if a.entries > 0 {
+		if entry.HasGPS() && a.prev.HasGPS() {
+			a.gpsDist += haversine(a.prev.Latitude.Value, a.prev.Longitude.Value, entry.Latitude.Value, entry.Longitude.Value)
+		}
+
+		timeDelta := float64(entry.Offset - a.prev.Offset)
+		speed, ok := intervalSpeed(a.prev, entry)
+		if !ok {
+			speed = -1
+		}
+
+		if timeDelta > 0 && timeDelta <= DefaultMaxGap.Seconds() && isMoving(entry, speed, DefaultMinMovingSpeedMS) {
+			a.movingSeconds += uint32(timeDelta)
+		}
+	}
+
+	if entry.Altitude.Valid {
+		a.elevation.add(entry.Altitude.Value)
+	}
+
+	a.prev = entry
+	a.entries++
+}
+
+// Summarize fills the same summary fields as RecomputeSummary. StartTime is left
+// to the caller, as entries only carry offsets.
+func (a *SummaryAccumulator) Summarize(act *Activity) {
+	act.ElapsedTime = 0
+	act.MovingTime = 0
+	act.Distance = 0
+	act.AvgSpeed = 0
+	act.AvgHR = Optional[uint8]{}
+	act.MaxHR = Optional[uint8]{}
+	act.ElevationGain = Optional[uint16]{}
+	act.ElevationLoss = Optional[uint16]{}
+
+	if a.entries == 0 {
+		return
+	}
+
+	act.ElapsedTime = uint32(a.maxOffset - a.firstOffset)
+
+	totalDist := a.gpsDist
+	if a.hasDistance {
+		totalDist = a.maxDist
+	}
+
+	act.Distance = DistanceFromMeters(totalDist)
+	act.MovingTime = a.movingSeconds
+	if a.movingSeconds > 0 {
+		act.AvgSpeed = SpeedFromMetersPerSecond(totalDist / float64(a.movingSeconds))
+	}
+
+	if a.elevation.initialized {
+		act.ElevationGain = Optional[uint16]{Value: uint16(a.elevation.gain), Valid: true}
+		act.ElevationLoss = Optional[uint16]{Value: uint16(a.elevation.loss), Valid: true}
+	}
+
+	if a.hasHeartRate {
+		if avgHR, err := a.hr.AverageHeartRate(); err == nil && avgHR > 0 {
+			act.AvgHR = Optional[uint8]{Value: uint8(math.Round(avgHR)), Valid: true}
+		}
+		if maxHR, err := a.hr.MaxHeartRate(); err == nil && maxHR > 0 {
+			act.MaxHR = Optional[uint8]{Value: uint8(maxHR), Valid: true}
+		}
+	}
+}
+
+// SummarizeStream computes the activity summary from a stream of entries in a single
+// pass.
+func SummarizeStream(act *Activity, startTime time.Time, entries iter.Seq[ActivityTimeseriesEntry], config AugmentConfig) {
+	acc := NewSummaryAccumulator(config)
+	for entry := range entries {
+		acc.Add(entry)
+	}
+
+	act.StartTime = startTime
+	acc.Summarize(act)
+}
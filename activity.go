@@ -3,6 +3,7 @@ package stride
 import (
 	"errors"
 	"math"
+	"sort"
 	"time"
 )
 
@@ -13,15 +14,28 @@ type ActivityConvertible interface {
 type Activity struct {
 	Provider      Provider
 	Sport         Sport
-	StartTime     time.Time        // UTC
-	ElapsedTime   uint32           // seconds
-	MovingTime    uint32           // seconds
-	Distance      uint32           // meters
-	AvgSpeed      uint16           // m / s
+	StartTime     time.Time // UTC
+	ElapsedTime   uint32    // seconds
+	MovingTime    uint32    // seconds
+	Distance      Distance
+	AvgSpeed      Speed
 	AvgHR         Optional[uint8]  // beats / minute
 	MaxHR         Optional[uint8]  // beats / minute
 	ElevationGain Optional[uint16] // meters
 	ElevationLoss Optional[uint16] // meters
+	Laps          []Lap
+	Sessions      []Session // Empty for single-sport activities without session data
+	Device        Optional[Device]
+}
+
+// Device describes the device that recorded an activity. Names follow the FIT profile,
+// e.g. manufacturer "garmin" and product "enduro3".
+type Device struct {
+	Manufacturer    string
+	Product         string
+	ProductID       uint16 // Manufacturer specific product number, zero when unknown
+	SerialNumber    uint32 // Zero when unknown
+	SoftwareVersion string // e.g. "12.34", empty when unknown
 }
 
 type ActivityTimeseriesConvertible interface {
@@ -31,6 +45,13 @@ type ActivityTimeseriesConvertible interface {
 type ActivityTimeseries struct {
 	StartTime time.Time
 	Data      []ActivityTimeseriesEntry
+
+	// Pauses are the stopped intervals sorted by offset. Parsers fill them from FIT timer
+	// events, GPX segments and the Strava moving stream; other timeseries, e.g. from CSV
+	// or a GPX track without segments, have none until DetectPauses is assigned.
+	Pauses []Pause
+
+	ExtraChannels []ExtraChannel // Channels whose values are in the Extra of entries
 }
 
 func (ts ActivityTimeseries) EndTime() time.Time {
@@ -58,14 +79,27 @@ type hrMetrics struct {
 	MaxHR int16
 }
 
-func (ts *ActivityTimeseries) HRMetrics() (*hrMetrics, error) {
-	avgHR, err := CalculateAverageHeartRate(ts, AvgHeartRateAnalysisConfig{
+// hrMetricsAvgConfig and hrMetricsMaxConfig are the settings of the activity summary
+// heart rate, shared by HRMetrics and SummaryAccumulator.
+var (
+	hrMetricsMaxWindow = 30 * time.Second
+
+	hrMetricsAvgConfig = AvgHeartRateAnalysisConfig{
 		Method:       HeartRateMethodTimeWeighted,
 		ExcludeZeros: true,
 		MinValidRate: 40,
 		MaxValidRate: 220,
 		MaxHeartRate: 193,
-	})
+	}
+
+	hrMetricsMaxConfig = MaxHeartRateAnalysisConfig{
+		Method:         MaxHeartRateMethodRollingWindow,
+		WindowDuration: &hrMetricsMaxWindow,
+	}
+)
+
+func (ts *ActivityTimeseries) HRMetrics() (*hrMetrics, error) {
+	avgHR, err := CalculateAverageHeartRate(ts, hrMetricsAvgConfig)
 	if err != nil {
 		if !errors.Is(err, ErrNoValidData) {
 			return nil, err
@@ -77,12 +111,7 @@ func (ts *ActivityTimeseries) HRMetrics() (*hrMetrics, error) {
 		avgValue = int16(math.Round(avgHR))
 	}
 
-	thirtySec := 30 * time.Second
-
-	maxHR, err := CalculateMaxHeartRate(ts, MaxHeartRateAnalysisConfig{
-		Method:         MaxHeartRateMethodRollingWindow,
-		WindowDuration: &thirtySec,
-	})
+	maxHR, err := CalculateMaxHeartRate(ts, hrMetricsMaxConfig)
 	if err != nil {
 		if !errors.Is(err, ErrNoValidData) {
 			return nil, err
@@ -100,15 +129,52 @@ func (ts *ActivityTimeseries) HRMetrics() (*hrMetrics, error) {
 	}, nil
 }
 
+type powerMetrics struct {
+	AvgPower         int16
+	MaxPower         int16
+	NormalizedPower  int16
+	IntensityFactor  float64
+	TSS              float64
+	VariabilityIndex float64
+	WorkKJ           float64
+}
+
+// PowerMetrics computes the power summary of the activity. IF and TSS are only
+// filled when ftp is greater than zero.
+func (ts *ActivityTimeseries) PowerMetrics(ftp int) (*powerMetrics, error) {
+	result, err := AnalyzePower(ts, PowerAnalysisConfig{FTP: ftp})
+	if err != nil {
+		if errors.Is(err, ErrNoValidPowerData) {
+			return &powerMetrics{}, nil
+		}
+		return nil, err
+	}
+
+	return &powerMetrics{
+		AvgPower:         int16(math.Round(result.AvgPower)),
+		MaxPower:         int16(result.MaxPower),
+		NormalizedPower:  int16(math.Round(result.NormalizedPower)),
+		IntensityFactor:  result.IntensityFactor,
+		TSS:              result.TSS,
+		VariabilityIndex: result.VariabilityIndex,
+		WorkKJ:           result.WorkKJ,
+	}, nil
+}
+
 type ActivityTimeseriesEntry struct {
-	Offset    int
-	HeartRate Optional[uint8]
-	Cadence   Optional[uint8]
-	Distance  Optional[uint32]
-	Altitude  Optional[float64]
-	Velocity  Optional[uint16]
-	Latitude  Optional[float64]
-	Longitude Optional[float64]
+	Offset      int
+	HeartRate   Optional[uint8]
+	Cadence     Optional[uint8]
+	Distance    Optional[Distance]
+	Altitude    Optional[float64]
+	Velocity    Optional[Speed]
+	Latitude    Optional[float64]
+	Longitude   Optional[float64]
+	Power       Optional[uint16]   // watts
+	Temperature Optional[int8]     // degrees Celsius
+	Moving      Optional[bool]     // provider-reported moving flag
+	Grade       Optional[float64]  // percent
+	Extra       map[string]float64 // values of ActivityTimeseries.ExtraChannels by name
 }
 
 func (a ActivityTimeseriesEntry) IsEmpty() bool {
@@ -118,13 +184,32 @@ func (a ActivityTimeseriesEntry) IsEmpty() bool {
 		!a.Altitude.Valid &&
 		!a.Velocity.Valid &&
 		!a.Latitude.Valid &&
-		!a.Longitude.Valid
+		!a.Longitude.Valid &&
+		!a.Power.Valid &&
+		!a.Temperature.Valid &&
+		!a.Grade.Valid &&
+		len(a.Extra) == 0
 }
 
 func (a ActivityTimeseriesEntry) HasGPS() bool {
 	return a.Latitude.Valid && a.Longitude.Valid
 }
 
+// isMoving prefers the provider's moving flag and falls back to a speed threshold
+// when the entry does not carry one.
+func isMoving(entry ActivityTimeseriesEntry, speedMS, minMovingSpeedMS float64) bool {
+	if entry.Moving.Valid {
+		return entry.Moving.Value
+	}
+	return speedMS > minMovingSpeedMS
+}
+
+// movingInterval reports whether the athlete was moving between two samples. Intervals
+// overlapping a pause are never moving, otherwise the moving flag or speed decides.
+func (ts *ActivityTimeseries) movingInterval(prev, curr ActivityTimeseriesEntry, speedMS, minMovingSpeedMS float64) bool {
+	return !ts.pausedBetween(prev.Offset, curr.Offset) && isMoving(curr, speedMS, minMovingSpeedMS)
+}
+
 // WeightedAvg correctly computes time-weighted averages and standard deviation for uneven timeseries data.
 type WeightedAvg struct {
 	Sum    float64
@@ -167,6 +252,7 @@ type EnrichedPoint struct {
 	GradePct    float64
 	DistanceM   float64
 	DeltaElevM  float64
+	Moving      bool
 }
 
 type AugmentConfig struct {
@@ -186,65 +272,179 @@ func AugmentGPXData(act *Activity, ts *ActivityTimeseries, config AugmentConfig)
 	config = config.ApplyDefaults()
 
 	var totalDist float64
-	var totalGain float64
-	var totalLoss float64
 	var movingSeconds uint32
 
 	if len(ts.Data) == 0 {
 		return
 	}
-	ts.Data[0].Distance = Optional[uint32]{Value: 0, Valid: true}
-
-	var lastRecordedElev float64
-	var elevInitialized bool
-
-	for _, entry := range ts.Data {
-		if entry.Altitude.Valid {
-			lastRecordedElev = entry.Altitude.Value
-			elevInitialized = true
-			break
-		}
-	}
+	ts.Data[0].Distance = Optional[Distance]{Value: 0, Valid: true}
 
 	for i := 1; i < len(ts.Data); i++ {
 		prev := ts.Data[i-1]
 		curr := &ts.Data[i]
 
+		timeDelta := float64(curr.Offset - prev.Offset)
+		speed := -1.0 // Unknown without GPS, only the moving flag can count this interval
+
 		if curr.HasGPS() && prev.HasGPS() {
 			d := haversine(prev.Latitude.Value, prev.Longitude.Value, curr.Latitude.Value, curr.Longitude.Value)
 			totalDist += d
-			timeDelta := float64(curr.Offset - prev.Offset)
 			if timeDelta > 0 {
-				speed := d / timeDelta
-				curr.Velocity = Optional[uint16]{Value: uint16(speed * 1000), Valid: true}
-				if speed > 0.5 { // Moving threshold
-					movingSeconds += uint32(timeDelta)
-				}
+				speed = d / timeDelta
+				curr.Velocity = Optional[Speed]{Value: SpeedFromMetersPerSecond(speed), Valid: true}
 			}
 		}
-		curr.Distance = Optional[uint32]{Value: uint32(totalDist), Valid: true}
-
-		if curr.Altitude.Valid && elevInitialized {
-			deltaZ := curr.Altitude.Value - lastRecordedElev
-			if deltaZ >= config.ElevationHysteresisM {
-				totalGain += deltaZ
-				lastRecordedElev = curr.Altitude.Value
-			} else if deltaZ <= -config.ElevationHysteresisM {
-				totalLoss -= deltaZ // deltaZ is negative, so subtract to add positive loss
-				lastRecordedElev = curr.Altitude.Value
-			}
+
+		if timeDelta > 0 && ts.movingInterval(prev, *curr, speed, DefaultMinMovingSpeedMS) {
+			movingSeconds += uint32(timeDelta)
 		}
+
+		curr.Distance = Optional[Distance]{Value: DistanceFromMeters(totalDist), Valid: true}
 	}
 
-	act.Distance = uint32(totalDist)
+	totalGain, totalLoss := elevationGainLoss(ts.Data, config.ElevationHysteresisM)
+
+	act.Distance = DistanceFromMeters(totalDist)
 	act.MovingTime = movingSeconds
 	act.ElevationGain = Optional[uint16]{Value: uint16(totalGain), Valid: true}
 	act.ElevationLoss = Optional[uint16]{Value: uint16(totalLoss), Valid: true}
 	if movingSeconds > 0 {
-		act.AvgSpeed = uint16((totalDist / float64(movingSeconds)) * 1000)
+		act.AvgSpeed = SpeedFromMetersPerSecond(totalDist / float64(movingSeconds))
+	}
+}
+
+// elevationGainLoss accumulates climbing and descending, ignoring changes smaller than
+// the hysteresis threshold so GPS/barometer noise does not inflate the totals.
+func elevationGainLoss(data []ActivityTimeseriesEntry, hysteresisM float64) (float64, float64) {
+	tracker := elevationTracker{hysteresisM: hysteresisM}
+	for _, entry := range data {
+		if entry.Altitude.Valid {
+			tracker.add(entry.Altitude.Value)
+		}
+	}
+	return tracker.gain, tracker.loss
+}
+
+// elevationTracker applies the elevation hysteresis one altitude sample at a time.
+type elevationTracker struct {
+	hysteresisM  float64
+	lastRecorded float64
+	initialized  bool
+	gain, loss   float64
+}
+
+func (e *elevationTracker) add(altitude float64) {
+	if !e.initialized {
+		e.lastRecorded = altitude
+		e.initialized = true
+		return
+	}
+
+	deltaZ := altitude - e.lastRecorded
+	if deltaZ >= e.hysteresisM {
+		e.gain += deltaZ
+		e.lastRecorded = altitude
+	} else if deltaZ <= -e.hysteresisM {
+		e.loss -= deltaZ // deltaZ is negative, so subtract to add positive loss
+		e.lastRecorded = altitude
 	}
 }
 
+// RecomputeSummary refreshes the summary fields of the activity (start, elapsed and
+// moving time, distance, average speed, elevation and heart rate) from the timeseries.
+// Unlike AugmentGPXData it trusts the distance channel when present and leaves the
+// timeseries untouched, so it is safe to use on data coming from any provider.
+func RecomputeSummary(act *Activity, ts *ActivityTimeseries, config AugmentConfig) error {
+	config = config.ApplyDefaults()
+
+	act.StartTime = ts.StartTime
+	act.ElapsedTime = 0
+	act.MovingTime = 0
+	act.Distance = 0
+	act.AvgSpeed = 0
+	act.AvgHR = Optional[uint8]{}
+	act.MaxHR = Optional[uint8]{}
+	act.ElevationGain = Optional[uint16]{}
+	act.ElevationLoss = Optional[uint16]{}
+
+	if len(ts.Data) == 0 {
+		return nil
+	}
+
+	act.ElapsedTime = uint32(ts.MaxOffset() - ts.Data[0].Offset)
+
+	var gpsDist, maxDist float64
+	var hasDistance bool
+	var movingSeconds uint32
+
+	for i := range ts.Data {
+		curr := ts.Data[i]
+		if curr.Distance.Valid {
+			hasDistance = true
+			maxDist = math.Max(maxDist, float64(curr.Distance.Value))
+		}
+
+		if i == 0 {
+			continue
+		}
+		prev := ts.Data[i-1]
+
+		timeDelta := float64(curr.Offset - prev.Offset)
+		speed := -1.0
+
+		if curr.HasGPS() && prev.HasGPS() {
+			d := haversine(prev.Latitude.Value, prev.Longitude.Value, curr.Latitude.Value, curr.Longitude.Value)
+			gpsDist += d
+			if timeDelta > 0 {
+				speed = d / timeDelta
+			}
+		} else if curr.Distance.Valid && prev.Distance.Valid && timeDelta > 0 {
+			speed = (float64(curr.Distance.Value) - float64(prev.Distance.Value)) / timeDelta
+		}
+
+		if timeDelta > 0 && timeDelta <= DefaultMaxGap.Seconds() && ts.movingInterval(prev, curr, speed, DefaultMinMovingSpeedMS) {
+			movingSeconds += uint32(timeDelta)
+		}
+	}
+
+	totalDist := gpsDist
+	if hasDistance {
+		totalDist = maxDist
+	}
+
+	act.Distance = DistanceFromMeters(totalDist)
+	act.MovingTime = movingSeconds
+	if movingSeconds > 0 {
+		act.AvgSpeed = SpeedFromMetersPerSecond(totalDist / float64(movingSeconds))
+	}
+
+	if hasChannel(ts.Data, ChannelAltitude) {
+		totalGain, totalLoss := elevationGainLoss(ts.Data, config.ElevationHysteresisM)
+		act.ElevationGain = Optional[uint16]{Value: uint16(totalGain), Valid: true}
+		act.ElevationLoss = Optional[uint16]{Value: uint16(totalLoss), Valid: true}
+	}
+
+	if hasChannel(ts.Data, ChannelHeartRate) {
+		hrMetrics, err := ts.HRMetrics()
+		if err != nil {
+			return err
+		}
+		act.AvgHR = Optional[uint8]{Value: uint8(hrMetrics.AvgHR), Valid: hrMetrics.AvgHR > 0}
+		act.MaxHR = Optional[uint8]{Value: uint8(hrMetrics.MaxHR), Valid: hrMetrics.MaxHR > 0}
+	}
+
+	return nil
+}
+
+func hasChannel(data []ActivityTimeseriesEntry, ch Channel) bool {
+	for _, entry := range data {
+		if entry.Has(ch) {
+			return true
+		}
+	}
+	return false
+}
+
 // DetectTopographicSplits uses a high/low watermark state machine to correctly isolate hills
 func DetectTopographicSplits(enriched []EnrichedPoint, config LLMSummaryConfig) []TopographicSplit {
 	if len(enriched) == 0 {
@@ -280,7 +480,7 @@ func DetectTopographicSplits(enriched []EnrichedPoint, config LLMSummaryConfig)
 
 		for i := sIdx; i <= eIdx; i++ {
 			pt := enriched[i]
-			if pt.ActualSpeed > config.MinMovingSpeedMS {
+			if pt.Moving {
 				gapAvg.Add(pt.GAPSpeed, pt.TimeDelta)
 				if pt.Entry.HeartRate.Valid {
 					hrAvg.Add(float64(pt.Entry.HeartRate.Value), pt.TimeDelta)
@@ -358,33 +558,48 @@ func DetectTopographicSplits(enriched []EnrichedPoint, config LLMSummaryConfig)
 	return splits
 }
 
-// Helper to look back using actual timestamps, not array indices
+// getHRAtOffset returns the heart rate of the valid sample closest to the offset, the
+// earlier one on ties. ts.Data must be sorted by offset.
 func getHRAtOffset(ts *ActivityTimeseries, targetOffset int) int {
-	bestDiff := math.MaxInt32
-	bestHR := 0
+	i := sort.Search(len(ts.Data), func(i int) bool { return ts.Data[i].Offset >= targetOffset })
 
-	for _, entry := range ts.Data {
-		if !entry.HeartRate.Valid {
-			continue
-		}
-		diff := entry.Offset - targetOffset
-		if diff < 0 {
-			diff = -diff
-		}
-		if diff < bestDiff {
-			bestDiff = diff
-			bestHR = int(entry.HeartRate.Value)
-		}
+	before := i - 1
+	for before >= 0 && !ts.Data[before].HeartRate.Valid {
+		before--
 	}
+	after := i
+	for after < len(ts.Data) && !ts.Data[after].HeartRate.Valid {
+		after++
+	}
+
+	switch {
+	case before < 0 && after == len(ts.Data):
+		return 0
 
-	return bestHR
+	case before < 0:
+		return int(ts.Data[after].HeartRate.Value)
+
+	case after == len(ts.Data):
+		return int(ts.Data[before].HeartRate.Value)
+
+	case targetOffset-ts.Data[before].Offset <= ts.Data[after].Offset-targetOffset:
+		return int(ts.Data[before].HeartRate.Value)
+
+	default:
+		return int(ts.Data[after].HeartRate.Value)
+	}
 }
 
-// Helper to get average HR within a specific time window (used for Post-Max Effort Recovery)
+// getAvgHRInOffsetRange returns the average heart rate of the samples in [startOffset,
+// endOffset], zero when there are none. ts.Data must be sorted by offset.
 func getAvgHRInOffsetRange(ts *ActivityTimeseries, startOffset, endOffset int) int {
 	var sum, count int
-	for _, entry := range ts.Data {
-		if entry.HeartRate.Valid && entry.Offset >= startOffset && entry.Offset <= endOffset {
+	first := sort.Search(len(ts.Data), func(i int) bool { return ts.Data[i].Offset >= startOffset })
+	for _, entry := range ts.Data[first:] {
+		if entry.Offset > endOffset {
+			break
+		}
+		if entry.HeartRate.Valid {
 			sum += int(entry.HeartRate.Value)
 			count++
 		}
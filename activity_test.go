@@ -2,7 +2,9 @@ package stride_test
 
 import (
 	"math"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/gabrieleangeletti/stride"
 )
@@ -71,3 +73,63 @@ func TestWeightedAvg(t *testing.T) {
 		t.Errorf("Time weighting failed, expected 134, got %f", hrAvg.Avg())
 	}
 }
+
+// Tests that the provider's moving flag overrides the speed heuristic for moving time
+func TestAugmentGPXData_ProviderMovingFlag(t *testing.T) {
+	gps := func(lat float64) (stride.Optional[float64], stride.Optional[float64]) {
+		return stride.Optional[float64]{Value: lat, Valid: true}, stride.Optional[float64]{Value: 0, Valid: true}
+	}
+
+	lat0, lon0 := gps(0)
+	lat1, lon1 := gps(0.001) // ~111m in 10s, fast enough for the speed heuristic
+	lat2, lon2 := gps(0.002)
+
+	ts := &stride.ActivityTimeseries{
+		Data: []stride.ActivityTimeseriesEntry{
+			{Offset: 0, Latitude: lat0, Longitude: lon0},
+			{Offset: 10, Latitude: lat1, Longitude: lon1, Moving: stride.Optional[bool]{Value: false, Valid: true}},
+			{Offset: 20, Latitude: lat2, Longitude: lon2},
+			{Offset: 30, Moving: stride.Optional[bool]{Value: true, Valid: true}}, // No GPS, provider still says moving
+		},
+	}
+
+	act := &stride.Activity{}
+	stride.AugmentGPXData(act, ts, stride.AugmentConfig{})
+
+	if act.MovingTime != 20 {
+		t.Errorf("Expected 20s moving time, got %d", act.MovingTime)
+	}
+}
+
+func TestSummarizeForLLM_UnsortedData(t *testing.T) {
+	recording := func() *stride.ActivityTimeseries {
+		ts := steadyRun(time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC), 1200)
+		for i := 1100; i <= 1200; i++ {
+			ts.Data[i].HeartRate.Value = uint8(150 - (i - 1100)) // Easing off at the end
+		}
+		return ts
+	}
+
+	sorted := recording()
+	expected, err := stride.SummarizeForLLM(&stride.Activity{}, sorted, stride.LLMSummaryConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shuffled := recording()
+	slices.Reverse(shuffled.Data)
+	summary, err := stride.SummarizeForLLM(&stride.Activity{}, shuffled, stride.LLMSummaryConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected.Recovery.EndHRDrop60s != 60 {
+		t.Errorf("Expected a 60 bpm drop over the last minute, got %d", expected.Recovery.EndHRDrop60s)
+	}
+	if summary.Recovery != expected.Recovery {
+		t.Errorf("Expected %+v from unsorted data, got %+v", expected.Recovery, summary.Recovery)
+	}
+	if shuffled.Data[0].Offset != 1200 {
+		t.Errorf("Expected the input to be left unsorted")
+	}
+}
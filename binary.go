@@ -0,0 +1,602 @@
+package stride
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrInvalidBinaryTimeseries     = errors.New("invalid binary timeseries")
+	ErrUnsupportedBinaryVersion    = errors.New("unsupported binary timeseries version")
+	binaryTimeseriesMagic          = [4]byte{'S', 'T', 'R', 'D'}
+	binaryTimeseriesCurrentVersion = byte(2)
+)
+
+// Presence of a column across all entries
+const (
+	presenceNone byte = iota
+	presenceAll
+	presenceBitmap
+)
+
+// floatEncodingXOR marks a float column stored as raw IEEE 754 bits, XORed with the
+// previous value. Any other value is an index in floatScales.
+const floatEncodingXOR byte = 0xff
+
+// semicircleDegrees is the size of a FIT semicircle in degrees, exact in a float64.
+const semicircleDegrees = 180.0 / (1 << 31)
+
+// floatScale maps the values of a float column to integers, which are then delta
+// encoded. It is only used when fromInt gives every value back bit for bit.
+type floatScale struct {
+	toInt   func(float64) float64
+	fromInt func(float64) float64
+}
+
+// floatScales is the table of version 1, tried in order. Decimals cover GPX and most
+// providers, the others the values computed by the FIT decoder.
+var floatScales = []floatScale{
+	decimalScale(0),
+	decimalScale(1),
+	decimalScale(2),
+	decimalScale(3),
+	decimalScale(4),
+	decimalScale(5),
+	decimalScale(6),
+	decimalScale(7),
+	decimalScale(8),
+	decimalScale(9),
+	{ // FIT positions
+		toInt:   func(v float64) float64 { return v / semicircleDegrees },
+		fromInt: func(n float64) float64 { return n * semicircleDegrees },
+	},
+	{ // FIT altitudes, with scale 5 and offset 500
+		toInt:   func(v float64) float64 { return (v + 500) * 5 },
+		fromInt: func(n float64) float64 { return n/5 - 500 },
+	},
+}
+
+func decimalScale(digits int) floatScale {
+	scale := math.Pow10(digits)
+	return floatScale{
+		toInt:   func(v float64) float64 { return v * scale },
+		fromInt: func(n float64) float64 { return n / scale },
+	}
+}
+
+// MarshalTimeseries encodes the timeseries in a compact, versioned, columnar binary
+// format meant for storage (e.g. a database blob). Offsets and every channel are stored
+// as separate columns: a presence bitmap followed by the delta encoded values of the
+// entries that have the channel. Floats are stored exactly. Only valid values are kept,
+// so the Value of an invalid Optional decodes as zero.
+//
+// Version 2 adds the extra channels: their names and units follow the entry count, and
+// a float column per channel follows the standard ones. The origin of channels read
+// from FIT developer fields is not kept.
+func MarshalTimeseries(ts *ActivityTimeseries) ([]byte, error) {
+	n := len(ts.Data)
+
+	buf := make([]byte, 0, 64+n*8)
+	buf = append(buf, binaryTimeseriesMagic[:]...)
+	buf = append(buf, binaryTimeseriesCurrentVersion)
+	buf = binary.AppendVarint(buf, ts.StartTime.Unix())
+	buf = binary.AppendUvarint(buf, uint64(ts.StartTime.Nanosecond()))
+	buf = binary.AppendUvarint(buf, uint64(n))
+
+	buf = binary.AppendUvarint(buf, uint64(len(ts.ExtraChannels)))
+	for _, ch := range ts.ExtraChannels {
+		buf = appendString(buf, ch.Name)
+		buf = appendString(buf, ch.Units)
+	}
+
+	var prevOffset int64
+	for _, entry := range ts.Data {
+		buf = binary.AppendVarint(buf, int64(entry.Offset)-prevOffset)
+		prevOffset = int64(entry.Offset)
+	}
+
+	for _, column := range binaryColumns {
+		buf = appendPresence(buf, ts.Data, column.present)
+
+		switch {
+		case column.integer != nil:
+			var values []int64
+			for _, entry := range ts.Data {
+				if column.present(entry) {
+					values = append(values, column.integer(entry))
+				}
+			}
+			buf = appendDeltas(buf, values)
+
+		case column.float != nil:
+			var values []float64
+			for _, entry := range ts.Data {
+				if column.present(entry) {
+					values = append(values, column.float(entry))
+				}
+			}
+			buf = appendFloats(buf, values)
+
+		case column.boolean != nil:
+			var values []bool
+			for _, entry := range ts.Data {
+				if column.present(entry) {
+					values = append(values, column.boolean(entry))
+				}
+			}
+			buf = appendBitmap(buf, values)
+		}
+	}
+
+	for _, ch := range ts.ExtraChannels {
+		present := func(e ActivityTimeseriesEntry) bool { _, ok := e.Extra[ch.Name]; return ok }
+		buf = appendPresence(buf, ts.Data, present)
+
+		var values []float64
+		for _, entry := range ts.Data {
+			if value, ok := entry.Extra[ch.Name]; ok {
+				values = append(values, value)
+			}
+		}
+		buf = appendFloats(buf, values)
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(ts.Pauses)))
+	var prevPause int64
+	for _, p := range ts.Pauses {
+		buf = binary.AppendVarint(buf, int64(p.StartOffset)-prevPause)
+		buf = binary.AppendVarint(buf, int64(p.Duration()))
+		buf = appendString(buf, string(p.Reason))
+		prevPause = int64(p.EndOffset)
+	}
+
+	return buf, nil
+}
+
+// UnmarshalTimeseries decodes data written by MarshalTimeseries, in any version up to
+// the current one. StartTime is in UTC.
+func UnmarshalTimeseries(data []byte) (*ActivityTimeseries, error) {
+	r := &binaryReader{data: data}
+
+	var magic [4]byte
+	copy(magic[:], r.bytes(4))
+	if r.err != nil || magic != binaryTimeseriesMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidBinaryTimeseries)
+	}
+
+	version := r.byte()
+	if r.err == nil && (version < 1 || version > binaryTimeseriesCurrentVersion) {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedBinaryVersion, version)
+	}
+
+	seconds := r.varint()
+	nanoseconds := r.uvarint()
+	ts := &ActivityTimeseries{StartTime: time.Unix(seconds, int64(nanoseconds)).UTC()}
+
+	n := r.uvarint()
+	if r.err != nil || n > uint64(len(data)) {
+		// Every entry takes at least one byte for its offset
+		return nil, fmt.Errorf("%w: bad entry count", ErrInvalidBinaryTimeseries)
+	}
+
+	if version >= 2 {
+		channels := r.uvarint()
+		if r.err == nil && channels > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: bad extra channel count", ErrInvalidBinaryTimeseries)
+		}
+		for i := uint64(0); i < channels && r.err == nil; i++ {
+			ts.ExtraChannels = append(ts.ExtraChannels, ExtraChannel{Name: r.string(), Units: r.string()})
+		}
+	}
+
+	ts.Data = make([]ActivityTimeseriesEntry, n)
+	var offset int64
+	for i := range ts.Data {
+		offset += r.varint()
+		ts.Data[i].Offset = int(offset)
+	}
+
+	for _, column := range binaryColumns {
+		present := r.presence(int(n))
+
+		switch {
+		case column.integer != nil:
+			var value int64
+			for i, ok := range present {
+				if ok {
+					value += r.varint()
+					column.setInteger(&ts.Data[i], value)
+				}
+			}
+
+		case column.float != nil:
+			values := r.floats(countTrue(present))
+			k := 0
+			for i, ok := range present {
+				if ok && k < len(values) {
+					column.setFloat(&ts.Data[i], values[k])
+					k++
+				}
+			}
+
+		case column.boolean != nil:
+			values := r.bitmap(countTrue(present))
+			k := 0
+			for i, ok := range present {
+				if ok && k < len(values) {
+					column.setBoolean(&ts.Data[i], values[k])
+					k++
+				}
+			}
+		}
+	}
+
+	for _, ch := range ts.ExtraChannels {
+		present := r.presence(int(n))
+		values := r.floats(countTrue(present))
+		k := 0
+		for i, ok := range present {
+			if ok && k < len(values) {
+				if ts.Data[i].Extra == nil {
+					ts.Data[i].Extra = make(map[string]float64)
+				}
+				ts.Data[i].Extra[ch.Name] = values[k]
+				k++
+			}
+		}
+	}
+
+	pauses := r.uvarint()
+	if r.err == nil && pauses > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: bad pause count", ErrInvalidBinaryTimeseries)
+	}
+	var prevPause int64
+	for i := uint64(0); i < pauses && r.err == nil; i++ {
+		start := prevPause + r.varint()
+		end := start + r.varint()
+		ts.Pauses = append(ts.Pauses, Pause{StartOffset: int(start), EndOffset: int(end), Reason: PauseReason(r.string())})
+		prevPause = end
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.pos != len(data) {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidBinaryTimeseries, len(data)-r.pos)
+	}
+
+	return ts, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler with MarshalTimeseries.
+func (ts *ActivityTimeseries) MarshalBinary() ([]byte, error) {
+	return MarshalTimeseries(ts)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler with UnmarshalTimeseries.
+func (ts *ActivityTimeseries) UnmarshalBinary(data []byte) error {
+	decoded, err := UnmarshalTimeseries(data)
+	if err != nil {
+		return err
+	}
+	*ts = *decoded
+	return nil
+}
+
+// binaryColumn describes how one channel is stored. Exactly one of integer, float and
+// boolean is set. Latitude and longitude are separate columns so each keeps its own
+// Valid flag.
+type binaryColumn struct {
+	present func(ActivityTimeseriesEntry) bool
+
+	integer    func(ActivityTimeseriesEntry) int64
+	setInteger func(*ActivityTimeseriesEntry, int64)
+
+	float    func(ActivityTimeseriesEntry) float64
+	setFloat func(*ActivityTimeseriesEntry, float64)
+
+	boolean    func(ActivityTimeseriesEntry) bool
+	setBoolean func(*ActivityTimeseriesEntry, bool)
+}
+
+// binaryColumns is the column order of the standard channels since version 1. New
+// channels need a new version.
+var binaryColumns = []binaryColumn{
+	{
+		present:    func(e ActivityTimeseriesEntry) bool { return e.HeartRate.Valid },
+		integer:    func(e ActivityTimeseriesEntry) int64 { return int64(e.HeartRate.Value) },
+		setInteger: func(e *ActivityTimeseriesEntry, v int64) { e.HeartRate = Optional[uint8]{Value: uint8(v), Valid: true} },
+	},
+	{
+		present:    func(e ActivityTimeseriesEntry) bool { return e.Cadence.Valid },
+		integer:    func(e ActivityTimeseriesEntry) int64 { return int64(e.Cadence.Value) },
+		setInteger: func(e *ActivityTimeseriesEntry, v int64) { e.Cadence = Optional[uint8]{Value: uint8(v), Valid: true} },
+	},
+	{
+		present: func(e ActivityTimeseriesEntry) bool { return e.Distance.Valid },
+		integer: func(e ActivityTimeseriesEntry) int64 { return int64(e.Distance.Value) },
+		setInteger: func(e *ActivityTimeseriesEntry, v int64) {
+			e.Distance = Optional[Distance]{Value: Distance(v), Valid: true}
+		},
+	},
+	{
+		present:  func(e ActivityTimeseriesEntry) bool { return e.Altitude.Valid },
+		float:    func(e ActivityTimeseriesEntry) float64 { return e.Altitude.Value },
+		setFloat: func(e *ActivityTimeseriesEntry, v float64) { e.Altitude = Optional[float64]{Value: v, Valid: true} },
+	},
+	{
+		present:    func(e ActivityTimeseriesEntry) bool { return e.Velocity.Valid },
+		integer:    func(e ActivityTimeseriesEntry) int64 { return int64(e.Velocity.Value) },
+		setInteger: func(e *ActivityTimeseriesEntry, v int64) { e.Velocity = Optional[Speed]{Value: Speed(v), Valid: true} },
+	},
+	{
+		present:  func(e ActivityTimeseriesEntry) bool { return e.Latitude.Valid },
+		float:    func(e ActivityTimeseriesEntry) float64 { return e.Latitude.Value },
+		setFloat: func(e *ActivityTimeseriesEntry, v float64) { e.Latitude = Optional[float64]{Value: v, Valid: true} },
+	},
+	{
+		present:  func(e ActivityTimeseriesEntry) bool { return e.Longitude.Valid },
+		float:    func(e ActivityTimeseriesEntry) float64 { return e.Longitude.Value },
+		setFloat: func(e *ActivityTimeseriesEntry, v float64) { e.Longitude = Optional[float64]{Value: v, Valid: true} },
+	},
+	{
+		present:    func(e ActivityTimeseriesEntry) bool { return e.Power.Valid },
+		integer:    func(e ActivityTimeseriesEntry) int64 { return int64(e.Power.Value) },
+		setInteger: func(e *ActivityTimeseriesEntry, v int64) { e.Power = Optional[uint16]{Value: uint16(v), Valid: true} },
+	},
+	{
+		present:    func(e ActivityTimeseriesEntry) bool { return e.Temperature.Valid },
+		integer:    func(e ActivityTimeseriesEntry) int64 { return int64(e.Temperature.Value) },
+		setInteger: func(e *ActivityTimeseriesEntry, v int64) { e.Temperature = Optional[int8]{Value: int8(v), Valid: true} },
+	},
+	{
+		present:    func(e ActivityTimeseriesEntry) bool { return e.Moving.Valid },
+		boolean:    func(e ActivityTimeseriesEntry) bool { return e.Moving.Value },
+		setBoolean: func(e *ActivityTimeseriesEntry, v bool) { e.Moving = Optional[bool]{Value: v, Valid: true} },
+	},
+	{
+		present:  func(e ActivityTimeseriesEntry) bool { return e.Grade.Valid },
+		float:    func(e ActivityTimeseriesEntry) float64 { return e.Grade.Value },
+		setFloat: func(e *ActivityTimeseriesEntry, v float64) { e.Grade = Optional[float64]{Value: v, Valid: true} },
+	},
+}
+
+func appendPresence(buf []byte, data []ActivityTimeseriesEntry, present func(ActivityTimeseriesEntry) bool) []byte {
+	bits := make([]bool, len(data))
+	count := 0
+	for i, entry := range data {
+		bits[i] = present(entry)
+		if bits[i] {
+			count++
+		}
+	}
+
+	switch count {
+	case 0:
+		return append(buf, presenceNone)
+	case len(data):
+		return append(buf, presenceAll)
+	default:
+		return appendBitmap(append(buf, presenceBitmap), bits)
+	}
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendBitmap(buf []byte, bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return append(buf, packed...)
+}
+
+// appendFloats stores the values as integers with the first exact scale, which exists
+// for data read from GPX and FIT files, and falls back to the raw bits otherwise.
+func appendFloats(buf []byte, values []float64) []byte {
+	if len(values) == 0 {
+		return buf
+	}
+
+	for i, scale := range floatScales {
+		if scaled, ok := scaleFloats(values, scale); ok {
+			return appendDeltas(append(buf, byte(i)), scaled)
+		}
+	}
+
+	// Consecutive samples share sign, exponent and the top of the mantissa, so the XOR
+	// has leading zeros and a varint is shorter than the 8 raw bytes
+	buf = append(buf, floatEncodingXOR)
+	var prev uint64
+	for _, v := range values {
+		bits := math.Float64bits(v)
+		buf = binary.AppendUvarint(buf, bits^prev)
+		prev = bits
+	}
+	return buf
+}
+
+func scaleFloats(values []float64, scale floatScale) ([]int64, bool) {
+	scaled := make([]int64, len(values))
+	for i, v := range values {
+		n := math.Round(scale.toInt(v))
+		if math.IsNaN(n) || math.Abs(n) > 1<<53 {
+			return nil, false
+		}
+		if math.Float64bits(scale.fromInt(n)) != math.Float64bits(v) {
+			return nil, false
+		}
+		scaled[i] = int64(n)
+	}
+	return scaled, true
+}
+
+func appendDeltas(buf []byte, values []int64) []byte {
+	var prev int64
+	for _, v := range values {
+		buf = binary.AppendVarint(buf, v-prev)
+		prev = v
+	}
+	return buf
+}
+
+func countTrue(bits []bool) int {
+	count := 0
+	for _, bit := range bits {
+		if bit {
+			count++
+		}
+	}
+	return count
+}
+
+// binaryReader reads the format written by MarshalTimeseries. The first error is kept
+// and every later read returns zero values.
+type binaryReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *binaryReader) fail(what string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: truncated %s at byte %d", ErrInvalidBinaryTimeseries, what, r.pos)
+	}
+}
+
+func (r *binaryReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.fail("bytes")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *binaryReader) byte() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *binaryReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data[r.pos:])
+	if n <= 0 {
+		r.fail("varint")
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.fail("uvarint")
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *binaryReader) string() string {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		r.fail("string")
+		return ""
+	}
+	return string(r.bytes(int(n)))
+}
+
+func (r *binaryReader) bitmap(n int) []bool {
+	packed := r.bytes((n + 7) / 8)
+	if packed == nil {
+		return nil
+	}
+	bits := make([]bool, n)
+	for i := range bits {
+		bits[i] = packed[i/8]&(1<<(i%8)) != 0
+	}
+	return bits
+}
+
+func (r *binaryReader) presence(n int) []bool {
+	switch mode := r.byte(); mode {
+	case presenceNone:
+		return make([]bool, n)
+
+	case presenceAll:
+		bits := make([]bool, n)
+		for i := range bits {
+			bits[i] = true
+		}
+		return bits
+
+	case presenceBitmap:
+		bits := r.bitmap(n)
+		if bits == nil {
+			return make([]bool, n)
+		}
+		return bits
+
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("%w: unknown presence mode %d", ErrInvalidBinaryTimeseries, mode)
+		}
+		return make([]bool, n)
+	}
+}
+
+func (r *binaryReader) floats(n int) []float64 {
+	if n == 0 {
+		return nil
+	}
+
+	values := make([]float64, n)
+	encoding := r.byte()
+	switch {
+	case r.err != nil:
+		return nil
+
+	case encoding == floatEncodingXOR:
+		var prev uint64
+		for i := range values {
+			prev ^= r.uvarint()
+			values[i] = math.Float64frombits(prev)
+		}
+
+	case int(encoding) < len(floatScales):
+		scale := floatScales[encoding]
+		var v int64
+		for i := range values {
+			v += r.varint()
+			values[i] = scale.fromInt(float64(v))
+		}
+
+	default:
+		r.err = fmt.Errorf("%w: unknown float encoding %d", ErrInvalidBinaryTimeseries, encoding)
+		return nil
+	}
+
+	return values
+}
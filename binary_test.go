@@ -0,0 +1,144 @@
+package stride_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func TestMarshalTimeseries(t *testing.T) {
+	start := time.Date(2025, 10, 1, 7, 0, 0, 0, time.UTC)
+	ts := pausedRun(start)
+	for i := range ts.Data {
+		entry := &ts.Data[i]
+		entry.Temperature = Optional[int8]{Value: int8(-5 + i%10), Valid: true}
+		entry.Velocity = Optional[Speed]{Value: Speed(3000 + i%11), Valid: true}
+		if i%5 != 0 {
+			entry.Cadence = Optional[uint8]{Value: uint8(80 + i%7), Valid: true}
+		}
+		if i > 100 {
+			entry.Power = Optional[uint16]{Value: uint16(250 + i%50), Valid: true}
+		}
+		if i%2 == 0 {
+			entry.Moving = Optional[bool]{Value: i%13 != 0, Valid: true}
+		}
+		if i%3 == 0 {
+			entry.Grade = Optional[float64]{Value: math.Sin(float64(i)), Valid: true}
+		}
+		if i%17 == 0 {
+			entry.Longitude = Optional[float64]{}
+		}
+		if i%4 != 0 {
+			entry.Extra = map[string]float64{"form_power": float64(200+i%30) + 0.25}
+		}
+		if i > 300 {
+			if entry.Extra == nil {
+				entry.Extra = map[string]float64{}
+			}
+			entry.Extra["core_temperature"] = 37 + float64(i%9)/10
+		}
+	}
+	ts.ExtraChannels = []ExtraChannel{{Name: "form_power", Units: "watts"}, {Name: "core_temperature", Units: "°C"}}
+	ts.Data[42].Altitude = Optional[float64]{Value: math.NaN(), Valid: true}
+
+	data, err := MarshalTimeseries(ts)
+	require.NoError(t, err)
+
+	decoded, err := UnmarshalTimeseries(data)
+	require.NoError(t, err)
+
+	assert.True(t, ts.StartTime.Equal(decoded.StartTime))
+	assert.Equal(t, ts.Pauses, decoded.Pauses)
+	assert.Equal(t, ts.ExtraChannels, decoded.ExtraChannels)
+	require.Len(t, decoded.Data, len(ts.Data))
+	assert.True(t, math.IsNaN(decoded.Data[42].Altitude.Value))
+	ts.Data[42].Altitude, decoded.Data[42].Altitude = Optional[float64]{}, Optional[float64]{}
+	assert.Equal(t, ts.Data, decoded.Data)
+
+	t.Run("BinaryMarshaler", func(t *testing.T) {
+		data, err := ts.MarshalBinary()
+		require.NoError(t, err)
+
+		var decoded ActivityTimeseries
+		require.NoError(t, decoded.UnmarshalBinary(data))
+		assert.Equal(t, ts.Data, decoded.Data)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		data, err := MarshalTimeseries(&ActivityTimeseries{StartTime: start})
+		require.NoError(t, err)
+
+		decoded, err := UnmarshalTimeseries(data)
+		require.NoError(t, err)
+		assert.Empty(t, decoded.Data)
+		assert.True(t, start.Equal(decoded.StartTime))
+
+		data, err = MarshalTimeseries(&ActivityTimeseries{})
+		require.NoError(t, err)
+
+		decoded, err = UnmarshalTimeseries(data)
+		require.NoError(t, err)
+		assert.True(t, decoded.StartTime.IsZero())
+	})
+
+	t.Run("Version1", func(t *testing.T) {
+		// steadyRun(start, 3) with a timer pause, written before extra channels
+		data, err := hex.DecodeString("5354524401e0c5e68d0d00040002020201ac020000000001000606060101d00f0202020001068095f52a36363601000e000000000000000102020574696d6572")
+		require.NoError(t, err)
+
+		decoded, err := UnmarshalTimeseries(data)
+		require.NoError(t, err)
+		assert.True(t, start.Equal(decoded.StartTime))
+		assert.Equal(t, steadyRun(start, 3).Data, decoded.Data)
+		assert.Equal(t, []Pause{{StartOffset: 1, EndOffset: 2, Reason: PauseReasonTimer}}, decoded.Pauses)
+		assert.Empty(t, decoded.ExtraChannels)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := UnmarshalTimeseries([]byte("nope"))
+		assert.ErrorIs(t, err, ErrInvalidBinaryTimeseries)
+
+		for _, n := range []int{5, len(data) / 2, len(data) - 1} {
+			_, err := UnmarshalTimeseries(data[:n])
+			assert.ErrorIs(t, err, ErrInvalidBinaryTimeseries, "truncated at %d", n)
+		}
+
+		future := append([]byte{}, data...)
+		future[4] = 99
+		_, err = UnmarshalTimeseries(future)
+		assert.ErrorIs(t, err, ErrUnsupportedBinaryVersion)
+	})
+}
+
+func TestMarshalTimeseriesSize(t *testing.T) {
+	start := time.Date(2025, 10, 1, 7, 0, 0, 0, time.UTC)
+	act := &Activity{Sport: SportRunning, StartTime: start, ElapsedTime: 3600}
+
+	fitData, err := CreateFITFileInMemory(act, steadyRun(start, 3600), SportRunning)
+	require.NoError(t, err)
+
+	// Positions read from FIT are whole semicircles
+	ts, err := FITFileToActivityTimeseries(fitData)
+	require.NoError(t, err)
+
+	data, err := MarshalTimeseries(ts)
+	require.NoError(t, err)
+
+	decoded, err := UnmarshalTimeseries(data)
+	require.NoError(t, err)
+	assert.Equal(t, ts.Data, decoded.Data)
+
+	jsonData, err := json.Marshal(ts)
+	require.NoError(t, err)
+
+	t.Logf("binary %d bytes, FIT %d bytes, JSON %d bytes", len(data), len(fitData), len(jsonData))
+	assert.Less(t, len(data)*2, len(fitData))
+	assert.Less(t, len(data)*20, len(jsonData))
+}
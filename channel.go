@@ -0,0 +1,159 @@
+package stride
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Channel identifies one of the data streams of an ActivityTimeseriesEntry.
+type Channel string
+
+const (
+	ChannelHeartRate   Channel = "heart_rate"
+	ChannelCadence     Channel = "cadence"
+	ChannelDistance    Channel = "distance"
+	ChannelAltitude    Channel = "altitude"
+	ChannelVelocity    Channel = "velocity"
+	ChannelPosition    Channel = "position" // Latitude and longitude together
+	ChannelPower       Channel = "power"
+	ChannelTemperature Channel = "temperature"
+	ChannelMoving      Channel = "moving"
+	ChannelGrade       Channel = "grade"
+)
+
+// AllChannels lists every channel in a stable order.
+var AllChannels = []Channel{
+	ChannelHeartRate,
+	ChannelCadence,
+	ChannelDistance,
+	ChannelAltitude,
+	ChannelVelocity,
+	ChannelPosition,
+	ChannelPower,
+	ChannelTemperature,
+	ChannelMoving,
+	ChannelGrade,
+}
+
+// ExtraChannel describes a data stream beyond the standard channels, such as running
+// power or core temperature recorded by a third-party sensor. Values are stored by Name
+// in ActivityTimeseriesEntry.Extra.
+type ExtraChannel struct {
+	Name  string
+	Units string
+	FIT   Optional[FITDeveloperField] // Origin of channels read from FIT developer fields
+}
+
+// mergeExtraChannels returns the channels of a followed by those of b with a new name.
+func mergeExtraChannels(a, b []ExtraChannel) []ExtraChannel {
+	merged := append([]ExtraChannel(nil), a...)
+	for _, ch := range b {
+		if !slices.ContainsFunc(merged, func(c ExtraChannel) bool { return c.Name == ch.Name }) {
+			merged = append(merged, ch)
+		}
+	}
+	return merged
+}
+
+// ParseChannel validates and converts a string to Channel
+func ParseChannel(s string) (Channel, error) {
+	for _, ch := range AllChannels {
+		if string(ch) == s {
+			return ch, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown channel: %q", s)
+}
+
+// Has reports whether the entry carries a valid value for the channel.
+func (a ActivityTimeseriesEntry) Has(ch Channel) bool {
+	switch ch {
+	case ChannelHeartRate:
+		return a.HeartRate.Valid
+	case ChannelCadence:
+		return a.Cadence.Valid
+	case ChannelDistance:
+		return a.Distance.Valid
+	case ChannelAltitude:
+		return a.Altitude.Valid
+	case ChannelVelocity:
+		return a.Velocity.Valid
+	case ChannelPosition:
+		return a.HasGPS()
+	case ChannelPower:
+		return a.Power.Valid
+	case ChannelTemperature:
+		return a.Temperature.Valid
+	case ChannelMoving:
+		return a.Moving.Valid
+	case ChannelGrade:
+		return a.Grade.Valid
+	default:
+		return false
+	}
+}
+
+// channelValue returns the value of a numeric channel, with velocity in m/s.
+func (entry ActivityTimeseriesEntry) channelValue(ch Channel) (float64, bool) {
+	switch ch {
+	case ChannelHeartRate:
+		return float64(entry.HeartRate.Value), entry.HeartRate.Valid
+
+	case ChannelCadence:
+		return float64(entry.Cadence.Value), entry.Cadence.Valid
+
+	case ChannelDistance:
+		return float64(entry.Distance.Value), entry.Distance.Valid
+
+	case ChannelAltitude:
+		return entry.Altitude.Value, entry.Altitude.Valid
+
+	case ChannelVelocity:
+		return entry.Velocity.Value.MetersPerSecond(), entry.Velocity.Valid
+
+	case ChannelPower:
+		return float64(entry.Power.Value), entry.Power.Valid
+
+	case ChannelTemperature:
+		return float64(entry.Temperature.Value), entry.Temperature.Valid
+
+	case ChannelGrade:
+		return entry.Grade.Value, entry.Grade.Valid
+
+	default:
+		return 0, false
+	}
+}
+
+// CopyChannel copies the channel value from src into the entry, including its validity.
+func (a *ActivityTimeseriesEntry) CopyChannel(ch Channel, src ActivityTimeseriesEntry) {
+	switch ch {
+	case ChannelHeartRate:
+		a.HeartRate = src.HeartRate
+	case ChannelCadence:
+		a.Cadence = src.Cadence
+	case ChannelDistance:
+		a.Distance = src.Distance
+	case ChannelAltitude:
+		a.Altitude = src.Altitude
+	case ChannelVelocity:
+		a.Velocity = src.Velocity
+	case ChannelPosition:
+		a.Latitude = src.Latitude
+		a.Longitude = src.Longitude
+	case ChannelPower:
+		a.Power = src.Power
+	case ChannelTemperature:
+		a.Temperature = src.Temperature
+	case ChannelMoving:
+		a.Moving = src.Moving
+	case ChannelGrade:
+		a.Grade = src.Grade
+	}
+}
+
+// ClearChannel marks the channel as invalid.
+func (a *ActivityTimeseriesEntry) ClearChannel(ch Channel) {
+	a.CopyChannel(ch, ActivityTimeseriesEntry{})
+}
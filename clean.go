@@ -0,0 +1,347 @@
+package stride
+
+import (
+	"maps"
+	"math"
+	"sort"
+	"time"
+)
+
+// CleanStep identifies one of the stages of Clean.
+type CleanStep string
+
+const (
+	CleanStepDuplicateOffsets CleanStep = "duplicate_offsets"
+	CleanStepGPSOutliers      CleanStep = "gps_outliers"
+	CleanStepAltitudeSpikes   CleanStep = "altitude_spikes"
+	CleanStepHeartRateRange   CleanStep = "heart_rate_range"
+	CleanStepCadenceLock      CleanStep = "cadence_lock"
+	CleanStepHeartRateSpikes  CleanStep = "heart_rate_spikes"
+)
+
+// AllCleanSteps lists every cleaning stage in the order they run.
+var AllCleanSteps = []CleanStep{
+	CleanStepDuplicateOffsets,
+	CleanStepGPSOutliers,
+	CleanStepAltitudeSpikes,
+	CleanStepHeartRateRange,
+	CleanStepCadenceLock,
+	CleanStepHeartRateSpikes,
+}
+
+type CleanConfig struct {
+	Steps []CleanStep // Stages to run, in the order of AllCleanSteps (default: all)
+
+	MaxSpeedMS         float64       // GPS points implying a faster speed are outliers (default: 30 m/s)
+	MaxVerticalSpeedMS float64       // Faster altitude changes are spikes (default: 5 m/s)
+	MaxHeartRateRate   float64       // Faster heart rate changes are spikes (default: 20 bpm/s)
+	MaxSpikeDuration   time.Duration // Longest excursion treated as a spike rather than a real change (default: 30s)
+
+	MinHeartRate int // Lower readings are dropouts (default: 30)
+	MaxHeartRate int // Higher readings are dropouts (default: 230)
+
+	CadenceLockTolerance   int           // Max bpm difference between heart rate and cadence (or twice the cadence) (default: 1)
+	MinCadenceLockDuration time.Duration // Shortest lock that is removed (default: 30s)
+}
+
+func (c CleanConfig) ApplyDefaults() CleanConfig {
+	config := c
+	if config.Steps == nil {
+		config.Steps = AllCleanSteps
+	}
+	if config.MaxSpeedMS == 0 {
+		config.MaxSpeedMS = 30
+	}
+	if config.MaxVerticalSpeedMS == 0 {
+		config.MaxVerticalSpeedMS = 5
+	}
+	if config.MaxHeartRateRate == 0 {
+		config.MaxHeartRateRate = 20
+	}
+	if config.MaxSpikeDuration == 0 {
+		config.MaxSpikeDuration = 30 * time.Second
+	}
+	if config.MinHeartRate == 0 {
+		config.MinHeartRate = 30
+	}
+	if config.MaxHeartRate == 0 {
+		config.MaxHeartRate = 230
+	}
+	if config.CadenceLockTolerance == 0 {
+		config.CadenceLockTolerance = 1
+	}
+	if config.MinCadenceLockDuration == 0 {
+		config.MinCadenceLockDuration = 30 * time.Second
+	}
+	return config
+}
+
+// CleanChange records a single value removed by Clean. Channel is empty when the whole
+// sample was dropped.
+type CleanChange struct {
+	Step    CleanStep
+	Offset  int
+	Channel Channel
+}
+
+type CleanReport struct {
+	Changes []CleanChange
+}
+
+// Count returns how many values the step removed.
+func (r *CleanReport) Count(step CleanStep) int {
+	var count int
+	for _, change := range r.Changes {
+		if change.Step == step {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *CleanReport) add(step CleanStep, offset int, ch Channel) {
+	r.Changes = append(r.Changes, CleanChange{Step: step, Offset: offset, Channel: ch})
+}
+
+// Clean returns a copy of the timeseries with recording artifacts removed, along with a
+// report of every change. Samples are sorted by offset and duplicates merged; GPS
+// outliers, altitude and heart rate spikes, out of range heart rate readings and optical
+// heart rate locked onto cadence are invalidated rather than guessed, so a later
+// Resample can interpolate them if needed.
+func (ts *ActivityTimeseries) Clean(config CleanConfig) (*ActivityTimeseries, *CleanReport) {
+	config = config.ApplyDefaults()
+
+	cleaned := &ActivityTimeseries{
+		StartTime: ts.StartTime,
+		Data:      make([]ActivityTimeseriesEntry, len(ts.Data)),
+		Pauses:    append([]Pause(nil), ts.Pauses...),
+
+		ExtraChannels: append([]ExtraChannel(nil), ts.ExtraChannels...),
+	}
+	copy(cleaned.Data, ts.Data)
+	for i := range cleaned.Data {
+		cleaned.Data[i].Extra = maps.Clone(cleaned.Data[i].Extra)
+	}
+	sort.SliceStable(cleaned.Data, func(i, j int) bool { return cleaned.Data[i].Offset < cleaned.Data[j].Offset })
+
+	report := &CleanReport{}
+	maxSpike := int(config.MaxSpikeDuration.Seconds())
+
+	for _, step := range AllCleanSteps {
+		if !containsCleanStep(config.Steps, step) {
+			continue
+		}
+
+		switch step {
+		case CleanStepDuplicateOffsets:
+			cleaned.Data = removeDuplicateOffsets(cleaned.Data, report)
+
+		case CleanStepGPSOutliers:
+			distance := func(a, b ActivityTimeseriesEntry) float64 {
+				return haversine(a.Latitude.Value, a.Longitude.Value, b.Latitude.Value, b.Longitude.Value)
+			}
+			removeSpikes(cleaned.Data, ChannelPosition, step, distance, config.MaxSpeedMS, maxSpike, report)
+
+		case CleanStepAltitudeSpikes:
+			climb := func(a, b ActivityTimeseriesEntry) float64 {
+				return math.Abs(b.Altitude.Value - a.Altitude.Value)
+			}
+			removeSpikes(cleaned.Data, ChannelAltitude, step, climb, config.MaxVerticalSpeedMS, maxSpike, report)
+
+		case CleanStepHeartRateRange:
+			for i := range cleaned.Data {
+				entry := &cleaned.Data[i]
+				hr := int(entry.HeartRate.Value)
+				if entry.HeartRate.Valid && (hr < config.MinHeartRate || hr > config.MaxHeartRate) {
+					entry.ClearChannel(ChannelHeartRate)
+					report.add(step, entry.Offset, ChannelHeartRate)
+				}
+			}
+
+		case CleanStepCadenceLock:
+			removeCadenceLock(cleaned.Data, config, report)
+
+		case CleanStepHeartRateSpikes:
+			change := func(a, b ActivityTimeseriesEntry) float64 {
+				return math.Abs(float64(b.HeartRate.Value) - float64(a.HeartRate.Value))
+			}
+			removeSpikes(cleaned.Data, ChannelHeartRate, step, change, config.MaxHeartRateRate, maxSpike, report)
+		}
+	}
+
+	return cleaned, report
+}
+
+func containsCleanStep(steps []CleanStep, step CleanStep) bool {
+	for _, s := range steps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDuplicateOffsets keeps the first sample at each offset, filling its missing
+// channels from the duplicates. data must be sorted by offset.
+func removeDuplicateOffsets(data []ActivityTimeseriesEntry, report *CleanReport) []ActivityTimeseriesEntry {
+	if len(data) == 0 {
+		return data
+	}
+
+	deduped := data[:1]
+	for _, entry := range data[1:] {
+		last := &deduped[len(deduped)-1]
+		if entry.Offset != last.Offset {
+			deduped = append(deduped, entry)
+			continue
+		}
+
+		for _, ch := range AllChannels {
+			if !last.Has(ch) && entry.Has(ch) {
+				last.CopyChannel(ch, entry)
+			}
+		}
+		for name, value := range entry.Extra {
+			if _, ok := last.Extra[name]; !ok {
+				if last.Extra == nil {
+					last.Extra = make(map[string]float64, len(entry.Extra))
+				}
+				last.Extra[name] = value
+			}
+		}
+		report.add(CleanStepDuplicateOffsets, entry.Offset, "")
+	}
+
+	return deduped
+}
+
+// removeSpikes invalidates short excursions of a channel. delta measures the change
+// between two samples, and a change faster than maxRate per second starts a candidate
+// spike. The excursion is removed when a second fast change brings the channel back in
+// line with the last good sample within maxDuration seconds; otherwise it is kept as a
+// real change. When the first fast change comes within maxDuration of the first sample
+// and the channel is steady after it, the recording started on an artifact and the
+// samples before it are removed.
+func removeSpikes(data []ActivityTimeseriesEntry, ch Channel, step CleanStep, delta func(a, b ActivityTimeseriesEntry) float64, maxRate float64, maxDuration int, report *CleanReport) {
+	var idx []int
+	for i, entry := range data {
+		if entry.Has(ch) {
+			idx = append(idx, i)
+		}
+	}
+	if len(idx) < 2 {
+		return
+	}
+
+	fast := func(a, b int) bool {
+		dt := data[b].Offset - data[a].Offset
+		if dt <= 0 {
+			return false
+		}
+		return delta(data[a], data[b])/float64(dt) > maxRate
+	}
+
+	remove := func(from, to int) {
+		for _, i := range idx[from:to] {
+			data[i].ClearChannel(ch)
+			report.add(step, data[i].Offset, ch)
+		}
+	}
+
+	// Only the first fast change can end a leading artifact, later ones are regular spikes
+	start := 0
+	for k := 1; k < len(idx) && data[idx[k]].Offset-data[idx[0]].Offset <= maxDuration; k++ {
+		if !fast(idx[k-1], idx[k]) {
+			continue
+		}
+		if k+1 < len(idx) && !fast(idx[k], idx[k+1]) {
+			start = k
+		}
+		break
+	}
+	remove(0, start)
+
+	ref := start
+	for k := start + 1; k < len(idx); {
+		if !fast(idx[ref], idx[k]) {
+			ref = k
+			k++
+			continue
+		}
+
+		end := -1
+		for j := k + 1; j < len(idx) && data[idx[j]].Offset-data[idx[k]].Offset <= maxDuration; j++ {
+			if fast(idx[j-1], idx[j]) && !fast(idx[ref], idx[j]) {
+				end = j
+				break
+			}
+		}
+
+		if end < 0 {
+			ref = k
+			k++
+			continue
+		}
+
+		remove(k, end)
+		ref = end
+		k = end + 1
+	}
+}
+
+// removeCadenceLock invalidates heart rate where an optical sensor picked up the step
+// rate instead of the pulse: heart rate matches the cadence, or twice the cadence for
+// per-leg cadence, for at least MinCadenceLockDuration while the cadence changes. A
+// steady cadence matching a steady heart rate is left alone, as it can be genuine.
+func removeCadenceLock(data []ActivityTimeseriesEntry, config CleanConfig, report *CleanReport) {
+	minDuration := int(config.MinCadenceLockDuration.Seconds())
+
+	locked := func(entry ActivityTimeseriesEntry) bool {
+		if !entry.HeartRate.Valid || !entry.Cadence.Valid || entry.Cadence.Value == 0 {
+			return false
+		}
+		hr := int(entry.HeartRate.Value)
+		cadence := int(entry.Cadence.Value)
+		return abs(hr-cadence) <= config.CadenceLockTolerance || abs(hr-2*cadence) <= config.CadenceLockTolerance
+	}
+
+	flush := func(from, to int) {
+		if from < 0 || data[to-1].Offset-data[from].Offset < minDuration {
+			return
+		}
+
+		minCadence, maxCadence := math.MaxInt, 0
+		for _, entry := range data[from:to] {
+			minCadence = min(minCadence, int(entry.Cadence.Value))
+			maxCadence = max(maxCadence, int(entry.Cadence.Value))
+		}
+		if maxCadence-minCadence <= config.CadenceLockTolerance {
+			return
+		}
+
+		for i := from; i < to; i++ {
+			data[i].ClearChannel(ChannelHeartRate)
+			report.add(CleanStepCadenceLock, data[i].Offset, ChannelHeartRate)
+		}
+	}
+
+	runStart := -1
+	for i, entry := range data {
+		if locked(entry) {
+			if runStart < 0 {
+				runStart = i
+			}
+			continue
+		}
+		flush(runStart, i)
+		runStart = -1
+	}
+	flush(runStart, len(data))
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
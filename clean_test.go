@@ -0,0 +1,111 @@
+package stride_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func noisyRun() *ActivityTimeseries {
+	ts := steadyRun(time.Date(2025, 5, 1, 6, 0, 0, 0, time.UTC), 300)
+
+	// Optical HR reads high until it locks on the pulse
+	for i := 0; i < 10; i++ {
+		ts.Data[i].HeartRate.Value = 190
+	}
+
+	ts.Data[100].Latitude.Value += 0.02
+	for i := 150; i < 153; i++ {
+		ts.Data[i].Altitude.Value += 80
+	}
+
+	ts.Data[200].HeartRate = Optional[uint8]{Value: 0, Valid: true}
+	ts.Data[210].HeartRate.Value = 250
+	ts.Data[220].HeartRate.Value = 200
+
+	for i := 240; i < 280; i++ {
+		cadence := uint8(85 + i%4)
+		ts.Data[i].Cadence = Optional[uint8]{Value: cadence, Valid: true}
+		ts.Data[i].HeartRate.Value = 2 * cadence
+	}
+
+	duplicate := ts.Data[50]
+	duplicate.Cadence = Optional[uint8]{Value: 80, Valid: true}
+	ts.Data = append(ts.Data, duplicate)
+
+	return ts
+}
+
+func TestClean(t *testing.T) {
+	ts := noisyRun()
+
+	cleaned, report := ts.Clean(CleanConfig{})
+
+	require.Len(t, cleaned.Data, 301)
+	assert.Len(t, ts.Data, 302, "the input is not modified")
+
+	assert.Equal(t, 1, report.Count(CleanStepDuplicateOffsets))
+	assert.Equal(t, uint8(80), cleaned.Data[50].Cadence.Value, "missing channels are filled from duplicates")
+
+	assert.Equal(t, 1, report.Count(CleanStepGPSOutliers))
+	assert.False(t, cleaned.Data[100].HasGPS())
+
+	assert.Equal(t, 3, report.Count(CleanStepAltitudeSpikes))
+	assert.False(t, cleaned.Data[151].Altitude.Valid)
+	assert.True(t, cleaned.Data[153].Altitude.Valid)
+
+	assert.Equal(t, 2, report.Count(CleanStepHeartRateRange))
+	assert.Equal(t, 40, report.Count(CleanStepCadenceLock))
+	assert.Equal(t, 11, report.Count(CleanStepHeartRateSpikes), "leading artifact and single spike")
+	assert.False(t, cleaned.Data[0].HeartRate.Valid)
+	assert.False(t, cleaned.Data[220].HeartRate.Valid)
+
+	maxHR, err := CalculateMaxHeartRate(cleaned, MaxHeartRateAnalysisConfig{Method: MaxHeartRateMethodPeak})
+	require.NoError(t, err)
+	assert.Equal(t, 150, maxHR)
+
+	act := &Activity{}
+	AugmentGPXData(act, cleaned, AugmentConfig{})
+	assert.InDelta(t, 900, float64(act.Distance), 10)
+
+	t.Run("ExtraChannels", func(t *testing.T) {
+		ts := steadyRun(time.Date(2025, 5, 1, 6, 0, 0, 0, time.UTC), 10)
+		ts.ExtraChannels = []ExtraChannel{{Name: "smo2"}, {Name: "core_temperature"}}
+		ts.Data[5].Extra = map[string]float64{"smo2": 61}
+		ts.Data[6].Extra = map[string]float64{"smo2": 62}
+		ts.Data = append(ts.Data, ActivityTimeseriesEntry{Offset: 5, Extra: map[string]float64{"smo2": 70, "core_temperature": 38.2}})
+
+		cleaned, _ := ts.Clean(CleanConfig{})
+		require.Len(t, cleaned.Data, 11)
+		assert.Equal(t, map[string]float64{"smo2": 61, "core_temperature": 38.2}, cleaned.Data[5].Extra, "missing extra values are filled from duplicates")
+
+		cleaned.Data[6].Extra["smo2"] = 0
+		assert.Equal(t, map[string]float64{"smo2": 61}, ts.Data[5].Extra, "the input is not modified")
+		assert.Equal(t, 62.0, ts.Data[6].Extra["smo2"])
+	})
+}
+
+func TestCleanSteps(t *testing.T) {
+	ts := noisyRun()
+
+	cleaned, report := ts.Clean(CleanConfig{Steps: []CleanStep{CleanStepGPSOutliers}})
+
+	assert.Len(t, report.Changes, 1)
+	assert.Equal(t, CleanChange{Step: CleanStepGPSOutliers, Offset: 100, Channel: ChannelPosition}, report.Changes[0])
+	assert.True(t, cleaned.Data[0].HeartRate.Valid)
+
+	t.Run("SteadyCadenceIsNotALock", func(t *testing.T) {
+		steady := steadyRun(time.Now(), 120)
+		for i := range steady.Data {
+			steady.Data[i].Cadence = Optional[uint8]{Value: 85, Valid: true}
+			steady.Data[i].HeartRate.Value = 170
+		}
+
+		_, report := steady.Clean(CleanConfig{})
+		assert.Zero(t, report.Count(CleanStepCadenceLock))
+	})
+}
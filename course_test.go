@@ -0,0 +1,210 @@
+package stride_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/muktihari/fit/decoder"
+	"github.com/muktihari/fit/profile/filedef"
+	"github.com/muktihari/fit/profile/typedef"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tkrajina/gpxgo/gpx"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+// hillRoute goes 1 km north on the flat, turns right and climbs 100 m over 1 km east,
+// then descends 100 m over the next kilometer.
+func hillRoute(start time.Time) *ActivityTimeseries {
+	const metersPerDegree = 111195.0
+	ts := &ActivityTimeseries{StartTime: start}
+
+	lat, lon := 45.0, 7.0
+	for i := 0; i <= 300; i++ {
+		var alt float64
+		switch {
+		case i <= 100:
+			lat = 45.0 + float64(i)*10/metersPerDegree
+			alt = 500
+		case i <= 200:
+			lon = 7.0 + float64(i-100)*10/(metersPerDegree*math.Cos(45*math.Pi/180))
+			alt = 500 + float64(i-100)
+		default:
+			lon = 7.0 + float64(i-100)*10/(metersPerDegree*math.Cos(45*math.Pi/180))
+			alt = 600 - float64(i-200)
+		}
+
+		ts.Data = append(ts.Data, ActivityTimeseriesEntry{
+			Offset:    i * 3,
+			Latitude:  Optional[float64]{Value: lat, Valid: true},
+			Longitude: Optional[float64]{Value: lon, Valid: true},
+			Altitude:  Optional[float64]{Value: alt, Valid: true},
+		})
+	}
+
+	return ts
+}
+
+func TestNewCourse(t *testing.T) {
+	start := time.Date(2025, 8, 30, 6, 0, 0, 0, time.UTC)
+
+	course, err := NewCourse("Hill loop", SportTrailRunning, hillRoute(start), CourseConfig{
+		TargetPace: Optional[Pace]{Value: Pace{Minutes: 5}, Valid: true},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, course.Track, 301)
+	assert.InDelta(t, 3000, course.Track[300].DistanceM, 5)
+
+	assert.Equal(t, []CoursePoint{
+		{Type: CoursePointValley, Name: "Climb 1", Index: 100},
+		{Type: CoursePointRight, Name: "Right", Index: 100},
+		{Type: CoursePointSummit, Name: "Summit 1", Index: 200},
+	}, course.Points)
+
+	// 5:00/km on the flat, slower uphill and faster on the gentle descent
+	flat := course.Track[100].Elapsed.Value
+	uphill := course.Track[200].Elapsed.Value - flat
+	downhill := course.Track[300].Elapsed.Value - course.Track[200].Elapsed.Value
+	assert.InDelta(t, 300, flat.Seconds(), 2)
+	assert.Greater(t, uphill, 6*time.Minute)
+	assert.Less(t, downhill, 5*time.Minute)
+
+	t.Run("NoVirtualPartner", func(t *testing.T) {
+		course, err := NewCourse("Hill loop", SportTrailRunning, hillRoute(start), CourseConfig{})
+		require.NoError(t, err)
+		assert.False(t, course.Track[10].Elapsed.Valid)
+	})
+
+	t.Run("NotEnoughPositions", func(t *testing.T) {
+		_, err := NewCourse("Treadmill", SportRunning, &ActivityTimeseries{StartTime: start}, CourseConfig{})
+		assert.ErrorIs(t, err, ErrNotEnoughCoursePositions)
+	})
+}
+
+func TestCreateFITCourseFileInMemory(t *testing.T) {
+	start := time.Date(2025, 8, 30, 6, 0, 0, 0, time.UTC)
+	course, err := NewCourse("Hill loop", SportTrailRunning, hillRoute(start), CourseConfig{
+		TargetPace: Optional[Pace]{Value: Pace{Minutes: 5}, Valid: true},
+	})
+	require.NoError(t, err)
+
+	data, err := CreateFITCourseFileInMemory(course, FITExportConfig{})
+	require.NoError(t, err)
+
+	fit, err := decoder.New(bytes.NewReader(data)).Decode()
+	require.NoError(t, err)
+	file := filedef.NewCourse(fit.Messages...)
+
+	assert.Equal(t, typedef.FileCourse, file.FileId.Type)
+	assert.Equal(t, "Hill loop", file.Course.Name)
+	assert.Equal(t, typedef.SportRunning, file.Course.Sport)
+	assert.Equal(t, typedef.SubSportTrail, file.Course.SubSport)
+	require.Len(t, file.Records, 301)
+	assert.Equal(t, start, file.Records[0].Timestamp)
+	assert.Equal(t, start.Add(course.Track[300].Elapsed.Value), file.Records[300].Timestamp)
+	assert.InDelta(t, course.Track[300].DistanceM, file.Lap.TotalDistanceScaled(), 0.01)
+	require.Len(t, file.Events, 2)
+
+	require.Len(t, file.CoursePoints, 3)
+	assert.Equal(t, typedef.CoursePointValley, file.CoursePoints[0].Type)
+	assert.Equal(t, typedef.CoursePointRight, file.CoursePoints[1].Type)
+	assert.Equal(t, typedef.CoursePointSummit, file.CoursePoints[2].Type)
+	assert.Equal(t, "Summit 1", file.CoursePoints[2].Name)
+	assert.InDelta(t, 45.0089932, file.CoursePoints[2].PositionLatDegrees(), 1e-6)
+
+	t.Run("FromGPXRoute", func(t *testing.T) {
+		gpxData, err := CreateGPXCourseFileInMemory(course, GPXCourseRoute)
+		require.NoError(t, err)
+
+		// Routes without times still get increasing timestamps
+		parsed, err := ParseGPXCourse(gpxData, CourseConfig{})
+		require.NoError(t, err)
+
+		data, err := CreateFITCourseFileInMemory(parsed, FITExportConfig{})
+		require.NoError(t, err)
+
+		fit, err := decoder.New(bytes.NewReader(data)).Decode()
+		require.NoError(t, err)
+		file := filedef.NewCourse(fit.Messages...)
+
+		assert.Equal(t, typedef.SportRunning, file.Course.Sport)
+		require.Len(t, file.Records, 301)
+		assert.True(t, file.Records[1].Timestamp.After(file.Records[0].Timestamp))
+	})
+}
+
+func TestCreateGPXCourseFileInMemory(t *testing.T) {
+	start := time.Date(2025, 8, 30, 6, 0, 0, 0, time.UTC)
+	ts := hillRoute(start)
+
+	course, err := NewCourse("Hill loop", SportTrailRunning, ts, CourseConfig{})
+	require.NoError(t, err)
+
+	data, err := CreateGPXCourseFileInMemory(course, GPXCourseRoute)
+	require.NoError(t, err)
+
+	gpxFile, err := gpx.ParseBytes(data)
+	require.NoError(t, err)
+	require.Len(t, gpxFile.Routes, 1)
+	assert.Empty(t, gpxFile.Tracks)
+	assert.Equal(t, "Hill loop", gpxFile.Routes[0].Name)
+	require.Len(t, gpxFile.Routes[0].Points, 301)
+	assert.True(t, gpxFile.Routes[0].Points[0].Timestamp.IsZero())
+	require.Len(t, gpxFile.Waypoints, 3)
+	assert.Equal(t, "Summit 1", gpxFile.Waypoints[2].Name)
+	assert.Equal(t, "summit", gpxFile.Waypoints[2].Type)
+
+	parsed, err := ParseGPXCourse(data, CourseConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, SportTrailRunning, parsed.Sport)
+	assert.Equal(t, course.Points, parsed.Points)
+	assert.InDelta(t, course.Track[300].DistanceM, parsed.Track[300].DistanceM, 1)
+
+	t.Run("Track", func(t *testing.T) {
+		course, err := NewCourse("Hill loop", SportTrailRunning, ts, CourseConfig{
+			TargetPace: Optional[Pace]{Value: Pace{Minutes: 6}, Valid: true},
+		})
+		require.NoError(t, err)
+
+		data, err := CreateGPXCourseFileInMemory(course, GPXCourseTrack)
+		require.NoError(t, err)
+
+		gpxFile, err := gpx.ParseBytes(data)
+		require.NoError(t, err)
+		require.Len(t, gpxFile.Tracks, 1)
+		points := gpxFile.Tracks[0].Segments[0].Points
+		require.Len(t, points, 301)
+		assert.Equal(t, start, points[0].Timestamp)
+		assert.Equal(t, start.Add(course.Track[300].Elapsed.Value), points[300].Timestamp)
+
+		parsed, err := ParseGPXCourse(data, CourseConfig{})
+		require.NoError(t, err)
+		assert.Equal(t, start, parsed.StartTime, "the first timestamp starts the virtual partner")
+		assert.False(t, parsed.Track[300].Elapsed.Valid, "without a target pace")
+
+		_, err = CreateGPXCourseFileInMemory(course, "kml")
+		assert.ErrorIs(t, err, ErrUnknownGPXCourseFormat)
+	})
+}
+
+func TestCourseExportInvalidPoint(t *testing.T) {
+	course, err := NewCourse("Hill", SportRunning, hillRoute(time.Date(2025, 8, 30, 6, 0, 0, 0, time.UTC)), CourseConfig{})
+	require.NoError(t, err)
+	course.Points = append(course.Points, CoursePoint{Type: CoursePointGeneric, Name: "Water", Index: len(course.Track)})
+
+	_, err = CreateFITCourseFileInMemory(course, FITExportConfig{})
+	assert.ErrorIs(t, err, ErrInvalidCoursePoint)
+
+	_, err = CreateGPXCourseFileInMemory(course, GPXCourseRoute)
+	assert.ErrorIs(t, err, ErrInvalidCoursePoint)
+
+	_, err = CreateGeoJSONCourseFileInMemory(course)
+	assert.ErrorIs(t, err, ErrInvalidCoursePoint)
+
+	_, err = CreateKMLCourseFileInMemory(course)
+	assert.ErrorIs(t, err, ErrInvalidCoursePoint)
+}
@@ -0,0 +1,511 @@
+package stride
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFailedToParseCSV  = errors.New("failed to parse CSV")
+	ErrNoTimestampColumn = errors.New("no time or offset column")
+)
+
+// TimestampMode selects how rows are timed in exported files.
+type TimestampMode string
+
+const (
+	TimestampOffset   TimestampMode = "offset" // Seconds since the start of the timeseries
+	TimestampAbsolute TimestampMode = "time"   // RFC 3339 in UTC
+)
+
+// TableColumn names a column of a CSV or JSON Lines file. Channels use their own name,
+// except the position channel that is split in latitude and longitude.
+type TableColumn string
+
+const (
+	ColumnOffset      TableColumn = "offset"
+	ColumnTime        TableColumn = "time"
+	ColumnHeartRate   TableColumn = TableColumn(ChannelHeartRate)
+	ColumnCadence     TableColumn = TableColumn(ChannelCadence)
+	ColumnDistance    TableColumn = TableColumn(ChannelDistance)
+	ColumnAltitude    TableColumn = TableColumn(ChannelAltitude)
+	ColumnVelocity    TableColumn = TableColumn(ChannelVelocity)
+	ColumnLatitude    TableColumn = "latitude"
+	ColumnLongitude   TableColumn = "longitude"
+	ColumnPower       TableColumn = TableColumn(ChannelPower)
+	ColumnTemperature TableColumn = TableColumn(ChannelTemperature)
+	ColumnMoving      TableColumn = TableColumn(ChannelMoving)
+	ColumnGrade       TableColumn = TableColumn(ChannelGrade)
+)
+
+// allTableColumns lists every column, used to match CSV headers by name.
+var allTableColumns = []TableColumn{
+	ColumnOffset,
+	ColumnTime,
+	ColumnHeartRate,
+	ColumnCadence,
+	ColumnDistance,
+	ColumnAltitude,
+	ColumnVelocity,
+	ColumnLatitude,
+	ColumnLongitude,
+	ColumnPower,
+	ColumnTemperature,
+	ColumnMoving,
+	ColumnGrade,
+}
+
+type TableExportConfig struct {
+	Channels     []Channel     // Channels to write, in order. Default: the channels present in the timeseries, then its extra channels.
+	Timestamps   TimestampMode // Default TimestampOffset.
+	DistanceUnit DistanceUnit  // Default DistanceUnitMeters.
+	SpeedUnit    SpeedUnit     // Default SpeedUnitMetersPerSecond.
+}
+
+// ApplyDefaults fills the zero fields. Default channels depend on the timeseries, so
+// they are resolved when writing.
+func (c TableExportConfig) ApplyDefaults() TableExportConfig {
+	config := c
+	if config.Timestamps == "" {
+		config.Timestamps = TimestampOffset
+	}
+	if config.DistanceUnit == "" {
+		config.DistanceUnit = DistanceUnitMeters
+	}
+	if config.SpeedUnit == "" {
+		config.SpeedUnit = SpeedUnitMetersPerSecond
+	}
+	return config
+}
+
+// columns returns the columns written for the timeseries, the timestamp first.
+func (c TableExportConfig) columns(ts *ActivityTimeseries) []TableColumn {
+	columns := []TableColumn{TableColumn(c.Timestamps)}
+
+	channels := c.Channels
+	if len(channels) == 0 {
+		for _, ch := range AllChannels {
+			if hasChannel(ts.Data, ch) {
+				channels = append(channels, ch)
+			}
+		}
+	}
+
+	for _, ch := range channels {
+		if ch == ChannelPosition {
+			columns = append(columns, ColumnLatitude, ColumnLongitude)
+		} else {
+			columns = append(columns, TableColumn(ch))
+		}
+	}
+
+	if len(c.Channels) == 0 {
+		for _, ch := range ts.ExtraChannels {
+			column := TableColumn(ch.Name)
+			if !slices.Contains(allTableColumns, column) && !slices.Contains(columns, column) {
+				columns = append(columns, column)
+			}
+		}
+	}
+
+	return columns
+}
+
+// value returns the value of the column for the entry, false when the entry has none.
+func (c TableExportConfig) value(ts *ActivityTimeseries, entry ActivityTimeseriesEntry, column TableColumn) (any, bool) {
+	switch column {
+	case ColumnOffset:
+		return entry.Offset, true
+
+	case ColumnTime:
+		return ts.StartTime.Add(time.Duration(entry.Offset) * time.Second).UTC().Format(time.RFC3339), true
+
+	case ColumnHeartRate:
+		return int(entry.HeartRate.Value), entry.HeartRate.Valid
+
+	case ColumnCadence:
+		return int(entry.Cadence.Value), entry.Cadence.Valid
+
+	case ColumnDistance:
+		return c.DistanceUnit.From(entry.Distance.Value), entry.Distance.Valid
+
+	case ColumnAltitude:
+		return entry.Altitude.Value, entry.Altitude.Valid
+
+	case ColumnVelocity:
+		return c.SpeedUnit.From(entry.Velocity.Value), entry.Velocity.Valid
+
+	case ColumnLatitude:
+		return entry.Latitude.Value, entry.Latitude.Valid
+
+	case ColumnLongitude:
+		return entry.Longitude.Value, entry.Longitude.Valid
+
+	case ColumnPower:
+		return int(entry.Power.Value), entry.Power.Valid
+
+	case ColumnTemperature:
+		return int(entry.Temperature.Value), entry.Temperature.Valid
+
+	case ColumnMoving:
+		return entry.Moving.Value, entry.Moving.Valid
+
+	case ColumnGrade:
+		return entry.Grade.Value, entry.Grade.Valid
+
+	default:
+		value, ok := entry.Extra[string(column)]
+		return value, ok
+	}
+}
+
+// WriteCSV writes the timeseries as CSV with a header row. Missing values are empty cells.
+func WriteCSV(w io.Writer, ts *ActivityTimeseries, config TableExportConfig) error {
+	config = config.ApplyDefaults()
+	columns := config.columns(ts)
+
+	writer := csv.NewWriter(w)
+
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = string(column)
+	}
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+
+	for _, entry := range ts.Data {
+		for i, column := range columns {
+			record[i] = ""
+			if value, ok := config.value(ts, entry, column); ok {
+				record[i] = formatTableValue(value)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSONL writes one JSON object per entry, keys in column order. Missing values are
+// left out.
+func WriteJSONL(w io.Writer, ts *ActivityTimeseries, config TableExportConfig) error {
+	config = config.ApplyDefaults()
+	columns := config.columns(ts)
+
+	buf := bufio.NewWriter(w)
+	for _, entry := range ts.Data {
+		buf.WriteByte('{')
+		first := true
+		for _, column := range columns {
+			value, ok := config.value(ts, entry, column)
+			if !ok {
+				continue
+			}
+			if f, isFloat := value.(float64); isFloat && (math.IsNaN(f) || math.IsInf(f, 0)) {
+				continue // Not representable in JSON
+			}
+
+			key, err := json.Marshal(string(column)) // Extra channel names may need escaping
+			if err != nil {
+				return err
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(encoded)
+		}
+		buf.WriteString("}\n")
+	}
+
+	return buf.Flush()
+}
+
+func formatTableValue(value any) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type CSVImportConfig struct {
+	Columns      map[string]TableColumn // CSV header to column. Default: headers named like a column, as written by WriteCSV, and the other numeric headers as extra channels. Unmapped headers are ignored.
+	TimeLayout   string                 // Layout of the time column. Default time.RFC3339.
+	StartTime    time.Time              // Start of files timed by an offset column only.
+	DistanceUnit DistanceUnit           // Default DistanceUnitMeters.
+	SpeedUnit    SpeedUnit              // Default SpeedUnitMetersPerSecond.
+	Comma        rune                   // Field delimiter. Default ','.
+	Sport        Sport                  // Default SportUnknown.
+	Augment      AugmentConfig
+}
+
+func (c CSVImportConfig) ApplyDefaults() CSVImportConfig {
+	config := c
+	if config.TimeLayout == "" {
+		config.TimeLayout = time.RFC3339
+	}
+	if config.DistanceUnit == "" {
+		config.DistanceUnit = DistanceUnitMeters
+	}
+	if config.SpeedUnit == "" {
+		config.SpeedUnit = SpeedUnitMetersPerSecond
+	}
+	if config.Comma == 0 {
+		config.Comma = ','
+	}
+	if config.Sport == "" {
+		config.Sport = SportUnknown
+	}
+	return config
+}
+
+// ReadCSV reads a timeseries from a CSV file with a header row, and computes its
+// activity summary. Rows are timed by an absolute time column, or by an offset column in
+// seconds since StartTime. Empty cells are missing values.
+//
+// Like a GPX file, a file with positions and no distance goes through AugmentGPXData,
+// which derives distance and speed from the positions. Files with a distance column, as
+// from treadmills, keep it and are summarized with RecomputeSummary.
+func ReadCSV(r io.Reader, config CSVImportConfig) (*Activity, *ActivityTimeseries, error) {
+	config = config.ApplyDefaults()
+
+	reader := csv.NewReader(r)
+	reader.Comma = config.Comma
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: header: %w", ErrFailedToParseCSV, err)
+	}
+	header = slices.Clone(header) // Overwritten by the next Read
+
+	columns := make([]TableColumn, len(header))
+	for i, name := range header {
+		columns[i] = config.column(name, i == 0)
+	}
+
+	// Without a mapping, the other headers are extra channels unless a cell is not a number
+	var extras []csvExtraColumn
+	if config.Columns == nil {
+		for i, name := range header {
+			name = csvHeaderName(name, i == 0)
+			if columns[i] == "" && name != "" && !slices.ContainsFunc(extras, func(e csvExtraColumn) bool { return e.name == name }) {
+				extras = append(extras, csvExtraColumn{index: i, name: name, numeric: true})
+			}
+		}
+	}
+
+	timeIndex := slices.Index(columns, ColumnTime)
+	offsetIndex := slices.Index(columns, ColumnOffset)
+	if timeIndex < 0 && offsetIndex < 0 {
+		return nil, nil, ErrNoTimestampColumn
+	}
+
+	ts := &ActivityTimeseries{StartTime: config.StartTime}
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrFailedToParseCSV, err)
+		}
+
+		var entry ActivityTimeseriesEntry
+		if timeIndex >= 0 {
+			timestamp, err := time.Parse(config.TimeLayout, strings.TrimSpace(record[timeIndex]))
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: row %d: %w", ErrFailedToParseCSV, row, err)
+			}
+			if len(ts.Data) == 0 {
+				ts.StartTime = timestamp.UTC()
+			}
+			entry.Offset = int(math.Round(timestamp.Sub(ts.StartTime).Seconds()))
+		}
+
+		for i, column := range columns {
+			if column == "" || column == ColumnTime || (column == ColumnOffset && timeIndex >= 0) {
+				continue
+			}
+			if err := config.setValue(&entry, column, strings.TrimSpace(record[i])); err != nil {
+				return nil, nil, fmt.Errorf("%w: row %d, column %q: %w", ErrFailedToParseCSV, row, header[i], err)
+			}
+		}
+
+		for i := range extras {
+			extras[i].read(&entry, strings.TrimSpace(record[extras[i].index]))
+		}
+
+		ts.Data = append(ts.Data, entry)
+	}
+
+	for _, extra := range extras {
+		if extra.numeric && extra.seen {
+			ts.ExtraChannels = append(ts.ExtraChannels, ExtraChannel{Name: extra.name})
+			continue
+		}
+		for i := range ts.Data {
+			delete(ts.Data[i].Extra, extra.name)
+			if len(ts.Data[i].Extra) == 0 {
+				ts.Data[i].Extra = nil
+			}
+		}
+	}
+
+	if len(ts.Data) == 0 {
+		return nil, nil, fmt.Errorf("%w: no rows", ErrFailedToParseCSV)
+	}
+
+	slices.SortStableFunc(ts.Data, func(a, b ActivityTimeseriesEntry) int { return a.Offset - b.Offset })
+
+	act := &Activity{Sport: config.Sport}
+	if hasChannel(ts.Data, ChannelPosition) && !hasChannel(ts.Data, ChannelDistance) {
+		act.StartTime = ts.StartTime
+		act.ElapsedTime = uint32(ts.MaxOffset() - ts.Data[0].Offset)
+		AugmentGPXData(act, ts, config.Augment)
+		return act, ts, nil
+	}
+
+	if err := RecomputeSummary(act, ts, config.Augment); err != nil {
+		return nil, nil, err
+	}
+
+	return act, ts, nil
+}
+
+// column maps a CSV header to a column, empty when the column is ignored.
+func (c CSVImportConfig) column(name string, first bool) TableColumn {
+	name = csvHeaderName(name, first)
+
+	if c.Columns != nil {
+		return c.Columns[name]
+	}
+
+	for _, column := range allTableColumns {
+		if strings.EqualFold(name, string(column)) {
+			return column
+		}
+	}
+	return ""
+}
+
+func csvHeaderName(name string, first bool) string {
+	if first {
+		name = strings.TrimPrefix(name, "\ufeff") // Byte order mark from spreadsheet exports
+	}
+	return strings.TrimSpace(name)
+}
+
+// csvExtraColumn is an unmapped CSV column read as an extra channel.
+type csvExtraColumn struct {
+	index   int
+	name    string
+	numeric bool // No cell failed to parse as a number
+	seen    bool // At least one cell had a value
+}
+
+func (e *csvExtraColumn) read(entry *ActivityTimeseriesEntry, cell string) {
+	if cell == "" || !e.numeric {
+		return
+	}
+
+	value, err := strconv.ParseFloat(cell, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		e.numeric = false
+		return
+	}
+
+	if entry.Extra == nil {
+		entry.Extra = make(map[string]float64)
+	}
+	entry.Extra[e.name] = value
+	e.seen = true
+}
+
+// setValue parses a cell into the entry. Empty cells leave the value missing.
+func (c CSVImportConfig) setValue(entry *ActivityTimeseriesEntry, column TableColumn, cell string) error {
+	if cell == "" {
+		if column == ColumnOffset {
+			return errors.New("missing offset")
+		}
+		return nil
+	}
+
+	if column == ColumnMoving {
+		moving, err := strconv.ParseBool(cell)
+		if err != nil {
+			return err
+		}
+		entry.Moving = Optional[bool]{Value: moving, Valid: true}
+		return nil
+	}
+
+	value, err := strconv.ParseFloat(cell, 64)
+	if err != nil {
+		return err
+	}
+	if math.IsNaN(value) {
+		return nil
+	}
+
+	switch column {
+	case ColumnOffset:
+		entry.Offset = int(math.Round(value))
+
+	case ColumnHeartRate:
+		entry.HeartRate = Optional[uint8]{Value: uint8(min(max(math.Round(value), 0), math.MaxUint8)), Valid: true}
+
+	case ColumnCadence:
+		entry.Cadence = Optional[uint8]{Value: uint8(min(max(math.Round(value), 0), math.MaxUint8)), Valid: true}
+
+	case ColumnDistance:
+		entry.Distance = Optional[Distance]{Value: c.DistanceUnit.To(value), Valid: true}
+
+	case ColumnAltitude:
+		entry.Altitude = Optional[float64]{Value: value, Valid: true}
+
+	case ColumnVelocity:
+		entry.Velocity = Optional[Speed]{Value: c.SpeedUnit.To(value), Valid: true}
+
+	case ColumnLatitude:
+		entry.Latitude = Optional[float64]{Value: value, Valid: true}
+
+	case ColumnLongitude:
+		entry.Longitude = Optional[float64]{Value: value, Valid: true}
+
+	case ColumnPower:
+		entry.Power = Optional[uint16]{Value: uint16(min(max(math.Round(value), 0), math.MaxUint16)), Valid: true}
+
+	case ColumnTemperature:
+		entry.Temperature = Optional[int8]{Value: int8(min(max(math.Round(value), math.MinInt8), math.MaxInt8)), Valid: true}
+
+	case ColumnGrade:
+		entry.Grade = Optional[float64]{Value: value, Valid: true}
+	}
+
+	return nil
+}
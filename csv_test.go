@@ -0,0 +1,189 @@
+package stride_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	start := time.Date(2025, 11, 1, 8, 0, 0, 0, time.UTC)
+	ts := steadyRun(start, 600)
+	for i := range ts.Data {
+		ts.Data[i].Moving = Optional[bool]{Value: i%100 != 0, Valid: true}
+		if i%50 == 0 {
+			ts.Data[i].HeartRate = Optional[uint8]{}
+		}
+	}
+
+	for _, mode := range []TimestampMode{TimestampOffset, TimestampAbsolute} {
+		var buf bytes.Buffer
+		require.NoError(t, WriteCSV(&buf, ts, TableExportConfig{Timestamps: mode}))
+
+		header, _, _ := strings.Cut(buf.String(), "\n")
+		assert.Equal(t, string(mode)+",heart_rate,distance,altitude,latitude,longitude,moving", header)
+
+		act, parsed, err := ReadCSV(&buf, CSVImportConfig{StartTime: start, Sport: SportRunning})
+		require.NoError(t, err)
+
+		assert.Equal(t, ts.Data, parsed.Data)
+		assert.True(t, start.Equal(parsed.StartTime))
+		assert.Equal(t, SportRunning, act.Sport)
+		assert.Equal(t, Distance(1800), act.Distance)
+		assert.Equal(t, uint32(600), act.ElapsedTime)
+	}
+}
+
+func TestWriteCSVUnits(t *testing.T) {
+	ts := steadyRun(time.Date(2025, 11, 1, 8, 0, 0, 0, time.UTC), 2)
+	ts.Data[1].Velocity = Optional[Speed]{Value: SpeedFromKilometersPerHour(18), Valid: true}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, ts, TableExportConfig{
+		Channels:     []Channel{ChannelDistance, ChannelVelocity},
+		Timestamps:   TimestampAbsolute,
+		DistanceUnit: DistanceUnitKilometers,
+		SpeedUnit:    SpeedUnitKilometersPerHour,
+	}))
+
+	assert.Equal(t, "time,distance,velocity\n"+
+		"2025-11-01T08:00:00Z,0,\n"+
+		"2025-11-01T08:00:01Z,0.003,18\n"+
+		"2025-11-01T08:00:02Z,0.006,\n", buf.String())
+}
+
+func TestWriteCSVExtraChannels(t *testing.T) {
+	ts := steadyRun(time.Date(2025, 11, 1, 8, 0, 0, 0, time.UTC), 2)
+	ts.ExtraChannels = []ExtraChannel{{Name: "form_power", Units: "watts"}, {Name: "heart_rate", Units: "bpm"}}
+	ts.Data[0].Extra = map[string]float64{"form_power": 212.5}
+	ts.Data[2].Extra = map[string]float64{"form_power": 215, "heart_rate": 90}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, ts, TableExportConfig{}))
+
+	assert.Equal(t, "offset,heart_rate,distance,altitude,latitude,longitude,form_power\n"+
+		"0,150,0,100,45,7,212.5\n"+
+		"1,150,3,100.1,45.000027,7,\n"+
+		"2,150,6,100.2,45.000054,7,215\n", buf.String(), "extra channels named like a standard column are left out")
+
+	_, parsed, err := ReadCSV(&buf, CSVImportConfig{StartTime: ts.StartTime})
+	require.NoError(t, err)
+	assert.Equal(t, []ExtraChannel{{Name: "form_power"}}, parsed.ExtraChannels)
+	assert.Equal(t, map[string]float64{"form_power": 212.5}, parsed.Data[0].Extra)
+	assert.Nil(t, parsed.Data[1].Extra)
+	assert.Equal(t, map[string]float64{"form_power": 215}, parsed.Data[2].Extra)
+
+	t.Run("NonNumericColumnsIgnored", func(t *testing.T) {
+		_, parsed, err := ReadCSV(strings.NewReader("offset,smo2,notes,empty\n0,61.5,easy,\n1,62,1,\n"), CSVImportConfig{})
+		require.NoError(t, err)
+		assert.Equal(t, []ExtraChannel{{Name: "smo2"}}, parsed.ExtraChannels)
+		assert.Equal(t, map[string]float64{"smo2": 62}, parsed.Data[1].Extra)
+	})
+
+	t.Run("JSONLKeys", func(t *testing.T) {
+		ts := &ActivityTimeseries{
+			ExtraChannels: []ExtraChannel{{Name: "bad\x7f\x01\"name"}},
+			Data:          []ActivityTimeseriesEntry{{Offset: 0, Extra: map[string]float64{"bad\x7f\x01\"name": 1}}},
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteJSONL(&buf, ts, TableExportConfig{}))
+
+		var row map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &row))
+		assert.Equal(t, 1.0, row["bad\x7f\x01\"name"])
+	})
+}
+
+func TestWriteJSONL(t *testing.T) {
+	ts := steadyRun(time.Date(2025, 11, 1, 8, 0, 0, 0, time.UTC), 10)
+	ts.Data[3].HeartRate = Optional[uint8]{}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteJSONL(&buf, ts, TableExportConfig{Channels: []Channel{ChannelHeartRate, ChannelPosition}}))
+
+	scanner := bufio.NewScanner(&buf)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.Len(t, lines, 11)
+	assert.Equal(t, `{"offset":0,"heart_rate":150,"latitude":45,"longitude":7}`, lines[0])
+
+	var row map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[3]), &row))
+	assert.NotContains(t, row, "heart_rate")
+	assert.InDelta(t, 45.000081, row["latitude"], 1e-9)
+}
+
+func TestReadCSVColumnMapping(t *testing.T) {
+	// Treadmill export: semicolon separated, custom headers, no GPS
+	data := "\ufeffElapsed (s);HR;Speed (km/h);Dist (km);Notes\n" +
+		"0;95;0;0;warmup\n" +
+		"1;96;10.8;0.003;\n" +
+		"2;;10.8;0.006;\n" +
+		"3;100;10.8;0.009;\n"
+
+	start := time.Date(2025, 11, 2, 18, 0, 0, 0, time.UTC)
+	act, ts, err := ReadCSV(strings.NewReader(data), CSVImportConfig{
+		Columns: map[string]TableColumn{
+			"Elapsed (s)":  ColumnOffset,
+			"HR":           ColumnHeartRate,
+			"Speed (km/h)": ColumnVelocity,
+			"Dist (km)":    ColumnDistance,
+		},
+		StartTime:    start,
+		DistanceUnit: DistanceUnitKilometers,
+		SpeedUnit:    SpeedUnitKilometersPerHour,
+		Comma:        ';',
+		Sport:        SportRunning,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, ts.Data, 4)
+	assert.Equal(t, start, ts.StartTime)
+	assert.Equal(t, Optional[Distance]{Value: 9, Valid: true}, ts.Data[3].Distance)
+	assert.Equal(t, Optional[Speed]{Value: 3000, Valid: true}, ts.Data[1].Velocity)
+	assert.False(t, ts.Data[2].HeartRate.Valid)
+
+	assert.Equal(t, Distance(9), act.Distance, "treadmill distance is kept")
+	assert.Equal(t, uint32(3), act.MovingTime)
+	assert.Equal(t, start, act.StartTime)
+
+	t.Run("GPS", func(t *testing.T) {
+		data := "time,lat,lon,ele\n" +
+			"2025-11-02T18:00:00Z,45.0,7.0,100\n" +
+			"2025-11-02T18:00:10Z,45.0003,7.0,101\n"
+
+		act, ts, err := ReadCSV(strings.NewReader(data), CSVImportConfig{
+			Columns: map[string]TableColumn{"time": ColumnTime, "lat": ColumnLatitude, "lon": ColumnLongitude, "ele": ColumnAltitude},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 10, ts.Data[1].Offset)
+		assert.Equal(t, SportUnknown, act.Sport)
+		assert.Equal(t, uint32(10), act.ElapsedTime)
+		assert.InDelta(t, 33, float64(act.Distance), 1, "derived from positions")
+		assert.True(t, ts.Data[1].Distance.Valid)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, _, err := ReadCSV(strings.NewReader("hr,power\n150,200\n"), CSVImportConfig{})
+		assert.ErrorIs(t, err, ErrNoTimestampColumn)
+
+		_, _, err = ReadCSV(strings.NewReader("offset,heart_rate\n0,abc\n"), CSVImportConfig{})
+		assert.ErrorIs(t, err, ErrFailedToParseCSV)
+		assert.ErrorContains(t, err, `row 1, column "heart_rate"`)
+
+		_, _, err = ReadCSV(strings.NewReader("offset,heart_rate\n"), CSVImportConfig{})
+		assert.ErrorIs(t, err, ErrFailedToParseCSV)
+	})
+}
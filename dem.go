@@ -0,0 +1,219 @@
+package stride
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	ErrElevationNotFound = errors.New("no terrain elevation for location")
+	ErrInvalidHGTTile    = errors.New("invalid hgt tile")
+)
+
+// hgtVoid marks missing samples in SRTM tiles.
+const hgtVoid = -32768
+
+// ElevationSource looks up the terrain elevation (meters) of a location. Implementations
+// return ErrElevationNotFound when they have no data for it.
+type ElevationSource interface {
+	Elevation(lat, lon float64) (float64, error)
+}
+
+// HGTSource reads SRTM .hgt tiles from a local directory. Tiles are named after their
+// south-west corner (e.g. N45E007.hgt) and loaded on first use. Both SRTM1 (3601x3601)
+// and SRTM3 (1201x1201) tiles are supported.
+type HGTSource struct {
+	dir string
+
+	mu    sync.Mutex
+	tiles map[string]*hgtTile // nil entries remember missing tiles
+}
+
+type hgtTile struct {
+	size    int // samples per side
+	samples []int16
+}
+
+func NewHGTSource(dir string) *HGTSource {
+	return &HGTSource{
+		dir:   dir,
+		tiles: make(map[string]*hgtTile),
+	}
+}
+
+// Elevation returns the terrain elevation at the location, interpolated bilinearly
+// between the four surrounding samples. Void samples are ignored.
+func (s *HGTSource) Elevation(lat, lon float64) (float64, error) {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, fmt.Errorf("%w: %f, %f", ErrElevationNotFound, lat, lon)
+	}
+
+	tileLat := int(math.Floor(lat))
+	tileLon := int(math.Floor(lon))
+
+	tile, err := s.tile(tileLat, tileLon)
+	if err != nil {
+		return 0, err
+	}
+
+	// Rows run from north to south, columns from west to east
+	row := (float64(tileLat+1) - lat) * float64(tile.size-1)
+	col := (lon - float64(tileLon)) * float64(tile.size-1)
+
+	r0 := min(int(math.Floor(row)), tile.size-2)
+	c0 := min(int(math.Floor(col)), tile.size-2)
+	fr := row - float64(r0)
+	fc := col - float64(c0)
+
+	var sum, weights float64
+	for _, corner := range []struct {
+		r, c   int
+		weight float64
+	}{
+		{r0, c0, (1 - fr) * (1 - fc)},
+		{r0, c0 + 1, (1 - fr) * fc},
+		{r0 + 1, c0, fr * (1 - fc)},
+		{r0 + 1, c0 + 1, fr * fc},
+	} {
+		value := tile.samples[corner.r*tile.size+corner.c]
+		if value == hgtVoid {
+			continue
+		}
+		sum += float64(value) * corner.weight
+		weights += corner.weight
+	}
+
+	if weights == 0 {
+		return 0, fmt.Errorf("%w: void at %f, %f", ErrElevationNotFound, lat, lon)
+	}
+
+	return sum / weights, nil
+}
+
+func (s *HGTSource) tile(lat, lon int) (*hgtTile, error) {
+	name := hgtTileName(lat, lon)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tile, ok := s.tiles[name]; ok {
+		if tile == nil {
+			return nil, fmt.Errorf("%w: missing tile %s", ErrElevationNotFound, name)
+		}
+		return tile, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		s.tiles[name] = nil
+		return nil, fmt.Errorf("%w: missing tile %s", ErrElevationNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tile, err := parseHGT(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	s.tiles[name] = tile
+	return tile, nil
+}
+
+// hgtTileName returns the SRTM file name of the tile whose south-west corner is lat, lon.
+func hgtTileName(lat, lon int) string {
+	ns, ew := 'N', 'E'
+	if lat < 0 {
+		ns = 'S'
+		lat = -lat
+	}
+	if lon < 0 {
+		ew = 'W'
+		lon = -lon
+	}
+	return fmt.Sprintf("%c%02d%c%03d.hgt", ns, lat, ew, lon)
+}
+
+// parseHGT decodes a square grid of big-endian signed 16-bit samples.
+func parseHGT(data []byte) (*hgtTile, error) {
+	count := len(data) / 2
+	size := int(math.Round(math.Sqrt(float64(count))))
+	if len(data)%2 != 0 || size < 2 || size*size != count {
+		return nil, fmt.Errorf("%w: %d bytes is not a square grid", ErrInvalidHGTTile, len(data))
+	}
+
+	samples := make([]int16, count)
+	for i := range samples {
+		samples[i] = int16(binary.BigEndian.Uint16(data[2*i:]))
+	}
+
+	return &hgtTile{size: size, samples: samples}, nil
+}
+
+type ElevationCorrectionMode int
+
+const (
+	ElevationCorrectionReplace ElevationCorrectionMode = iota // Use the terrain elevation
+	ElevationCorrectionBlend                                  // Weighted mix of terrain and recorded altitude
+)
+
+type ElevationCorrectionConfig struct {
+	Mode      ElevationCorrectionMode
+	DEMWeight float64 // Weight of the terrain elevation in blend mode, 0-1 (default: 0.7)
+	Augment   AugmentConfig
+}
+
+func (c ElevationCorrectionConfig) ApplyDefaults() ElevationCorrectionConfig {
+	config := c
+	if config.DEMWeight == 0 {
+		config.DEMWeight = 0.7
+	}
+	config.Augment = config.Augment.ApplyDefaults()
+	return config
+}
+
+// CorrectElevation replaces or blends the Altitude channel with the terrain elevation
+// under each GPS sample and recomputes the elevation gain and loss of the activity.
+// Samples without GPS, or outside the coverage of the source, keep their altitude.
+// It returns how many samples were corrected.
+func CorrectElevation(act *Activity, ts *ActivityTimeseries, source ElevationSource, config ElevationCorrectionConfig) (int, error) {
+	config = config.ApplyDefaults()
+
+	var corrected int
+	for i := range ts.Data {
+		entry := &ts.Data[i]
+		if !entry.HasGPS() {
+			continue
+		}
+
+		dem, err := source.Elevation(entry.Latitude.Value, entry.Longitude.Value)
+		if errors.Is(err, ErrElevationNotFound) {
+			continue
+		}
+		if err != nil {
+			return corrected, err
+		}
+
+		altitude := dem
+		if config.Mode == ElevationCorrectionBlend && entry.Altitude.Valid {
+			altitude = config.DEMWeight*dem + (1-config.DEMWeight)*entry.Altitude.Value
+		}
+
+		entry.Altitude = Optional[float64]{Value: altitude, Valid: true}
+		corrected++
+	}
+
+	if hasChannel(ts.Data, ChannelAltitude) {
+		totalGain, totalLoss := elevationGainLoss(ts.Data, config.Augment.ElevationHysteresisM)
+		act.ElevationGain = Optional[uint16]{Value: uint16(totalGain), Valid: true}
+		act.ElevationLoss = Optional[uint16]{Value: uint16(totalLoss), Valid: true}
+	}
+
+	return corrected, nil
+}
@@ -0,0 +1,87 @@
+package stride_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+// writeHGTTile writes an 11x11 tile whose elevation rises 100 m per row towards north
+// and 10 m per column towards east.
+func writeHGTTile(t *testing.T, dir, name string) {
+	const size = 11
+
+	data := make([]byte, size*size*2)
+	for row := 0; row < size; row++ {
+		for col := 0; col < size; col++ {
+			value := int16(2000 + 10*col - 100*row)
+			binary.BigEndian.PutUint16(data[2*(row*size+col):], uint16(value))
+		}
+	}
+	// Void in the south-east corner
+	binary.BigEndian.PutUint16(data[2*(size*size-1):], 0x8000)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o644))
+}
+
+func TestHGTSource(t *testing.T) {
+	dir := t.TempDir()
+	writeHGTTile(t, dir, "N45E007.hgt")
+
+	source := NewHGTSource(dir)
+
+	elevation, err := source.Elevation(45.55, 7.25)
+	require.NoError(t, err)
+	assert.InDelta(t, 2000+25-450, elevation, 1e-6)
+
+	elevation, err = source.Elevation(45.05, 7.95)
+	require.NoError(t, err)
+	assert.InDelta(t, (1190+1200+1090)/3.0, elevation, 1e-6, "void corners are skipped")
+
+	_, err = source.Elevation(-12.5, 7.5)
+	assert.ErrorIs(t, err, ErrElevationNotFound)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "N46E007.hgt"), []byte{1, 2, 3}, 0o644))
+	_, err = source.Elevation(46.5, 7.5)
+	assert.ErrorIs(t, err, ErrInvalidHGTTile)
+}
+
+func TestCorrectElevation(t *testing.T) {
+	dir := t.TempDir()
+	writeHGTTile(t, dir, "N45E007.hgt")
+	source := NewHGTSource(dir)
+
+	ts := steadyRun(time.Date(2025, 7, 1, 6, 0, 0, 0, time.UTC), 3600)
+	ts.Data = append(ts.Data, ActivityTimeseriesEntry{
+		Offset:    3601,
+		Altitude:  Optional[float64]{Value: 500, Valid: true},
+		Latitude:  Optional[float64]{Value: 10, Valid: true},
+		Longitude: Optional[float64]{Value: 10, Valid: true},
+	})
+	act := &Activity{}
+
+	corrected, err := CorrectElevation(act, ts, source, ElevationCorrectionConfig{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3601, corrected, "samples outside the tiles are kept")
+	assert.InDelta(t, 1000, ts.Data[0].Altitude.Value, 1e-6)
+	assert.Equal(t, 500.0, ts.Data[3601].Altitude.Value)
+	assert.InDelta(t, 97, float64(act.ElevationGain.Value), 3)
+
+	t.Run("Blend", func(t *testing.T) {
+		ts := steadyRun(time.Now(), 0)
+		_, err := CorrectElevation(&Activity{}, ts, source, ElevationCorrectionConfig{
+			Mode:      ElevationCorrectionBlend,
+			DEMWeight: 0.5,
+		})
+		require.NoError(t, err)
+		assert.InDelta(t, 550, ts.Data[0].Altitude.Value, 1e-6)
+	})
+}
@@ -0,0 +1,310 @@
+package stride
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrInvalidCropRange   = errors.New("invalid crop range")
+	ErrInvalidSplitOffset = errors.New("split offset must fall inside the activity")
+	ErrOverlappingConcat  = errors.New("cannot concatenate overlapping activities")
+)
+
+// Crop returns the part of the timeseries between start and end, both offsets from
+// StartTime and both inclusive. The result is rebased: its StartTime is moved to the
+// first kept sample and offsets restart from zero.
+func (ts *ActivityTimeseries) Crop(start, end time.Duration) (*ActivityTimeseries, error) {
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidCropRange, start, end)
+	}
+
+	startOffset := int(start.Seconds())
+	endOffset := int(end.Seconds())
+
+	var kept []ActivityTimeseriesEntry
+	for _, entry := range ts.Data {
+		if entry.Offset >= startOffset && entry.Offset <= endOffset {
+			kept = append(kept, entry)
+		}
+	}
+
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("%w: no samples between %s and %s", ErrInvalidCropRange, start, end)
+	}
+
+	return rebaseTimeseries(ts.StartTime, kept, ts.Pauses, ts.ExtraChannels), nil
+}
+
+// SplitAt cuts the timeseries in two at the given offset. Samples before the offset go
+// to the first part, the rest to the second, and both are rebased to start at zero.
+func (ts *ActivityTimeseries) SplitAt(offset time.Duration) (*ActivityTimeseries, *ActivityTimeseries, error) {
+	splitOffset := int(offset.Seconds())
+
+	var before, after []ActivityTimeseriesEntry
+	for _, entry := range ts.Data {
+		if entry.Offset < splitOffset {
+			before = append(before, entry)
+		} else {
+			after = append(after, entry)
+		}
+	}
+
+	if len(before) == 0 || len(after) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidSplitOffset, offset)
+	}
+
+	return rebaseTimeseries(ts.StartTime, before, ts.Pauses, ts.ExtraChannels), rebaseTimeseries(ts.StartTime, after, ts.Pauses, ts.ExtraChannels), nil
+}
+
+// Concat joins two timeseries of the same activity. Offsets of b are rebased onto the
+// StartTime of a, so the wall-clock time between the two recordings is kept as a gap.
+// Pauses of both parts are kept; the gap itself is left to DetectPauses.
+// b must start after a ends.
+func Concat(a, b *ActivityTimeseries) (*ActivityTimeseries, error) {
+	if len(a.Data) == 0 {
+		return rebaseTimeseries(b.StartTime, b.Data, b.Pauses, mergeExtraChannels(a.ExtraChannels, b.ExtraChannels)), nil
+	}
+	if len(b.Data) == 0 {
+		return rebaseTimeseries(a.StartTime, a.Data, a.Pauses, mergeExtraChannels(a.ExtraChannels, b.ExtraChannels)), nil
+	}
+
+	if !b.StartTime.Add(time.Duration(b.Data[0].Offset) * time.Second).After(a.EndTime()) {
+		return nil, ErrOverlappingConcat
+	}
+
+	shift := int(b.StartTime.Sub(a.StartTime).Seconds())
+
+	data := make([]ActivityTimeseriesEntry, 0, len(a.Data)+len(b.Data))
+	data = append(data, a.Data...)
+
+	// Distance restarts at zero on the second recording. Carry over the distance of the
+	// first one, plus the straight line between the two when both have GPS.
+	var distanceBase Distance
+	var lastPosition *ActivityTimeseriesEntry
+	for i, entry := range a.Data {
+		if entry.Distance.Valid && entry.Distance.Value > distanceBase {
+			distanceBase = entry.Distance.Value
+		}
+		if entry.HasGPS() {
+			lastPosition = &a.Data[i]
+		}
+	}
+
+	if lastPosition != nil {
+		for _, entry := range b.Data {
+			if entry.HasGPS() {
+				bridge := haversine(lastPosition.Latitude.Value, lastPosition.Longitude.Value, entry.Latitude.Value, entry.Longitude.Value)
+				distanceBase += DistanceFromMeters(bridge)
+				break
+			}
+		}
+	}
+
+	for _, entry := range b.Data {
+		entry.Offset += shift
+		if entry.Distance.Valid {
+			entry.Distance.Value += distanceBase
+		}
+		data = append(data, entry)
+	}
+
+	pauses := append([]Pause(nil), a.Pauses...)
+	pauses = append(pauses, clipPauses(b.Pauses, shift, math.MinInt, math.MaxInt)...)
+
+	return rebaseTimeseries(a.StartTime, data, pauses, mergeExtraChannels(a.ExtraChannels, b.ExtraChannels)), nil
+}
+
+// CropActivity crops the timeseries and recomputes the activity summary from the result.
+func CropActivity(act *Activity, ts *ActivityTimeseries, start, end time.Duration, config AugmentConfig) (*Activity, *ActivityTimeseries, error) {
+	cropped, err := ts.Crop(start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shift := cropShift(ts, cropped)
+	laps := shiftLaps(act.Laps, -shift, 0, uint32(cropped.MaxOffset()))
+	sessions := shiftSessions(act.Sessions, -shift, 0, uint32(cropped.MaxOffset()))
+
+	result, err := summarizeEdit(act, cropped, laps, sessions, config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, cropped, nil
+}
+
+// SplitActivity splits the timeseries and recomputes both activity summaries.
+func SplitActivity(act *Activity, ts *ActivityTimeseries, offset time.Duration, config AugmentConfig) ([2]*Activity, [2]*ActivityTimeseries, error) {
+	first, second, err := ts.SplitAt(offset)
+	if err != nil {
+		return [2]*Activity{}, [2]*ActivityTimeseries{}, err
+	}
+
+	firstLaps := shiftLaps(act.Laps, -cropShift(ts, first), 0, uint32(first.MaxOffset()))
+	firstSessions := shiftSessions(act.Sessions, -cropShift(ts, first), 0, uint32(first.MaxOffset()))
+	firstAct, err := summarizeEdit(act, first, firstLaps, firstSessions, config)
+	if err != nil {
+		return [2]*Activity{}, [2]*ActivityTimeseries{}, err
+	}
+
+	secondLaps := shiftLaps(act.Laps, -cropShift(ts, second), 0, uint32(second.MaxOffset()))
+	secondSessions := shiftSessions(act.Sessions, -cropShift(ts, second), 0, uint32(second.MaxOffset()))
+	secondAct, err := summarizeEdit(act, second, secondLaps, secondSessions, config)
+	if err != nil {
+		return [2]*Activity{}, [2]*ActivityTimeseries{}, err
+	}
+
+	return [2]*Activity{firstAct, secondAct}, [2]*ActivityTimeseries{first, second}, nil
+}
+
+// ConcatActivities joins two recordings and recomputes the summary of the result. The
+// provider and sport of the first activity are kept.
+func ConcatActivities(actA *Activity, tsA *ActivityTimeseries, actB *Activity, tsB *ActivityTimeseries, config AugmentConfig) (*Activity, *ActivityTimeseries, error) {
+	joined, err := Concat(tsA, tsB)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	laps := shiftLaps(actA.Laps, cropShift(joined, tsA), 0, uint32(joined.MaxOffset()))
+	laps = append(laps, shiftLaps(actB.Laps, cropShift(joined, tsB), 0, uint32(joined.MaxOffset()))...)
+
+	sessions := shiftSessions(actA.Sessions, cropShift(joined, tsA), 0, uint32(joined.MaxOffset()))
+	sessions = append(sessions, shiftSessions(actB.Sessions, cropShift(joined, tsB), 0, uint32(joined.MaxOffset()))...)
+
+	result, err := summarizeEdit(actA, joined, laps, sessions, config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result, joined, nil
+}
+
+// summarizeEdit builds the summary of an edited timeseries. The summary of each session
+// is recomputed from the samples it kept, and its laps are those starting within it.
+func summarizeEdit(act *Activity, ts *ActivityTimeseries, laps []Lap, sessions []Session, config AugmentConfig) (*Activity, error) {
+	result := &Activity{
+		Provider: act.Provider,
+		Sport:    act.Sport,
+		Device:   act.Device,
+		Laps:     laps,
+	}
+
+	if err := RecomputeSummary(result, ts, config); err != nil {
+		return nil, err
+	}
+
+	for _, s := range sessions {
+		part, err := ts.Crop(time.Duration(s.StartOffset)*time.Second, time.Duration(s.StartOffset+s.ElapsedTime)*time.Second)
+		if err != nil {
+			continue // No samples left in the session
+		}
+
+		summary := &Activity{}
+		if err := RecomputeSummary(summary, part, config); err != nil {
+			return nil, err
+		}
+
+		s.MovingTime = summary.MovingTime
+		s.Distance = summary.Distance
+		s.AvgSpeed = summary.AvgSpeed
+		s.AvgHR = summary.AvgHR
+		s.MaxHR = summary.MaxHR
+		s.ElevationGain = summary.ElevationGain
+		s.ElevationLoss = summary.ElevationLoss
+
+		s.FirstLapIndex, s.NumLaps = 0, 0
+		for i, lap := range laps {
+			if lap.StartOffset < s.StartOffset || lap.StartOffset >= s.StartOffset+s.ElapsedTime {
+				continue
+			}
+			if s.NumLaps == 0 {
+				s.FirstLapIndex = i
+			}
+			s.NumLaps++
+		}
+
+		result.Sessions = append(result.Sessions, s)
+	}
+
+	return result, nil
+}
+
+// cropShift returns how many seconds the start of part is after the start of ts.
+func cropShift(ts, part *ActivityTimeseries) int {
+	return int(part.StartTime.Sub(ts.StartTime).Seconds())
+}
+
+// shiftLaps moves laps by shift seconds and keeps those that still fall entirely
+// within [minOffset, maxOffset]. Laps cut by an edit are dropped.
+func shiftLaps(laps []Lap, shift int, minOffset, maxOffset uint32) []Lap {
+	var shifted []Lap
+	for _, lap := range laps {
+		start := int(lap.StartOffset) + shift
+		if start < int(minOffset) || start+int(lap.Duration) > int(maxOffset) {
+			continue
+		}
+		lap.StartOffset = uint32(start)
+		shifted = append(shifted, lap)
+	}
+	return shifted
+}
+
+// shiftSessions moves sessions by shift seconds and clips them to [minOffset, maxOffset].
+// Sessions left without time are dropped.
+func shiftSessions(sessions []Session, shift int, minOffset, maxOffset uint32) []Session {
+	var shifted []Session
+	for _, s := range sessions {
+		start := max(int(s.StartOffset)+shift, int(minOffset))
+		end := min(int(s.StartOffset+s.ElapsedTime)+shift, int(maxOffset))
+		if end <= start {
+			continue
+		}
+		s.StartOffset = uint32(start)
+		s.ElapsedTime = uint32(end - start)
+		shifted = append(shifted, s)
+	}
+	return shifted
+}
+
+// rebaseTimeseries moves StartTime to the first sample and shifts offsets to start at zero.
+// Distance is rebased too, so a cropped or split part starts from zero meters. Pauses are
+// clipped to the kept samples.
+func rebaseTimeseries(startTime time.Time, data []ActivityTimeseriesEntry, pauses []Pause, extra []ExtraChannel) *ActivityTimeseries {
+	if len(data) == 0 {
+		return &ActivityTimeseries{StartTime: startTime, ExtraChannels: extra}
+	}
+
+	base := data[0].Offset
+	var distanceBase Distance
+	for _, entry := range data {
+		if entry.Distance.Valid {
+			distanceBase = entry.Distance.Value
+			break
+		}
+	}
+
+	rebased := &ActivityTimeseries{
+		StartTime: startTime.Add(time.Duration(base) * time.Second),
+		Data:      make([]ActivityTimeseriesEntry, len(data)),
+		Pauses:    clipPauses(pauses, -base, base, data[len(data)-1].Offset),
+
+		ExtraChannels: extra,
+	}
+
+	for i, entry := range data {
+		entry.Offset -= base
+		if entry.Distance.Valid {
+			if entry.Distance.Value >= distanceBase {
+				entry.Distance.Value -= distanceBase
+			} else {
+				entry.Distance.Value = 0
+			}
+		}
+		rebased.Data[i] = entry
+	}
+
+	return rebased
+}
@@ -0,0 +1,134 @@
+package stride_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+// steadyRun is a 1 Hz run at 3 m/s heading north, climbing 1 m every 10 s.
+func steadyRun(start time.Time, seconds int) *ActivityTimeseries {
+	ts := &ActivityTimeseries{StartTime: start}
+	for i := 0; i <= seconds; i++ {
+		ts.Data = append(ts.Data, ActivityTimeseriesEntry{
+			Offset:    i,
+			HeartRate: Optional[uint8]{Value: 150, Valid: true},
+			Distance:  Optional[Distance]{Value: Distance(i * 3), Valid: true},
+			Altitude:  Optional[float64]{Value: 100 + float64(i)/10, Valid: true},
+			Latitude:  Optional[float64]{Value: 45 + float64(i)*0.000027, Valid: true},
+			Longitude: Optional[float64]{Value: 7, Valid: true},
+		})
+	}
+	return ts
+}
+
+func TestCropActivity(t *testing.T) {
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	ts := steadyRun(start, 3600)
+	act := &Activity{
+		Provider: ProviderStrava,
+		Sport:    SportRunning,
+		Laps: []Lap{
+			{StartOffset: 0, Duration: 600},
+			{StartOffset: 600, Duration: 600},
+			{StartOffset: 1200, Duration: 600},
+		},
+	}
+
+	cropped, croppedTs, err := CropActivity(act, ts, 10*time.Minute, 30*time.Minute, AugmentConfig{})
+	require.NoError(t, err)
+
+	assert.Equal(t, start.Add(10*time.Minute), croppedTs.StartTime)
+	assert.Equal(t, 0, croppedTs.Data[0].Offset)
+	assert.Equal(t, Distance(0), croppedTs.Data[0].Distance.Value)
+
+	assert.Equal(t, SportRunning, cropped.Sport)
+	assert.Equal(t, start.Add(10*time.Minute), cropped.StartTime)
+	assert.Equal(t, uint32(1200), cropped.ElapsedTime)
+	assert.Equal(t, uint32(1200), cropped.MovingTime)
+	assert.Equal(t, Distance(3600), cropped.Distance)
+	assert.Equal(t, uint16(120), cropped.ElevationGain.Value)
+	assert.Equal(t, uint8(150), cropped.AvgHR.Value)
+
+	require.Len(t, cropped.Laps, 2)
+	assert.Equal(t, uint32(0), cropped.Laps[0].StartOffset)
+	assert.Equal(t, uint32(600), cropped.Laps[1].StartOffset)
+
+	_, _, err = CropActivity(act, ts, 2*time.Hour, 3*time.Hour, AugmentConfig{})
+	assert.ErrorIs(t, err, ErrInvalidCropRange)
+
+	t.Run("DeviceAndSessions", func(t *testing.T) {
+		multisport := &Activity{
+			Sport:  SportRunning,
+			Device: Optional[Device]{Value: Device{Manufacturer: "wahoo_fitness", Product: "ELEMNT ROAM"}, Valid: true},
+			Laps:   act.Laps,
+			Sessions: []Session{
+				{Sport: SportRunning, ElapsedTime: 1800, Distance: 5400, FirstLapIndex: 0, NumLaps: 3},
+				{Sport: SportCycling, StartOffset: 1800, ElapsedTime: 1800, Distance: 5400},
+			},
+		}
+
+		cropped, croppedTs, err := CropActivity(multisport, ts, 10*time.Minute, 40*time.Minute, AugmentConfig{})
+		require.NoError(t, err)
+
+		assert.Equal(t, multisport.Device, cropped.Device)
+		require.Len(t, cropped.Sessions, 2)
+		assert.Equal(t, Session{
+			Sport:         SportRunning,
+			ElapsedTime:   1200,
+			MovingTime:    1200,
+			Distance:      3600,
+			AvgSpeed:      3000,
+			AvgHR:         Optional[uint8]{Value: 150, Valid: true},
+			MaxHR:         Optional[uint8]{Value: 150, Valid: true},
+			ElevationGain: Optional[uint16]{Value: 120, Valid: true},
+			ElevationLoss: Optional[uint16]{Value: 0, Valid: true},
+			FirstLapIndex: 0,
+			NumLaps:       2,
+		}, cropped.Sessions[0])
+		assert.Equal(t, SportCycling, cropped.Sessions[1].Sport)
+		assert.Equal(t, uint32(1200), cropped.Sessions[1].StartOffset)
+		assert.Equal(t, uint32(600), cropped.Sessions[1].ElapsedTime)
+		assert.Equal(t, Distance(1800), cropped.Sessions[1].Distance)
+		assert.Equal(t, 0, cropped.Sessions[1].NumLaps)
+
+		data, err := CreateFITFileInMemory(cropped, croppedTs, SportRunning)
+		require.NoError(t, err)
+		parsed, _, err := ParseFITFile(data)
+		require.NoError(t, err)
+		assert.Equal(t, "ELEMNT ROAM", parsed.Device.Value.Product)
+		assert.Len(t, parsed.Sessions, 2)
+	})
+}
+
+func TestSplitAndConcat(t *testing.T) {
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	ts := steadyRun(start, 1200)
+	act := &Activity{Sport: SportRunning}
+
+	acts, parts, err := SplitActivity(act, ts, 10*time.Minute, AugmentConfig{})
+	require.NoError(t, err)
+
+	assert.Equal(t, start, parts[0].StartTime)
+	assert.Equal(t, start.Add(10*time.Minute), parts[1].StartTime)
+	assert.Equal(t, Distance(1797), acts[0].Distance)
+	assert.Equal(t, Distance(1800), acts[1].Distance)
+
+	joined, joinedTs, err := ConcatActivities(acts[0], parts[0], acts[1], parts[1], AugmentConfig{})
+	require.NoError(t, err)
+
+	require.Len(t, joinedTs.Data, len(ts.Data))
+	assert.Equal(t, ts.Data[1000].Offset, joinedTs.Data[1000].Offset)
+	assert.Equal(t, ts.Data[1000].Distance, joinedTs.Data[1000].Distance)
+	assert.Equal(t, uint32(1200), joined.ElapsedTime)
+
+	_, err = Concat(parts[1], parts[0])
+	assert.ErrorIs(t, err, ErrOverlappingConcat)
+
+	_, _, err = ts.SplitAt(time.Hour)
+	assert.ErrorIs(t, err, ErrInvalidSplitOffset)
+}
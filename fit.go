@@ -2,71 +2,122 @@ package stride
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/muktihari/fit/decoder"
 	"github.com/muktihari/fit/encoder"
+	"github.com/muktihari/fit/profile/basetype"
 	"github.com/muktihari/fit/profile/filedef"
 	"github.com/muktihari/fit/profile/mesgdef"
 	"github.com/muktihari/fit/profile/typedef"
 	"github.com/muktihari/fit/proto"
 )
 
+var (
+	ErrFailedToParseFITFile = errors.New("failed to parse FIT file")
+	ErrNotFITActivity       = errors.New("FIT file is not an activity")
+	ErrNoFITActivityData    = errors.New("no sessions, laps or records found in FIT file")
+	ErrTooManyFITFields     = errors.New("too many extra channels for a FIT developer data index")
+)
+
 type FITSport struct {
 	Sport    typedef.Sport
 	SubSport typedef.SubSport
 }
 
+// FITExportConfig sets the file metadata written by CreateFITFileWithConfig.
+type FITExportConfig struct {
+	Device      Optional[Device] // Recording device (default: the activity device, then a Garmin Enduro 3)
+	TimeCreated time.Time        // File creation time (default: the activity start time)
+}
+
+var defaultFITDevice = Device{
+	Manufacturer: typedef.ManufacturerGarmin.String(),
+	Product:      "Enduro 3",
+	ProductID:    uint16(typedef.GarminProductEnduro3),
+}
+
 func CreateFITFileInMemory(data *Activity, ts *ActivityTimeseries, sport Sport) ([]byte, error) {
-	now := time.Now()
+	return CreateFITFileWithConfig(data, ts, sport, FITExportConfig{})
+}
+
+// CreateFITFileWithConfig writes a FIT activity file. Pauses of the timeseries become
+// timer stop/start events and are left out of the timer time of sessions and laps, so
+// other platforms compute the same moving time. Activities without laps get one lap
+// per session. The output only depends on the inputs.
+func CreateFITFileWithConfig(data *Activity, ts *ActivityTimeseries, sport Sport, config FITExportConfig) ([]byte, error) {
+	device := config.Device
+	if !device.Valid {
+		device = data.Device
+	}
+	if !device.Valid {
+		device = Optional[Device]{Value: defaultFITDevice, Valid: true}
+	}
+
+	timeCreated := config.TimeCreated
+	if timeCreated.IsZero() {
+		timeCreated = data.StartTime
+	}
 
 	activity := filedef.NewActivity()
 
-	activity.FileId = *mesgdef.NewFileId(nil).
+	activity.FileId = *deviceToFitFileId(device.Value).
 		SetType(typedef.FileActivity).
-		SetTimeCreated(now).
-		SetManufacturer(typedef.ManufacturerGarmin).
-		SetProductName("Enduro 3")
+		SetTimeCreated(timeCreated)
 
-	activity.Activity = mesgdef.NewActivity(nil).
-		SetType(typedef.ActivityManual).
-		SetTimestamp(data.StartTime).
-		SetNumSessions(1)
+	activity.DeviceInfos = append(activity.DeviceInfos, deviceToFitDeviceInfo(device.Value, data.StartTime))
 
-	fitSport, err := sportToFitSport(sport)
-	if err != nil {
-		return nil, err
-	}
+	// Pauses are relative to the timeseries start, sessions and laps to the activity start
+	shift := int(ts.StartTime.Unix() - data.StartTime.Unix())
+	pauses := clipPauses(ts.Pauses, shift, math.MinInt, math.MaxInt)
 
-	session := mesgdef.NewSession(nil).
-		SetStartTime(data.StartTime).
-		SetTotalElapsedTime(data.ElapsedTime).
-		SetTotalMovingTime(data.MovingTime).
-		SetTotalTimerTime(data.ElapsedTime).
-		SetTotalDistance(data.Distance).
-		SetSport(fitSport.Sport).
-		SetSubSport(fitSport.SubSport).
-		SetAvgSpeed(data.AvgSpeed)
+	sessions := slices.Clone(data.sessionsOrDefault(sport))
 
-	if data.AvgHR.Valid {
-		session = session.SetAvgHeartRate(data.AvgHR.Value)
+	laps := data.Laps
+	if len(laps) == 0 {
+		laps = make([]Lap, 0, len(sessions))
+		for i, s := range sessions {
+			laps = append(laps, s.lap())
+			sessions[i].FirstLapIndex = i
+			sessions[i].NumLaps = 1
+		}
 	}
 
-	if data.MaxHR.Valid {
-		session = session.SetMaxHeartRate(data.MaxHR.Value)
-	}
+	var totalTimerTime uint32
+	for _, s := range sessions {
+		session, err := sessionToFitSession(s, data.StartTime, pauses)
+		if err != nil {
+			return nil, err
+		}
 
-	if data.ElevationGain.Valid {
-		session = session.SetTotalAscent(data.ElevationGain.Value)
+		activity.Sessions = append(activity.Sessions, session)
+		totalTimerTime += timerTime(s.StartOffset, s.ElapsedTime, pauses)
 	}
 
-	if data.ElevationLoss.Valid {
-		session = session.SetTotalDescent(data.ElevationLoss.Value)
+	activity.Activity = mesgdef.NewActivity(nil).
+		SetType(typedef.ActivityManual).
+		SetTimestamp(data.StartTime).
+		SetTotalTimerTimeScaled(float64(totalTimerTime)).
+		SetNumSessions(uint16(len(sessions)))
+
+	for _, lap := range laps {
+		activity.Laps = append(activity.Laps, lapToFitLap(lap, data.StartTime, pauses))
 	}
 
-	activity.Sessions = append(activity.Sessions, session)
+	activity.Events = timerEvents(data, sessions, pauses)
+
+	fields, developerDataIds, fieldDescriptions, err := extraChannelsToFitFields(ts.ExtraChannels)
+	if err != nil {
+		return nil, err
+	}
+	activity.DeveloperDataIds = developerDataIds
+	activity.FieldDescriptions = fieldDescriptions
 
 	for _, d := range ts.Data {
 		if d.IsEmpty() {
@@ -78,11 +129,11 @@ func CreateFITFileInMemory(data *Activity, ts *ActivityTimeseries, sport Sport)
 		record := mesgdef.NewRecord(nil).SetTimestamp(t)
 
 		if d.Distance.Valid {
-			record = record.SetDistance(d.Distance.Value)
+			record = record.SetDistanceScaled(d.Distance.Value.Meters())
 		}
 
 		if d.Velocity.Valid {
-			record = record.SetSpeed(d.Velocity.Value)
+			record = record.SetSpeedScaled(d.Velocity.Value.MetersPerSecond())
 		}
 
 		if d.Altitude.Valid {
@@ -97,11 +148,33 @@ func CreateFITFileInMemory(data *Activity, ts *ActivityTimeseries, sport Sport)
 			record = record.SetHeartRate(d.HeartRate.Value)
 		}
 
+		if d.Power.Valid {
+			record = record.SetPower(d.Power.Value)
+		}
+
+		if d.Temperature.Valid {
+			record = record.SetTemperature(d.Temperature.Value)
+		}
+
+		if d.Grade.Valid {
+			record = record.SetGradeScaled(d.Grade.Value)
+		}
+
 		if d.Latitude.Valid && d.Longitude.Valid {
 			record = record.SetPositionLatDegrees(d.Latitude.Value)
 			record = record.SetPositionLongDegrees(d.Longitude.Value)
 		}
 
+		for i, ch := range ts.ExtraChannels {
+			if value, ok := d.Extra[ch.Name]; ok {
+				record.DeveloperFields = append(record.DeveloperFields, proto.DeveloperField{
+					Num:                fields[i].FieldNumber,
+					DeveloperDataIndex: fields[i].DeveloperDataIndex,
+					Value:              fields[i].encode(value),
+				})
+			}
+		}
+
 		activity.Records = append(activity.Records, record)
 	}
 
@@ -117,45 +190,569 @@ func CreateFITFileInMemory(data *Activity, ts *ActivityTimeseries, sport Sport)
 	return buf.Bytes(), nil
 }
 
-func FITFileToActivityTimeseries(data []byte) (*ActivityTimeseries, error) {
+func sessionToFitSession(s Session, activityStart time.Time, pauses []Pause) (*mesgdef.Session, error) {
+	// Sports without a mapping, e.g. read from another FIT file, are written as generic
+	fitSport := FITSport{Sport: typedef.SportGeneric}
+	if s.Sport != SportUnknown {
+		var err error
+		if fitSport, err = sportToFitSport(s.Sport); err != nil {
+			return nil, err
+		}
+	}
+
+	startTime := activityStart.Add(time.Duration(s.StartOffset) * time.Second)
+
+	session := mesgdef.NewSession(nil).
+		SetTimestamp(startTime.Add(time.Duration(s.ElapsedTime) * time.Second)).
+		SetEvent(typedef.EventSession).
+		SetEventType(typedef.EventTypeStop).
+		SetStartTime(startTime).
+		SetTotalElapsedTimeScaled(float64(s.ElapsedTime)).
+		SetTotalMovingTimeScaled(float64(s.MovingTime)).
+		SetTotalTimerTimeScaled(float64(timerTime(s.StartOffset, s.ElapsedTime, pauses))).
+		SetTotalDistanceScaled(float64(s.Distance)).
+		SetSport(fitSport.Sport).
+		SetSubSport(fitSport.SubSport).
+		SetAvgSpeedScaled(s.AvgSpeed.MetersPerSecond()).
+		SetFirstLapIndex(uint16(s.FirstLapIndex)).
+		SetNumLaps(uint16(s.NumLaps))
+
+	if s.AvgHR.Valid {
+		session = session.SetAvgHeartRate(s.AvgHR.Value)
+	}
+
+	if s.MaxHR.Valid {
+		session = session.SetMaxHeartRate(s.MaxHR.Value)
+	}
+
+	if s.ElevationGain.Valid {
+		session = session.SetTotalAscent(s.ElevationGain.Value)
+	}
+
+	if s.ElevationLoss.Valid {
+		session = session.SetTotalDescent(s.ElevationLoss.Value)
+	}
+
+	return session, nil
+}
+
+func lapToFitLap(lap Lap, activityStart time.Time, pauses []Pause) *mesgdef.Lap {
+	startTime := lap.startTime(activityStart)
+
+	fitLap := mesgdef.NewLap(nil).
+		SetTimestamp(startTime.Add(time.Duration(lap.Duration) * time.Second)).
+		SetEvent(typedef.EventLap).
+		SetEventType(typedef.EventTypeStop).
+		SetStartTime(startTime).
+		SetTotalElapsedTimeScaled(float64(lap.Duration)).
+		SetTotalTimerTimeScaled(float64(timerTime(lap.StartOffset, lap.Duration, pauses))).
+		SetTotalDistanceScaled(float64(lap.Distance)).
+		SetAvgSpeedScaled(lap.AvgSpeed.MetersPerSecond()).
+		SetLapTrigger(lapTriggerToFitLapTrigger(lap.Trigger))
+
+	if lap.AvgHR.Valid {
+		fitLap = fitLap.SetAvgHeartRate(lap.AvgHR.Value)
+	}
+
+	if lap.MaxHR.Valid {
+		fitLap = fitLap.SetMaxHeartRate(lap.MaxHR.Value)
+	}
+
+	return fitLap
+}
+
+// timerTime returns the seconds of [start, start+duration) that are not paused.
+func timerTime(start, duration uint32, pauses []Pause) uint32 {
+	end := int(start + duration)
+
+	paused := 0
+	for _, p := range pauses {
+		paused += max(0, min(p.EndOffset, end)-max(p.StartOffset, int(start)))
+	}
+
+	return duration - uint32(min(paused, int(duration)))
+}
+
+// timerEvents starts the timer with the first session, stops and restarts it around
+// each pause and stops it for good at the end of the last session. Pauses crossing the
+// session bounds are clipped to them, matching what timerTime subtracts.
+func timerEvents(data *Activity, sessions []Session, pauses []Pause) []*mesgdef.Event {
+	event := func(offset int, eventType typedef.EventType) *mesgdef.Event {
+		return mesgdef.NewEvent(nil).
+			SetTimestamp(data.StartTime.Add(time.Duration(offset) * time.Second)).
+			SetEvent(typedef.EventTimer).
+			SetEventType(eventType).
+			SetEventGroup(0)
+	}
+
+	first, last := sessions[0], sessions[len(sessions)-1]
+	start, end := int(first.StartOffset), int(last.StartOffset+last.ElapsedTime)
+
+	events := []*mesgdef.Event{event(start, typedef.EventTypeStart)}
+	for _, p := range clipPauses(pauses, 0, start, end) {
+		events = append(events, event(p.StartOffset, typedef.EventTypeStop), event(p.EndOffset, typedef.EventTypeStart))
+	}
+
+	return append(events, event(end, typedef.EventTypeStopAll))
+}
+
+func deviceToFitFileId(device Device) *mesgdef.FileId {
+	manufacturer := typedef.ManufacturerFromString(device.Manufacturer)
+	if manufacturer == typedef.ManufacturerInvalid {
+		manufacturer = typedef.ManufacturerDevelopment
+	}
+
+	fileID := mesgdef.NewFileId(nil).
+		SetManufacturer(manufacturer).
+		SetProductName(device.Product)
+
+	if product := fitProductID(manufacturer, device); product != basetype.Uint16Invalid {
+		fileID = fileID.SetProduct(product)
+	}
+
+	if device.SerialNumber != 0 {
+		fileID = fileID.SetSerialNumber(device.SerialNumber)
+	}
+
+	return fileID
+}
+
+func deviceToFitDeviceInfo(device Device, timestamp time.Time) *mesgdef.DeviceInfo {
+	manufacturer := typedef.ManufacturerFromString(device.Manufacturer)
+	if manufacturer == typedef.ManufacturerInvalid {
+		manufacturer = typedef.ManufacturerDevelopment
+	}
+
+	info := mesgdef.NewDeviceInfo(nil).
+		SetTimestamp(timestamp).
+		SetDeviceIndex(typedef.DeviceIndexCreator).
+		SetManufacturer(manufacturer).
+		SetProductName(device.Product)
+
+	if product := fitProductID(manufacturer, device); product != basetype.Uint16Invalid {
+		info = info.SetProduct(product)
+	}
+
+	if device.SerialNumber != 0 {
+		info = info.SetSerialNumber(device.SerialNumber)
+	}
+
+	if version, err := strconv.ParseFloat(device.SoftwareVersion, 64); err == nil {
+		info = info.SetSoftwareVersionScaled(version)
+	}
+
+	return info
+}
+
+// fitProductID returns the product ID of the device, or looks up the FIT profile name
+// of Garmin products.
+func fitProductID(manufacturer typedef.Manufacturer, device Device) uint16 {
+	if device.ProductID != 0 {
+		return device.ProductID
+	}
+
+	switch manufacturer {
+	case typedef.ManufacturerGarmin, typedef.ManufacturerDynastream, typedef.ManufacturerDynastreamOem, typedef.ManufacturerTacx:
+		return uint16(typedef.GarminProductFromString(device.Product))
+	}
+
+	return basetype.Uint16Invalid
+}
+
+// FITFileToLaps returns the laps recorded in a FIT activity file, with offsets relative
+// to the start of the first session.
+func FITFileToLaps(data []byte) ([]Lap, error) {
+	activity, err := decodeFITActivity(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return fitLapsToLaps(activity.Laps, fitActivityStartTime(activity)), nil
+}
+
+// FITFileToSessions returns the sessions recorded in a FIT activity file. Multisport
+// files have one session per sport.
+func FITFileToSessions(data []byte) ([]Session, error) {
+	activity, err := decodeFITActivity(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return fitSessionsToSessions(activity.Sessions, fitActivityStartTime(activity)), nil
+}
+
+func decodeFITActivity(data []byte) (*filedef.Activity, error) {
 	dec := decoder.New(bytes.NewReader(data))
 
 	fit, err := dec.Decode()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrFailedToParseFITFile, err)
 	}
 
 	activity := filedef.NewActivity(fit.Messages...)
+	if activity.FileId.Type != typedef.FileActivity {
+		return nil, fmt.Errorf("%w: file type %s", ErrNotFITActivity, activity.FileId.Type)
+	}
 
-	startTime := activity.Sessions[0].StartTime
+	return activity, nil
+}
 
-	timeseries := ActivityTimeseries{
+// ParseFITFile reads a FIT activity file: the summary, sport, laps, sessions and
+// recording device, along with the timeseries. Totals come from the sessions, or from
+// the records for files that were not closed properly.
+func ParseFITFile(data []byte) (*Activity, *ActivityTimeseries, error) {
+	activity, err := decodeFITActivity(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	startTime := fitActivityStartTime(activity)
+	if startTime.IsZero() {
+		return nil, nil, ErrNoFITActivityData
+	}
+
+	ts := fitActivityTimeseries(activity, startTime)
+
+	act := &Activity{
 		StartTime: startTime,
-		Data:      make([]ActivityTimeseriesEntry, 0),
+		Laps:      fitLapsToLaps(activity.Laps, startTime),
+		Sessions:  fitSessionsToSessions(activity.Sessions, startTime),
+		Device:    fitDevice(activity),
 	}
 
-	for _, record := range activity.Records {
-		entry := ActivityTimeseriesEntry{
-			Offset:    int(record.Timestamp.Unix() - startTime.Unix()),
-			HeartRate: Optional[uint8]{Value: record.HeartRate, Valid: record.HeartRate > 0},
-			Cadence:   Optional[uint8]{Value: record.Cadence, Valid: record.Cadence > 0},
-			Velocity:  Optional[uint16]{Value: uint16(record.SpeedScaled()), Valid: !math.IsNaN(record.SpeedScaled())},
-			Altitude:  Optional[float64]{Value: record.AltitudeScaled(), Valid: !math.IsNaN(record.AltitudeScaled())},
-			Distance:  Optional[uint32]{Value: uint32(record.DistanceScaled()), Valid: !math.IsNaN(record.DistanceScaled())},
+	if len(act.Sessions) == 0 {
+		act.Sport = SportUnknown
+		if len(activity.Sports) > 0 {
+			act.Sport = fitSportToSport(FITSport{Sport: activity.Sports[0].Sport, SubSport: activity.Sports[0].SubSport})
+		}
+
+		if err := RecomputeSummary(act, ts, AugmentConfig{}); err != nil {
+			return nil, nil, err
+		}
+
+		return act, ts, nil
+	}
+
+	act.Sport = act.Sessions[0].Sport
+	applySessionTotals(act)
+
+	return act, ts, nil
+}
+
+// applySessionTotals fills the activity summary from its sessions: times, distances
+// and elevation add up, heart rate is averaged by moving time.
+func applySessionTotals(act *Activity) {
+	if len(act.Sessions) == 1 {
+		s := act.Sessions[0]
+		act.ElapsedTime = s.StartOffset + s.ElapsedTime
+		act.MovingTime = s.MovingTime
+		act.Distance = s.Distance
+		act.AvgSpeed = s.AvgSpeed
+		act.AvgHR = s.AvgHR
+		act.MaxHR = s.MaxHR
+		act.ElevationGain = s.ElevationGain
+		act.ElevationLoss = s.ElevationLoss
+		return
+	}
+
+	var hrSum, hrWeight float64
+	for _, s := range act.Sessions {
+		act.ElapsedTime = max(act.ElapsedTime, s.StartOffset+s.ElapsedTime)
+		act.MovingTime += s.MovingTime
+		act.Distance += s.Distance
+
+		if s.AvgHR.Valid && s.MovingTime > 0 {
+			hrSum += float64(s.AvgHR.Value) * float64(s.MovingTime)
+			hrWeight += float64(s.MovingTime)
+		}
+
+		if s.MaxHR.Valid && (!act.MaxHR.Valid || s.MaxHR.Value > act.MaxHR.Value) {
+			act.MaxHR = s.MaxHR
+		}
+
+		if s.ElevationGain.Valid {
+			act.ElevationGain = Optional[uint16]{Value: act.ElevationGain.Value + s.ElevationGain.Value, Valid: true}
+		}
+
+		if s.ElevationLoss.Valid {
+			act.ElevationLoss = Optional[uint16]{Value: act.ElevationLoss.Value + s.ElevationLoss.Value, Valid: true}
+		}
+	}
+
+	if act.MovingTime > 0 {
+		act.AvgSpeed = SpeedFromMetersPerSecond(act.Distance.Meters() / float64(act.MovingTime))
+	}
+
+	if hrWeight > 0 {
+		act.AvgHR = Optional[uint8]{Value: uint8(math.Round(hrSum / hrWeight)), Valid: true}
+	}
+}
+
+// fitDevice describes the device that created the file, from the file id and the
+// creator device info.
+func fitDevice(activity *filedef.Activity) Optional[Device] {
+	fileID := activity.FileId
+	if fileID.Manufacturer == typedef.ManufacturerInvalid {
+		return Optional[Device]{}
+	}
+
+	device := Device{
+		Manufacturer: fileID.Manufacturer.String(),
+		Product:      fitProductName(fileID.Manufacturer, fileID.Product, fileID.ProductName),
+	}
+
+	if fileID.Product != basetype.Uint16Invalid {
+		device.ProductID = fileID.Product
+	}
+
+	if fileID.SerialNumber != basetype.Uint32zInvalid {
+		device.SerialNumber = fileID.SerialNumber
+	}
+
+	for _, info := range activity.DeviceInfos {
+		if info.DeviceIndex != typedef.DeviceIndexCreator {
+			continue
+		}
+
+		if version := info.SoftwareVersionScaled(); !math.IsNaN(version) {
+			device.SoftwareVersion = fmt.Sprintf("%.2f", version)
+		}
+
+		if device.SerialNumber == 0 && info.SerialNumber != basetype.Uint32zInvalid {
+			device.SerialNumber = info.SerialNumber
+		}
+
+		break
+	}
+
+	return Optional[Device]{Value: device, Valid: true}
+}
+
+// fitProductName prefers the free form product name, then the FIT profile name of
+// Garmin products.
+func fitProductName(manufacturer typedef.Manufacturer, product uint16, productName string) string {
+	if productName != "" {
+		return productName
+	}
+
+	switch manufacturer {
+	case typedef.ManufacturerGarmin, typedef.ManufacturerDynastream, typedef.ManufacturerDynastreamOem, typedef.ManufacturerTacx:
+		if name := typedef.GarminProduct(product).String(); !strings.HasPrefix(name, "GarminProductInvalid") {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// fitActivityStartTime returns the start of the first session, falling back to the
+// first lap or record for files that were not closed properly or lack start times.
+func fitActivityStartTime(activity *filedef.Activity) time.Time {
+	if len(activity.Sessions) > 0 && !activity.Sessions[0].StartTime.IsZero() {
+		return activity.Sessions[0].StartTime
+	}
+
+	if len(activity.Laps) > 0 && !activity.Laps[0].StartTime.IsZero() {
+		return activity.Laps[0].StartTime
+	}
+
+	if len(activity.Records) > 0 {
+		return activity.Records[0].Timestamp
+	}
+
+	return time.Time{}
+}
+
+func fitLapsToLaps(fitLaps []*mesgdef.Lap, startTime time.Time) []Lap {
+	laps := make([]Lap, 0, len(fitLaps))
+
+	for _, l := range fitLaps {
+		lap := Lap{
+			StartOffset: uint32(max(0, l.StartTime.Unix()-startTime.Unix())),
+			Trigger:     fitLapTriggerToLapTrigger(l.LapTrigger),
+			AvgHR:       fitUint8(l.AvgHeartRate),
+			MaxHR:       fitUint8(l.MaxHeartRate),
+		}
+
+		if elapsed := l.TotalElapsedTimeScaled(); !math.IsNaN(elapsed) {
+			lap.Duration = uint32(math.Round(elapsed))
+		}
+
+		if distance := l.TotalDistanceScaled(); !math.IsNaN(distance) {
+			lap.Distance = DistanceFromMeters(distance)
 		}
 
-		// Parse GPS coordinates if available
-		if !math.IsNaN(record.PositionLatDegrees()) && !math.IsNaN(record.PositionLongDegrees()) {
-			lat := record.PositionLatDegrees()
-			lon := record.PositionLongDegrees()
-			entry.Latitude = Optional[float64]{Value: lat, Valid: lat != 0}
-			entry.Longitude = Optional[float64]{Value: lon, Valid: lon != 0}
+		if speed := l.AvgSpeedScaled(); !math.IsNaN(speed) {
+			lap.AvgSpeed = SpeedFromMetersPerSecond(speed)
 		}
 
+		laps = append(laps, lap)
+	}
+
+	return laps
+}
+
+func fitSessionsToSessions(fitSessions []*mesgdef.Session, startTime time.Time) []Session {
+	sessions := make([]Session, 0, len(fitSessions))
+
+	for _, s := range fitSessions {
+		session := Session{
+			Sport:         fitSportToSport(FITSport{Sport: s.Sport, SubSport: s.SubSport}),
+			StartOffset:   uint32(max(0, s.StartTime.Unix()-startTime.Unix())),
+			AvgHR:         fitUint8(s.AvgHeartRate),
+			MaxHR:         fitUint8(s.MaxHeartRate),
+			ElevationGain: fitUint16(s.TotalAscent),
+			ElevationLoss: fitUint16(s.TotalDescent),
+		}
+
+		if elapsed := s.TotalElapsedTimeScaled(); !math.IsNaN(elapsed) {
+			session.ElapsedTime = uint32(math.Round(elapsed))
+		}
+
+		if moving := s.TotalMovingTimeScaled(); !math.IsNaN(moving) {
+			session.MovingTime = uint32(math.Round(moving))
+		} else if timer := s.TotalTimerTimeScaled(); !math.IsNaN(timer) {
+			session.MovingTime = uint32(math.Round(timer))
+		}
+
+		if distance := s.TotalDistanceScaled(); !math.IsNaN(distance) {
+			session.Distance = DistanceFromMeters(distance)
+		}
+
+		if speed := s.AvgSpeedScaled(); !math.IsNaN(speed) {
+			session.AvgSpeed = SpeedFromMetersPerSecond(speed)
+		}
+
+		if s.FirstLapIndex != basetype.Uint16Invalid {
+			session.FirstLapIndex = int(s.FirstLapIndex)
+		}
+
+		if s.NumLaps != basetype.Uint16Invalid {
+			session.NumLaps = int(s.NumLaps)
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions
+}
+
+// fitUint8 and fitUint16 map FIT invalid values to a zero, invalid Optional.
+func fitUint8(v uint8) Optional[uint8] {
+	if v == basetype.Uint8Invalid {
+		return Optional[uint8]{}
+	}
+	return Optional[uint8]{Value: v, Valid: true}
+}
+
+func fitUint16(v uint16) Optional[uint16] {
+	if v == basetype.Uint16Invalid {
+		return Optional[uint16]{}
+	}
+	return Optional[uint16]{Value: v, Valid: true}
+}
+
+func FITFileToActivityTimeseries(data []byte) (*ActivityTimeseries, error) {
+	activity, err := decodeFITActivity(data)
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := fitActivityStartTime(activity)
+	if startTime.IsZero() {
+		return nil, ErrNoFITActivityData
+	}
+
+	return fitActivityTimeseries(activity, startTime), nil
+}
+
+func fitActivityTimeseries(activity *filedef.Activity, startTime time.Time) *ActivityTimeseries {
+	timeseries := ActivityTimeseries{
+		StartTime: startTime,
+		Data:      make([]ActivityTimeseriesEntry, 0, len(activity.Records)),
+	}
+
+	developer := newFitDeveloperFields()
+	for _, id := range activity.DeveloperDataIds {
+		developer.addDeveloperDataId(id)
+	}
+	for _, description := range activity.FieldDescriptions {
+		developer.addFieldDescription(description)
+	}
+
+	for _, record := range activity.Records {
+		entry := fitRecordToEntry(record, startTime)
+		entry.Extra = developer.values(record.DeveloperFields)
 		timeseries.Data = append(timeseries.Data, entry)
 	}
 
-	return &timeseries, nil
+	timeseries.Pauses = fitTimerPauses(activity, startTime)
+	timeseries.ExtraChannels = developer.recordedChannels()
+
+	return &timeseries
+}
+
+// fitRecordToEntry converts a FIT record to a timeseries entry, offset from startTime.
+// Invalid FIT values leave the channel invalid and zero. Speed and altitude fall back to
+// their enhanced fields, which newer devices record instead.
+func fitRecordToEntry(record *mesgdef.Record, startTime time.Time) ActivityTimeseriesEntry {
+	entry := ActivityTimeseriesEntry{
+		Offset: int(record.Timestamp.Unix() - startTime.Unix()),
+	}
+
+	if record.HeartRate != basetype.Uint8Invalid && record.HeartRate > 0 {
+		entry.HeartRate = Optional[uint8]{Value: record.HeartRate, Valid: true}
+	}
+
+	if record.Cadence != basetype.Uint8Invalid && record.Cadence > 0 {
+		entry.Cadence = Optional[uint8]{Value: record.Cadence, Valid: true}
+	}
+
+	speed := record.SpeedScaled()
+	if math.IsNaN(speed) {
+		speed = record.EnhancedSpeedScaled()
+	}
+	if !math.IsNaN(speed) {
+		entry.Velocity = Optional[Speed]{Value: SpeedFromMetersPerSecond(speed), Valid: true}
+	}
+
+	altitude := record.AltitudeScaled()
+	if math.IsNaN(altitude) {
+		altitude = record.EnhancedAltitudeScaled()
+	}
+	if !math.IsNaN(altitude) {
+		entry.Altitude = Optional[float64]{Value: altitude, Valid: true}
+	}
+
+	if distance := record.DistanceScaled(); !math.IsNaN(distance) {
+		entry.Distance = Optional[Distance]{Value: DistanceFromMeters(distance), Valid: true}
+	}
+
+	if record.Power != basetype.Uint16Invalid {
+		entry.Power = Optional[uint16]{Value: record.Power, Valid: true}
+	}
+
+	if record.Temperature != basetype.Sint8Invalid {
+		entry.Temperature = Optional[int8]{Value: record.Temperature, Valid: true}
+	}
+
+	if grade := record.GradeScaled(); !math.IsNaN(grade) {
+		entry.Grade = Optional[float64]{Value: grade, Valid: true}
+	}
+
+	// Parse GPS coordinates if available
+	if !math.IsNaN(record.PositionLatDegrees()) && !math.IsNaN(record.PositionLongDegrees()) {
+		lat := record.PositionLatDegrees()
+		lon := record.PositionLongDegrees()
+		if lat != 0 {
+			entry.Latitude = Optional[float64]{Value: lat, Valid: true}
+		}
+		if lon != 0 {
+			entry.Longitude = Optional[float64]{Value: lon, Valid: true}
+		}
+	}
+
+	return entry
 }
 
 func sportToFitSport(sport Sport) (FITSport, error) {
@@ -163,6 +760,9 @@ func sportToFitSport(sport Sport) (FITSport, error) {
 	case SportCycling:
 		return FITSport{Sport: typedef.SportCycling}, nil
 
+	case SportGravelCycling:
+		return FITSport{Sport: typedef.SportCycling, SubSport: typedef.SubSportGravelCycling}, nil
+
 	case SportElliptical:
 		return FITSport{Sport: typedef.SportFitnessEquipment, SubSport: typedef.SubSportElliptical}, nil
 
@@ -197,3 +797,344 @@ func sportToFitSport(sport Sport) (FITSport, error) {
 		return FITSport{}, fmt.Errorf("unknown sport: %s", sport)
 	}
 }
+
+func fitSportToSport(fitSport FITSport) Sport {
+	switch fitSport.Sport {
+	case typedef.SportCycling:
+		if fitSport.SubSport == typedef.SubSportGravelCycling {
+			return SportGravelCycling
+		}
+		return SportCycling
+
+	case typedef.SportFitnessEquipment:
+		switch fitSport.SubSport {
+		case typedef.SubSportElliptical:
+			return SportElliptical
+
+		case typedef.SubSportStairClimbing:
+			return SportStairStepper
+
+		default:
+			return SportUnknown
+		}
+
+	case typedef.SportHiking:
+		return SportHiking
+
+	case typedef.SportInlineSkating:
+		return SportInlineSkating
+
+	case typedef.SportKayaking:
+		return SportKayaking
+
+	case typedef.SportRockClimbing:
+		return SportRockClimbing
+
+	case typedef.SportRunning:
+		if fitSport.SubSport == typedef.SubSportTrail {
+			return SportTrailRunning
+		}
+		return SportRunning
+
+	case typedef.SportSurfing:
+		return SportSurfing
+
+	case typedef.SportSwimming:
+		return SportSwimming
+
+	default:
+		return SportUnknown
+	}
+}
+
+func lapTriggerToFitLapTrigger(trigger LapTrigger) typedef.LapTrigger {
+	switch trigger {
+	case LapTriggerManual:
+		return typedef.LapTriggerManual
+
+	case LapTriggerTime:
+		return typedef.LapTriggerTime
+
+	case LapTriggerDistance:
+		return typedef.LapTriggerDistance
+
+	case LapTriggerPosition:
+		return typedef.LapTriggerPositionLap
+
+	case LapTriggerSessionEnd:
+		return typedef.LapTriggerSessionEnd
+
+	default:
+		return typedef.LapTriggerInvalid
+	}
+}
+
+func fitLapTriggerToLapTrigger(trigger typedef.LapTrigger) LapTrigger {
+	switch trigger {
+	case typedef.LapTriggerManual:
+		return LapTriggerManual
+
+	case typedef.LapTriggerTime:
+		return LapTriggerTime
+
+	case typedef.LapTriggerDistance:
+		return LapTriggerDistance
+
+	case typedef.LapTriggerPositionStart, typedef.LapTriggerPositionLap, typedef.LapTriggerPositionWaypoint, typedef.LapTriggerPositionMarked:
+		return LapTriggerPosition
+
+	case typedef.LapTriggerSessionEnd:
+		return LapTriggerSessionEnd
+
+	default:
+		return LapTriggerUnknown
+	}
+}
+
+// FITDeveloperField identifies the FIT developer field of an extra channel and how its
+// values are encoded, so they can be written back unchanged.
+type FITDeveloperField struct {
+	ApplicationID      []byte
+	DeveloperDataIndex uint8
+	FieldNumber        uint8
+	BaseType           basetype.BaseType
+	Scale              uint8 // 0 when values are not scaled
+	Offset             int8
+}
+
+func (f FITDeveloperField) decode(v proto.Value) (float64, bool) {
+	var value float64
+
+	switch v.Type() {
+	case proto.TypeInt8:
+		value = float64(v.Int8())
+	case proto.TypeUint8:
+		value = float64(v.Uint8())
+	case proto.TypeInt16:
+		value = float64(v.Int16())
+	case proto.TypeUint16:
+		value = float64(v.Uint16())
+	case proto.TypeInt32:
+		value = float64(v.Int32())
+	case proto.TypeUint32:
+		value = float64(v.Uint32())
+	case proto.TypeInt64:
+		value = float64(v.Int64())
+	case proto.TypeUint64:
+		value = float64(v.Uint64())
+	case proto.TypeFloat32:
+		value = float64(v.Float32())
+	case proto.TypeFloat64:
+		value = v.Float64()
+	default:
+		return 0, false
+	}
+
+	if !v.Valid(f.BaseType) {
+		return 0, false
+	}
+
+	if f.Scale != 0 {
+		value /= float64(f.Scale)
+	}
+
+	return value - float64(f.Offset), true
+}
+
+func (f FITDeveloperField) encode(value float64) proto.Value {
+	value += float64(f.Offset)
+	if f.Scale != 0 {
+		value *= float64(f.Scale)
+	}
+
+	switch f.BaseType {
+	case basetype.Sint8:
+		return proto.Int8(int8(math.Round(value)))
+	case basetype.Uint8, basetype.Uint8z, basetype.Enum, basetype.Byte:
+		return proto.Uint8(uint8(math.Round(value)))
+	case basetype.Sint16:
+		return proto.Int16(int16(math.Round(value)))
+	case basetype.Uint16, basetype.Uint16z:
+		return proto.Uint16(uint16(math.Round(value)))
+	case basetype.Sint32:
+		return proto.Int32(int32(math.Round(value)))
+	case basetype.Uint32, basetype.Uint32z:
+		return proto.Uint32(uint32(math.Round(value)))
+	case basetype.Sint64:
+		return proto.Int64(int64(math.Round(value)))
+	case basetype.Uint64, basetype.Uint64z:
+		return proto.Uint64(uint64(math.Round(value)))
+	case basetype.Float32:
+		return proto.Float32(float32(value))
+	default:
+		return proto.Float64(value)
+	}
+}
+
+// fitDeveloperFields turns the developer fields of records into extra channel values,
+// using the developer data IDs and field descriptions met so far. Only numeric fields
+// with a single value are kept.
+type fitDeveloperFields struct {
+	applicationIDs map[uint8][]byte
+	channels       []ExtraChannel
+	byField        map[[2]uint8]int // developer data index and field number to channel
+	recorded       []bool
+}
+
+func newFitDeveloperFields() *fitDeveloperFields {
+	return &fitDeveloperFields{
+		applicationIDs: make(map[uint8][]byte),
+		byField:        make(map[[2]uint8]int),
+	}
+}
+
+func (f *fitDeveloperFields) addDeveloperDataId(id *mesgdef.DeveloperDataId) {
+	f.applicationIDs[id.DeveloperDataIndex] = id.ApplicationId
+
+	for i, ch := range f.channels {
+		if ch.FIT.Value.DeveloperDataIndex == id.DeveloperDataIndex {
+			f.channels[i].FIT.Value.ApplicationID = id.ApplicationId
+		}
+	}
+}
+
+func (f *fitDeveloperFields) addFieldDescription(desc *mesgdef.FieldDescription) {
+	if desc.FitBaseTypeId == basetype.String || (desc.Array != basetype.Uint8Invalid && desc.Array > 1) {
+		return
+	}
+
+	key := [2]uint8{desc.DeveloperDataIndex, desc.FieldDefinitionNumber}
+
+	name := strings.Join(desc.FieldName, "")
+	if name == "" || slices.ContainsFunc(f.channels, func(ch ExtraChannel) bool { return ch.Name == name }) {
+		name = fmt.Sprintf("developer_%d_%d", desc.DeveloperDataIndex, desc.FieldDefinitionNumber)
+	}
+
+	field := FITDeveloperField{
+		ApplicationID:      f.applicationIDs[desc.DeveloperDataIndex],
+		DeveloperDataIndex: desc.DeveloperDataIndex,
+		FieldNumber:        desc.FieldDefinitionNumber,
+		BaseType:           desc.FitBaseTypeId,
+	}
+
+	if desc.Scale != basetype.Uint8Invalid {
+		field.Scale = desc.Scale
+	}
+
+	if desc.Offset != basetype.Sint8Invalid {
+		field.Offset = desc.Offset
+	}
+
+	channel := ExtraChannel{
+		Name:  name,
+		Units: strings.Join(desc.Units, ""),
+		FIT:   Optional[FITDeveloperField]{Value: field, Valid: true},
+	}
+
+	if i, ok := f.byField[key]; ok {
+		f.channels[i] = channel
+		return
+	}
+
+	f.byField[key] = len(f.channels)
+	f.channels = append(f.channels, channel)
+	f.recorded = append(f.recorded, false)
+}
+
+func (f *fitDeveloperFields) values(fields []proto.DeveloperField) map[string]float64 {
+	var values map[string]float64
+
+	for _, field := range fields {
+		i, ok := f.byField[[2]uint8{field.DeveloperDataIndex, field.Num}]
+		if !ok {
+			continue
+		}
+
+		value, ok := f.channels[i].FIT.Value.decode(field.Value)
+		if !ok {
+			continue
+		}
+
+		if values == nil {
+			values = make(map[string]float64, len(fields))
+		}
+		values[f.channels[i].Name] = value
+		f.recorded[i] = true
+	}
+
+	return values
+}
+
+// recordedChannels returns the channels that have values in at least one record.
+func (f *fitDeveloperFields) recordedChannels() []ExtraChannel {
+	var channels []ExtraChannel
+	for i, ch := range f.channels {
+		if f.recorded[i] {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// extraChannelsToFitFields assigns a developer field to each extra channel. Channels
+// read from a FIT file keep their field; the others are written as float64 fields of a
+// new developer data index, which holds at most 256 fields.
+func extraChannelsToFitFields(channels []ExtraChannel) ([]FITDeveloperField, []*mesgdef.DeveloperDataId, []*mesgdef.FieldDescription, error) {
+	newIndex, newFields := 0, 0
+	for _, ch := range channels {
+		if ch.FIT.Valid {
+			newIndex = max(newIndex, int(ch.FIT.Value.DeveloperDataIndex)+1)
+		} else {
+			newFields++
+		}
+	}
+
+	if newFields > math.MaxUint8+1 || (newFields > 0 && newIndex > math.MaxUint8) {
+		return nil, nil, nil, fmt.Errorf("%w: %d new fields after developer data index %d", ErrTooManyFITFields, newFields, newIndex-1)
+	}
+
+	fields := make([]FITDeveloperField, 0, len(channels))
+	var ids []*mesgdef.DeveloperDataId
+	var descriptions []*mesgdef.FieldDescription
+
+	nextFieldNumber := 0
+	for _, ch := range channels {
+		field := ch.FIT.Value
+		if !ch.FIT.Valid {
+			field = FITDeveloperField{DeveloperDataIndex: uint8(newIndex), FieldNumber: uint8(nextFieldNumber), BaseType: basetype.Float64}
+			nextFieldNumber++
+		}
+		fields = append(fields, field)
+
+		if !slices.ContainsFunc(ids, func(id *mesgdef.DeveloperDataId) bool { return id.DeveloperDataIndex == field.DeveloperDataIndex }) {
+			id := mesgdef.NewDeveloperDataId(nil).SetDeveloperDataIndex(field.DeveloperDataIndex)
+			if field.ApplicationID != nil {
+				id = id.SetApplicationId(field.ApplicationID)
+			}
+			ids = append(ids, id)
+		}
+
+		description := mesgdef.NewFieldDescription(nil).
+			SetDeveloperDataIndex(field.DeveloperDataIndex).
+			SetFieldDefinitionNumber(field.FieldNumber).
+			SetFitBaseTypeId(field.BaseType).
+			SetFieldName([]string{ch.Name}).
+			SetNativeMesgNum(typedef.MesgNumRecord)
+
+		if ch.Units != "" {
+			description = description.SetUnits([]string{ch.Units})
+		}
+
+		if field.Scale != 0 {
+			description = description.SetScale(field.Scale)
+		}
+
+		if field.Offset != 0 {
+			description = description.SetOffset(field.Offset)
+		}
+
+		descriptions = append(descriptions, description)
+	}
+
+	return fields, ids, descriptions, nil
+}
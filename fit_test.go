@@ -0,0 +1,400 @@
+package stride_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/muktihari/fit/decoder"
+	"github.com/muktihari/fit/encoder"
+	"github.com/muktihari/fit/profile/basetype"
+	"github.com/muktihari/fit/profile/filedef"
+	"github.com/muktihari/fit/profile/mesgdef"
+	"github.com/muktihari/fit/profile/typedef"
+	"github.com/muktihari/fit/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func TestParseFITFile(t *testing.T) {
+	start := time.Date(2025, 6, 1, 7, 0, 0, 0, time.UTC)
+	ts := steadyRun(start, 600)
+
+	act := &Activity{
+		Sport:         SportTrailRunning,
+		StartTime:     start,
+		ElapsedTime:   600,
+		MovingTime:    580,
+		Distance:      1800,
+		AvgSpeed:      3103,
+		AvgHR:         Optional[uint8]{Value: 150, Valid: true},
+		MaxHR:         Optional[uint8]{Value: 150, Valid: true},
+		ElevationGain: Optional[uint16]{Value: 60, Valid: true},
+		ElevationLoss: Optional[uint16]{Value: 0, Valid: true},
+		Laps: []Lap{
+			{StartOffset: 0, Duration: 300, Distance: 900, AvgSpeed: 3000, Trigger: LapTriggerDistance},
+			{StartOffset: 300, Duration: 300, Distance: 900, AvgSpeed: 3000, Trigger: LapTriggerSessionEnd},
+		},
+	}
+
+	data, err := CreateFITFileInMemory(act, ts, SportTrailRunning)
+	require.NoError(t, err)
+
+	parsed, parsedTs, err := ParseFITFile(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, SportTrailRunning, parsed.Sport)
+	assert.Equal(t, start, parsed.StartTime)
+	assert.Equal(t, act.ElapsedTime, parsed.ElapsedTime)
+	assert.Equal(t, act.MovingTime, parsed.MovingTime)
+	assert.Equal(t, act.Distance, parsed.Distance)
+	assert.Equal(t, act.AvgSpeed, parsed.AvgSpeed)
+	assert.Equal(t, act.AvgHR, parsed.AvgHR)
+	assert.Equal(t, act.MaxHR, parsed.MaxHR)
+	assert.Equal(t, act.ElevationGain, parsed.ElevationGain)
+	assert.Equal(t, act.ElevationLoss, parsed.ElevationLoss)
+	assert.Equal(t, act.Laps, parsed.Laps)
+	require.Len(t, parsed.Sessions, 1)
+	assert.Equal(t, 2, parsed.Sessions[0].NumLaps)
+
+	require.True(t, parsed.Device.Valid)
+	assert.Equal(t, "garmin", parsed.Device.Value.Manufacturer)
+
+	expectedTs, err := FITFileToActivityTimeseries(data)
+	require.NoError(t, err)
+	assert.Equal(t, expectedTs, parsedTs)
+	assert.Len(t, parsedTs.Data, 601)
+
+	t.Run("Multisport", func(t *testing.T) {
+		act := &Activity{
+			StartTime: start,
+			Sessions: []Session{
+				{Sport: SportGravelCycling, ElapsedTime: 2400, MovingTime: 2400, Distance: 20000, AvgHR: Optional[uint8]{Value: 140, Valid: true}, MaxHR: Optional[uint8]{Value: 170, Valid: true}, ElevationGain: Optional[uint16]{Value: 300, Valid: true}},
+				{Sport: SportRunning, StartOffset: 2460, ElapsedTime: 1200, MovingTime: 1200, Distance: 4000, AvgHR: Optional[uint8]{Value: 155, Valid: true}, MaxHR: Optional[uint8]{Value: 165, Valid: true}, ElevationGain: Optional[uint16]{Value: 20, Valid: true}},
+			},
+		}
+
+		data, err := CreateFITFileInMemory(act, &ActivityTimeseries{StartTime: start}, SportUnknown)
+		require.NoError(t, err)
+
+		parsed, parsedTs, err := ParseFITFile(data)
+		require.NoError(t, err)
+
+		assert.Empty(t, parsedTs.Data)
+		assert.Equal(t, SportGravelCycling, parsed.Sport)
+		require.Len(t, parsed.Sessions, 2)
+		assert.Equal(t, uint32(3660), parsed.ElapsedTime)
+		assert.Equal(t, uint32(3600), parsed.MovingTime)
+		assert.Equal(t, Distance(24000), parsed.Distance)
+		assert.Equal(t, SpeedFromMetersPerSecond(24000.0/3600), parsed.AvgSpeed)
+		assert.Equal(t, Optional[uint8]{Value: 145, Valid: true}, parsed.AvgHR)
+		assert.Equal(t, Optional[uint8]{Value: 170, Valid: true}, parsed.MaxHR)
+		assert.Equal(t, Optional[uint16]{Value: 320, Valid: true}, parsed.ElevationGain)
+		assert.False(t, parsed.ElevationLoss.Valid)
+	})
+
+	t.Run("UnmappedSport", func(t *testing.T) {
+		file := filedef.NewActivity()
+		file.FileId = *mesgdef.NewFileId(nil).SetType(typedef.FileActivity).SetManufacturer(typedef.ManufacturerGarmin)
+		file.Sessions = append(file.Sessions, mesgdef.NewSession(nil).
+			SetStartTime(start).
+			SetTimestamp(start.Add(60*time.Second)).
+			SetSport(typedef.SportGolf).
+			SetTotalElapsedTimeScaled(60).
+			SetTotalTimerTimeScaled(60))
+		for i := 0; i <= 60; i++ {
+			file.Records = append(file.Records, mesgdef.NewRecord(nil).SetTimestamp(start.Add(time.Duration(i)*time.Second)).SetHeartRate(100))
+		}
+
+		parsed, parsedTs, err := ParseFITFile(encodeFIT(t, file))
+		require.NoError(t, err)
+		assert.Equal(t, SportUnknown, parsed.Sport)
+
+		data, err := CreateFITFileInMemory(parsed, parsedTs, parsed.Sport)
+		require.NoError(t, err, "unmapped sports are exported as generic")
+		assert.Equal(t, typedef.SportGeneric, decodeFIT(t, data).Sessions[0].Sport)
+	})
+
+	t.Run("WithoutSessions", func(t *testing.T) {
+		file := filedef.NewActivity()
+		file.FileId = *mesgdef.NewFileId(nil).
+			SetType(typedef.FileActivity).
+			SetManufacturer(typedef.ManufacturerGarmin).
+			SetProduct(uint16(typedef.GarminProductEnduro3)).
+			SetSerialNumber(123456)
+		file.DeviceInfos = append(file.DeviceInfos, mesgdef.NewDeviceInfo(nil).
+			SetTimestamp(start).
+			SetDeviceIndex(typedef.DeviceIndexCreator).
+			SetSoftwareVersion(1234))
+		file.Sports = append(file.Sports, mesgdef.NewSport(nil).SetSport(typedef.SportCycling))
+
+		for i := 0; i <= 120; i++ {
+			record := mesgdef.NewRecord(nil).
+				SetTimestamp(start.Add(time.Duration(i) * time.Second)).
+				SetHeartRate(130).
+				SetCadence(90).
+				SetEnhancedSpeedScaled(8).
+				SetDistanceScaled(float64(i * 8))
+			if i == 60 {
+				record.SetHeartRate(255).SetCadence(255) // Invalid readings
+			}
+			file.Records = append(file.Records, record)
+		}
+
+		parsed, parsedTs, err := ParseFITFile(encodeFIT(t, file))
+		require.NoError(t, err)
+
+		assert.Equal(t, SportCycling, parsed.Sport)
+		assert.Equal(t, start, parsed.StartTime)
+		assert.Equal(t, uint32(120), parsed.ElapsedTime)
+		assert.Equal(t, Distance(960), parsed.Distance)
+		assert.Equal(t, Optional[uint8]{Value: 130, Valid: true}, parsed.MaxHR)
+		assert.Equal(t, Optional[Device]{Value: Device{
+			Manufacturer:    "garmin",
+			Product:         "enduro3",
+			ProductID:       uint16(typedef.GarminProductEnduro3),
+			SerialNumber:    123456,
+			SoftwareVersion: "12.34",
+		}, Valid: true}, parsed.Device)
+
+		assert.Equal(t, Optional[Speed]{Value: 8000, Valid: true}, parsedTs.Data[0].Velocity)
+		assert.Equal(t, Optional[uint8]{}, parsedTs.Data[60].HeartRate)
+		assert.Equal(t, Optional[uint8]{}, parsedTs.Data[60].Cadence)
+		assert.Equal(t, Optional[uint16]{}, parsedTs.Data[60].Power)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, _, err := ParseFITFile([]byte("not a fit file"))
+		assert.ErrorIs(t, err, ErrFailedToParseFITFile)
+
+		course := filedef.NewActivity()
+		course.FileId = *mesgdef.NewFileId(nil).SetType(typedef.FileCourse).SetManufacturer(typedef.ManufacturerDevelopment)
+		_, _, err = ParseFITFile(encodeFIT(t, course))
+		assert.ErrorIs(t, err, ErrNotFITActivity)
+
+		empty := filedef.NewActivity()
+		empty.FileId = *mesgdef.NewFileId(nil).SetType(typedef.FileActivity).SetManufacturer(typedef.ManufacturerDevelopment)
+		_, _, err = ParseFITFile(encodeFIT(t, empty))
+		assert.ErrorIs(t, err, ErrNoFITActivityData)
+
+		_, err = FITFileToActivityTimeseries(encodeFIT(t, empty))
+		assert.ErrorIs(t, err, ErrNoFITActivityData, "no panic without sessions")
+	})
+}
+
+func TestCreateFITFileWithConfig(t *testing.T) {
+	start := time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC)
+	ts := pausedRun(start)
+	ts.Pauses = DetectPauses(ts, PauseDetectionConfig{})
+
+	act := &Activity{Sport: SportRunning, StartTime: start, ElapsedTime: 600, MovingTime: 491, Distance: 1800}
+
+	data, err := CreateFITFileInMemory(act, ts, SportRunning)
+	require.NoError(t, err)
+
+	again, err := CreateFITFileInMemory(act, ts, SportRunning)
+	require.NoError(t, err)
+	assert.Equal(t, data, again, "output is reproducible")
+
+	file := decodeFIT(t, data)
+	assert.Equal(t, start, file.FileId.TimeCreated)
+	require.Len(t, file.Sessions, 1)
+	assert.Equal(t, 491.0, file.Sessions[0].TotalTimerTimeScaled())
+	assert.Equal(t, 600.0, file.Sessions[0].TotalElapsedTimeScaled())
+
+	require.Len(t, file.Laps, 1, "a lap per session when the activity has none")
+	assert.Equal(t, 491.0, file.Laps[0].TotalTimerTimeScaled())
+	assert.Equal(t, uint16(1), file.Sessions[0].NumLaps)
+
+	require.Len(t, file.Events, 2+2*len(ts.Pauses))
+	assert.Equal(t, typedef.EventTypeStart, file.Events[0].EventType)
+	assert.Equal(t, typedef.EventTypeStopAll, file.Events[len(file.Events)-1].EventType)
+	assert.Equal(t, start.Add(600*time.Second), file.Events[len(file.Events)-1].Timestamp)
+
+	parsed, parsedTs, err := ParseFITFile(data)
+	require.NoError(t, err)
+	require.Len(t, parsedTs.Pauses, len(ts.Pauses))
+	for i, p := range ts.Pauses {
+		assert.Equal(t, Pause{StartOffset: p.StartOffset, EndOffset: p.EndOffset, Reason: PauseReasonTimer}, parsedTs.Pauses[i])
+	}
+	assert.Equal(t, Optional[Device]{Value: Device{
+		Manufacturer: "garmin",
+		Product:      "Enduro 3",
+		ProductID:    uint16(typedef.GarminProductEnduro3),
+	}, Valid: true}, parsed.Device)
+
+	t.Run("PauseCrossingSessionEnd", func(t *testing.T) {
+		crossing := steadyRun(start, 600)
+		crossing.Pauses = []Pause{{StartOffset: 590, EndOffset: 610, Reason: PauseReasonTimer}}
+
+		data, err := CreateFITFileInMemory(act, crossing, SportRunning)
+		require.NoError(t, err)
+
+		file := decodeFIT(t, data)
+		assert.Equal(t, 590.0, file.Sessions[0].TotalTimerTimeScaled())
+
+		_, parsedTs, err := ParseFITFile(data)
+		require.NoError(t, err)
+		assert.Equal(t, []Pause{{StartOffset: 590, EndOffset: 600, Reason: PauseReasonTimer}}, parsedTs.Pauses, "the pause is clipped, not dropped")
+	})
+
+	t.Run("Device", func(t *testing.T) {
+		device := Device{Manufacturer: "wahoo_fitness", Product: "ELEMNT ROAM", ProductID: 31, SerialNumber: 987654, SoftwareVersion: "1.50"}
+		created := start.Add(2 * time.Hour)
+
+		data, err := CreateFITFileWithConfig(act, ts, SportRunning, FITExportConfig{
+			Device:      Optional[Device]{Value: device, Valid: true},
+			TimeCreated: created,
+		})
+		require.NoError(t, err)
+
+		parsed, _, err := ParseFITFile(data)
+		require.NoError(t, err)
+		assert.Equal(t, Optional[Device]{Value: device, Valid: true}, parsed.Device)
+		assert.Equal(t, created, decodeFIT(t, data).FileId.TimeCreated)
+
+		// The activity device is used without a configured one
+		act := *act
+		act.Device = Optional[Device]{Value: Device{Manufacturer: "garmin", Product: "fenix8"}, Valid: true}
+
+		data, err = CreateFITFileInMemory(&act, ts, SportRunning)
+		require.NoError(t, err)
+
+		file := decodeFIT(t, data)
+		assert.Equal(t, typedef.ManufacturerGarmin, file.FileId.Manufacturer)
+		assert.Equal(t, uint16(typedef.GarminProductFenix8), file.FileId.Product)
+	})
+}
+
+func TestFITDeveloperFields(t *testing.T) {
+	start := time.Date(2025, 7, 1, 6, 0, 0, 0, time.UTC)
+	strydID := []byte{0x18, 0xfb, 0x2c, 0xf0, 0x1a, 0x4b, 0x43, 0x0d, 0xad, 0x66, 0x98, 0x8c, 0x84, 0x7a, 0x7d, 0x1d}
+
+	file := filedef.NewActivity()
+	file.FileId = *mesgdef.NewFileId(nil).SetType(typedef.FileActivity).SetManufacturer(typedef.ManufacturerGarmin)
+	file.DeveloperDataIds = append(file.DeveloperDataIds,
+		mesgdef.NewDeveloperDataId(nil).SetDeveloperDataIndex(0).SetApplicationId(strydID),
+		mesgdef.NewDeveloperDataId(nil).SetDeveloperDataIndex(1))
+	file.FieldDescriptions = append(file.FieldDescriptions,
+		mesgdef.NewFieldDescription(nil).
+			SetDeveloperDataIndex(0).SetFieldDefinitionNumber(0).
+			SetFitBaseTypeId(basetype.Uint16).SetFieldName([]string{"Power"}).SetUnits([]string{"Watts"}),
+		mesgdef.NewFieldDescription(nil).
+			SetDeveloperDataIndex(1).SetFieldDefinitionNumber(3).
+			SetFitBaseTypeId(basetype.Sint16).SetScale(100).SetFieldName([]string{"core_temperature"}).SetUnits([]string{"°C"}),
+		mesgdef.NewFieldDescription(nil).
+			SetDeveloperDataIndex(1).SetFieldDefinitionNumber(4).
+			SetFitBaseTypeId(basetype.String).SetFieldName([]string{"notes"}))
+
+	for i := 0; i < 10; i++ {
+		record := mesgdef.NewRecord(nil).SetTimestamp(start.Add(time.Duration(i) * time.Second)).SetHeartRate(120)
+		record.DeveloperFields = append(record.DeveloperFields,
+			proto.DeveloperField{DeveloperDataIndex: 0, Num: 0, Value: proto.Uint16(uint16(250 + i))},
+			proto.DeveloperField{DeveloperDataIndex: 1, Num: 4, Value: proto.String("hot")})
+		if i != 5 {
+			record.DeveloperFields = append(record.DeveloperFields,
+				proto.DeveloperField{DeveloperDataIndex: 1, Num: 3, Value: proto.Int16(int16(3750 + i))})
+		}
+		file.Records = append(file.Records, record)
+	}
+
+	data := encodeFIT(t, file)
+
+	ts, err := FITFileToActivityTimeseries(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, []ExtraChannel{
+		{Name: "Power", Units: "Watts", FIT: Optional[FITDeveloperField]{Value: FITDeveloperField{
+			ApplicationID: strydID, DeveloperDataIndex: 0, FieldNumber: 0, BaseType: basetype.Uint16,
+		}, Valid: true}},
+		{Name: "core_temperature", Units: "°C", FIT: Optional[FITDeveloperField]{Value: FITDeveloperField{
+			DeveloperDataIndex: 1, FieldNumber: 3, BaseType: basetype.Sint16, Scale: 100,
+		}, Valid: true}},
+	}, ts.ExtraChannels)
+	require.Len(t, ts.Data, 10)
+	assert.Equal(t, map[string]float64{"Power": 250, "core_temperature": 37.5}, ts.Data[0].Extra)
+	assert.Equal(t, map[string]float64{"Power": 255}, ts.Data[5].Extra)
+
+	stream := StreamFITFile(bytes.NewReader(data))
+	var streamed []ActivityTimeseriesEntry
+	for entry := range stream.Entries() {
+		streamed = append(streamed, entry)
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, ts.Data, streamed)
+
+	cropped, err := ts.Crop(2*time.Second, 6*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, ts.ExtraChannels, cropped.ExtraChannels)
+	assert.Equal(t, ts.Data[2].Extra, cropped.Data[0].Extra)
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		act := &Activity{Sport: SportRunning, StartTime: start, ElapsedTime: 9}
+
+		exported, err := CreateFITFileInMemory(act, ts, SportRunning)
+		require.NoError(t, err)
+
+		_, parsed, err := ParseFITFile(exported)
+		require.NoError(t, err)
+		assert.Equal(t, ts.ExtraChannels, parsed.ExtraChannels)
+		for i := range ts.Data {
+			assert.InDeltaMapValues(t, ts.Data[i].Extra, parsed.Data[i].Extra, 1e-9)
+		}
+	})
+
+	t.Run("NewChannel", func(t *testing.T) {
+		ts := steadyRun(start, 10)
+		ts.ExtraChannels = []ExtraChannel{{Name: "smo2", Units: "%"}}
+		for i := range ts.Data {
+			ts.Data[i].Extra = map[string]float64{"smo2": 60.25 + float64(i)}
+		}
+
+		exported, err := CreateFITFileInMemory(&Activity{Sport: SportRunning, StartTime: start, ElapsedTime: 10}, ts, SportRunning)
+		require.NoError(t, err)
+
+		parsed, err := FITFileToActivityTimeseries(exported)
+		require.NoError(t, err)
+		require.Len(t, parsed.ExtraChannels, 1)
+		assert.Equal(t, "smo2", parsed.ExtraChannels[0].Name)
+		assert.Equal(t, "%", parsed.ExtraChannels[0].Units)
+		assert.Equal(t, basetype.Float64, parsed.ExtraChannels[0].FIT.Value.BaseType)
+		assert.Equal(t, map[string]float64{"smo2": 63.25}, parsed.Data[3].Extra)
+	})
+
+	t.Run("TooManyChannels", func(t *testing.T) {
+		ts := steadyRun(start, 10)
+		for i := 0; i <= 256; i++ {
+			ts.ExtraChannels = append(ts.ExtraChannels, ExtraChannel{Name: fmt.Sprintf("channel_%d", i)})
+		}
+
+		_, err := CreateFITFileInMemory(&Activity{Sport: SportRunning, StartTime: start, ElapsedTime: 10}, ts, SportRunning)
+		assert.ErrorIs(t, err, ErrTooManyFITFields)
+
+		ts.ExtraChannels = ts.ExtraChannels[:256]
+		_, err = CreateFITFileInMemory(&Activity{Sport: SportRunning, StartTime: start, ElapsedTime: 10}, ts, SportRunning)
+		assert.NoError(t, err)
+	})
+}
+
+func decodeFIT(t *testing.T, data []byte) *filedef.Activity {
+	t.Helper()
+
+	fit, err := decoder.New(bytes.NewReader(data)).Decode()
+	require.NoError(t, err)
+
+	return filedef.NewActivity(fit.Messages...)
+}
+
+func encodeFIT(t *testing.T, file *filedef.Activity) []byte {
+	t.Helper()
+
+	fit := file.ToFIT(nil)
+
+	var buf bytes.Buffer
+	require.NoError(t, encoder.New(&buf, encoder.WithProtocolVersion(proto.V2)).Encode(&fit))
+
+	return buf.Bytes()
+}
@@ -1,8 +1,8 @@
 package stride
 
 import (
-	"fmt"
 	"math"
+	"time"
 )
 
 // formatPace helper converts speed (m/s) into standard MM:SS/km pace
@@ -16,8 +16,5 @@ func formatPace(speedMs float64) string {
 		return ""
 	}
 
-	mins := int(secPerKm) / 60
-	secs := int(secPerKm) % 60
-
-	return fmt.Sprintf("%d:%02d", mins, secs)
+	return PaceFromDuration(time.Duration(secPerKm * float64(time.Second))).String()
 }
@@ -1,12 +1,21 @@
 package stride
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/twpayne/go-polyline"
 )
 
+var (
+	ErrNoPositionData     = errors.New("no GPS positions found")
+	ErrNotEnoughPositions = errors.New("a line needs at least two GPS positions")
+)
+
 func PolylineToWKT(poly string) (string, error) {
 	coords, _, err := polyline.DecodeCoords([]byte(poly))
 	if err != nil {
@@ -22,9 +31,168 @@ func PolylineToWKT(poly string) (string, error) {
 	for i, c := range coords {
 		lat := c[0]
 		lon := c[1]
-		points[i] = fmt.Sprintf("%f %f", lon, lat)
+		points[i] = wktFloat(lon) + " " + wktFloat(lat)
 	}
 
 	wkt := fmt.Sprintf("LINESTRING(%s)", strings.Join(points, ", "))
 	return wkt, nil
 }
+
+type PolylineConfig struct {
+	Simplify  SimplifyConfig // Simplification of the track before encoding (default: every position)
+	Precision int            // Decimal digits of the coordinates, 5 for Google and Strava, 6 for OSRM and Valhalla (default: 5)
+}
+
+func (c PolylineConfig) ApplyDefaults() PolylineConfig {
+	if c.Precision == 0 {
+		c.Precision = 5
+	}
+	c.Simplify = c.Simplify.ApplyDefaults()
+
+	return c
+}
+
+// TimeseriesToPolyline encodes the positions of the timeseries as an encoded polyline,
+// like the SummaryPolyline of Strava activities. A simplification tolerance of a few
+// meters gives a detail polyline, tens of meters a summary one for maps of a list.
+func TimeseriesToPolyline(ts *ActivityTimeseries, config PolylineConfig) (string, error) {
+	config = config.ApplyDefaults()
+
+	simplified, err := ts.SimplifyTrack(config.Simplify)
+	if err != nil {
+		return "", err
+	}
+
+	if len(simplified.Data) == 0 {
+		return "", ErrNoPositionData
+	}
+
+	coords := make([][]float64, len(simplified.Data))
+	for i, d := range simplified.Data {
+		coords[i] = []float64{d.Latitude.Value, d.Longitude.Value}
+	}
+
+	codec := polyline.Codec{Dim: 2, Scale: math.Pow10(config.Precision)}
+	return string(codec.EncodeCoords(nil, coords)), nil
+}
+
+const (
+	sridWGS84 = 4326
+
+	wkbLittleEndian = 1
+	wkbLineStringZM = 3002 // ISO type of a LINESTRING ZM
+	ewkbLineString  = 2
+	ewkbFlagZ       = 0x80000000
+	ewkbFlagM       = 0x40000000
+	ewkbFlagSRID    = 0x20000000
+)
+
+type GeometryConfig struct {
+	Simplify SimplifyConfig // Simplification of the track before writing (default: every position)
+}
+
+func (c GeometryConfig) ApplyDefaults() GeometryConfig {
+	c.Simplify = c.Simplify.ApplyDefaults()
+
+	return c
+}
+
+// TimeseriesToWKT writes the track as a LINESTRING ZM, with altitude as Z and the offset
+// in seconds as M, e.g. for ST_GeomFromText(wkt, 4326).
+func TimeseriesToWKT(ts *ActivityTimeseries, config GeometryConfig) (string, error) {
+	line, err := timeseriesLineZM(ts, config)
+	if err != nil {
+		return "", err
+	}
+
+	points := make([]string, len(line))
+	for i, p := range line {
+		points[i] = wktFloat(p[0]) + " " + wktFloat(p[1]) + " " + wktFloat(p[2]) + " " + wktFloat(p[3])
+	}
+
+	return fmt.Sprintf("LINESTRING ZM (%s)", strings.Join(points, ", ")), nil
+}
+
+// TimeseriesToWKB writes the track as an ISO WKB LINESTRING ZM in little endian, with
+// altitude as Z and the offset in seconds as M.
+func TimeseriesToWKB(ts *ActivityTimeseries, config GeometryConfig) ([]byte, error) {
+	line, err := timeseriesLineZM(ts, config)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, 9+len(line)*32)
+	buf = append(buf, wkbLittleEndian)
+	buf = binary.LittleEndian.AppendUint32(buf, wkbLineStringZM)
+
+	return appendWKBPoints(buf, line), nil
+}
+
+// TimeseriesToEWKB writes the track as a PostGIS EWKB LINESTRING ZM with SRID 4326, which
+// can be inserted as is into a geometry(LineStringZM, 4326) column.
+func TimeseriesToEWKB(ts *ActivityTimeseries, config GeometryConfig) ([]byte, error) {
+	line, err := timeseriesLineZM(ts, config)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, 13+len(line)*32)
+	buf = append(buf, wkbLittleEndian)
+	buf = binary.LittleEndian.AppendUint32(buf, ewkbLineString|ewkbFlagZ|ewkbFlagM|ewkbFlagSRID)
+	buf = binary.LittleEndian.AppendUint32(buf, sridWGS84)
+
+	return appendWKBPoints(buf, line), nil
+}
+
+func appendWKBPoints(buf []byte, line [][4]float64) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(line)))
+	for _, p := range line {
+		for _, v := range p {
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+		}
+	}
+	return buf
+}
+
+// timeseriesLineZM returns the positions of the timeseries as longitude, latitude,
+// altitude and offset. A line has a Z for every position, so missing altitudes take the
+// previous one, or the first one at the start, and zero without any.
+func timeseriesLineZM(ts *ActivityTimeseries, config GeometryConfig) ([][4]float64, error) {
+	config = config.ApplyDefaults()
+
+	simplified, err := ts.SimplifyTrack(config.Simplify)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(simplified.Data) == 0 {
+		return nil, ErrNoPositionData
+	}
+
+	if len(simplified.Data) < 2 {
+		return nil, ErrNotEnoughPositions
+	}
+
+	var altitude float64
+	for _, d := range simplified.Data {
+		if d.Altitude.Valid {
+			altitude = d.Altitude.Value
+			break
+		}
+	}
+
+	line := make([][4]float64, len(simplified.Data))
+	for i, d := range simplified.Data {
+		if d.Altitude.Valid {
+			altitude = d.Altitude.Value
+		}
+		line[i] = [4]float64{d.Longitude.Value, d.Latitude.Value, altitude, float64(d.Offset)}
+	}
+
+	return line, nil
+}
+
+// wktFloat writes the shortest decimal that reads back as the same float64.
+func wktFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,126 @@
+package stride_test
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-polyline"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func TestTimeseriesToPolyline(t *testing.T) {
+	start := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	ts := hillRoute(start)
+
+	encoded, err := TimeseriesToPolyline(ts, PolylineConfig{})
+	require.NoError(t, err)
+
+	coords, _, err := polyline.DecodeCoords([]byte(encoded))
+	require.NoError(t, err)
+	require.Len(t, coords, len(ts.Data))
+	for i, c := range coords {
+		assert.InDelta(t, ts.Data[i].Latitude.Value, c[0], 1e-5)
+		assert.InDelta(t, ts.Data[i].Longitude.Value, c[1], 1e-5)
+	}
+
+	t.Run("Simplified", func(t *testing.T) {
+		summary, err := TimeseriesToPolyline(ts, PolylineConfig{Simplify: SimplifyConfig{ToleranceM: 10}})
+		require.NoError(t, err)
+		assert.Less(t, len(summary), len(encoded)/20)
+
+		coords, _, err := polyline.DecodeCoords([]byte(summary))
+		require.NoError(t, err)
+		assert.Len(t, coords, 3)
+
+		wkt, err := PolylineToWKT(summary)
+		require.NoError(t, err)
+		assert.Equal(t, "LINESTRING(7 45, 7 45.00899, 7.02544 45.00899)", wkt)
+	})
+
+	t.Run("Precision", func(t *testing.T) {
+		encoded, err := TimeseriesToPolyline(ts, PolylineConfig{Precision: 6})
+		require.NoError(t, err)
+
+		coords, _, err := polyline.Codec{Dim: 2, Scale: 1e6}.DecodeCoords([]byte(encoded))
+		require.NoError(t, err)
+		assert.InDelta(t, ts.Data[150].Longitude.Value, coords[150][1], 1e-6)
+	})
+
+	t.Run("NoPositions", func(t *testing.T) {
+		_, err := TimeseriesToPolyline(&ActivityTimeseries{StartTime: start, Data: []ActivityTimeseriesEntry{{Offset: 0}}}, PolylineConfig{})
+		assert.ErrorIs(t, err, ErrNoPositionData)
+	})
+}
+
+func TestTimeseriesToWKT(t *testing.T) {
+	start := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	ts := &ActivityTimeseries{StartTime: start, Data: []ActivityTimeseriesEntry{
+		{Offset: 0, Latitude: Optional[float64]{Value: 45.1234567, Valid: true}, Longitude: Optional[float64]{Value: 7.7654321, Valid: true}},
+		{Offset: 1, HeartRate: Optional[uint8]{Value: 120, Valid: true}},
+		{Offset: 2, Latitude: Optional[float64]{Value: 45.1234667, Valid: true}, Longitude: Optional[float64]{Value: 7.7654321, Valid: true}, Altitude: Optional[float64]{Value: 512.5, Valid: true}},
+		{Offset: 3, Latitude: Optional[float64]{Value: 45.1234767, Valid: true}, Longitude: Optional[float64]{Value: 7.7654421, Valid: true}},
+		{Offset: 4, Latitude: Optional[float64]{Value: 45.1234867, Valid: true}, Longitude: Optional[float64]{Value: 7.7654521, Valid: true}, Altitude: Optional[float64]{Value: 513, Valid: true}},
+	}}
+
+	wkt, err := TimeseriesToWKT(ts, GeometryConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, "LINESTRING ZM (7.7654321 45.1234567 512.5 0, 7.7654321 45.1234667 512.5 2, 7.7654421 45.1234767 512.5 3, 7.7654521 45.1234867 513 4)", wkt)
+
+	t.Run("WKB", func(t *testing.T) {
+		data, err := TimeseriesToWKB(ts, GeometryConfig{})
+		require.NoError(t, err)
+		require.Len(t, data, 1+4+4+4*32)
+
+		assert.Equal(t, byte(1), data[0])
+		assert.Equal(t, uint32(3002), binary.LittleEndian.Uint32(data[1:]))
+		assert.Equal(t, uint32(4), binary.LittleEndian.Uint32(data[5:]))
+		assert.Equal(t, [4]float64{7.7654321, 45.1234667, 512.5, 2}, wkbPoint(data[9+32:]))
+	})
+
+	t.Run("EWKB", func(t *testing.T) {
+		data, err := TimeseriesToEWKB(ts, GeometryConfig{})
+		require.NoError(t, err)
+		require.Len(t, data, 1+4+4+4+4*32)
+
+		// PostGIS: SELECT ST_AsEWKT('\x01020000e0e6100000...'::geometry)
+		assert.Equal(t, "01020000e0e610000004000000", hex.EncodeToString(data[:13]))
+		assert.Equal(t, [4]float64{7.7654521, 45.1234867, 513, 4}, wkbPoint(data[13+3*32:]))
+	})
+
+	t.Run("Simplified", func(t *testing.T) {
+		route := hillRoute(start)
+		wkt, err := TimeseriesToWKT(route, GeometryConfig{Simplify: SimplifyConfig{ToleranceM: 5}})
+		require.NoError(t, err)
+
+		corner, end := route.Data[100], route.Data[300]
+		expected := fmt.Sprintf("LINESTRING ZM (7 45 500 0, 7 %s 500 300, %s %s 500 900)",
+			strconv.FormatFloat(corner.Latitude.Value, 'f', -1, 64),
+			strconv.FormatFloat(end.Longitude.Value, 'f', -1, 64),
+			strconv.FormatFloat(end.Latitude.Value, 'f', -1, 64))
+		assert.Equal(t, expected, wkt)
+	})
+
+	t.Run("NotEnoughPositions", func(t *testing.T) {
+		_, err := TimeseriesToEWKB(&ActivityTimeseries{StartTime: start, Data: ts.Data[1:2]}, GeometryConfig{})
+		assert.ErrorIs(t, err, ErrNoPositionData)
+
+		_, err = TimeseriesToWKT(&ActivityTimeseries{StartTime: start, Data: ts.Data[:2]}, GeometryConfig{})
+		assert.ErrorIs(t, err, ErrNotEnoughPositions)
+	})
+}
+
+func wkbPoint(data []byte) [4]float64 {
+	var p [4]float64
+	for i := range p {
+		p[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[i*8:]))
+	}
+	return p
+}
@@ -0,0 +1,206 @@
+package stride
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+type GeoJSONExportConfig struct {
+	Points bool // Add a Point feature per sample with its time, heart rate and pace (default: false)
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// CreateGeoJSONFileInMemory writes the track of the activity as a GeoJSON
+// FeatureCollection. The first feature is a LineString with the activity summary as
+// properties; samples without a position are left out.
+func CreateGeoJSONFileInMemory(act *Activity, ts *ActivityTimeseries, config GeoJSONExportConfig) ([]byte, error) {
+	var positions []ActivityTimeseriesEntry
+	for _, d := range ts.Data {
+		if d.HasGPS() {
+			positions = append(positions, d)
+		}
+	}
+
+	if len(positions) == 0 {
+		return nil, ErrNoPositionData
+	}
+
+	// Altitude is written only when every position has one, GeoJSON positions of a
+	// geometry should have the same number of dimensions
+	withAltitude := true
+	for _, d := range positions {
+		withAltitude = withAltitude && d.Altitude.Valid
+	}
+
+	line := make([][]float64, len(positions))
+	for i, d := range positions {
+		line[i] = geoJSONPosition(d.Latitude.Value, d.Longitude.Value, d.Altitude, withAltitude)
+	}
+
+	collection := geoJSONFeatureCollection{
+		Type: "FeatureCollection",
+		Features: []geoJSONFeature{{
+			Type:       "Feature",
+			Geometry:   geoJSONGeometry{Type: "LineString", Coordinates: line},
+			Properties: activityGeoJSONProperties(act),
+		}},
+	}
+
+	if config.Points {
+		for _, d := range positions {
+			collection.Features = append(collection.Features, geoJSONFeature{
+				Type:       "Feature",
+				Geometry:   geoJSONGeometry{Type: "Point", Coordinates: geoJSONPosition(d.Latitude.Value, d.Longitude.Value, d.Altitude, d.Altitude.Valid)},
+				Properties: entryGeoJSONProperties(ts.StartTime, d),
+			})
+		}
+	}
+
+	return json.Marshal(collection)
+}
+
+// CreateGeoJSONCourseFileInMemory writes the course track as a LineString feature
+// followed by a Point feature per course point.
+func CreateGeoJSONCourseFileInMemory(course *Course) ([]byte, error) {
+	if len(course.Track) == 0 {
+		return nil, ErrNoPositionData
+	}
+	if err := course.checkPoints(); err != nil {
+		return nil, err
+	}
+
+	withAltitude := true
+	for _, p := range course.Track {
+		withAltitude = withAltitude && p.Altitude.Valid
+	}
+
+	line := make([][]float64, len(course.Track))
+	for i, p := range course.Track {
+		line[i] = geoJSONPosition(p.Latitude, p.Longitude, p.Altitude, withAltitude)
+	}
+
+	properties := map[string]any{
+		"name":       course.Name,
+		"sport":      course.Sport,
+		"distance_m": math.Round(course.Track[len(course.Track)-1].DistanceM),
+	}
+
+	collection := geoJSONFeatureCollection{
+		Type: "FeatureCollection",
+		Features: []geoJSONFeature{{
+			Type:       "Feature",
+			Geometry:   geoJSONGeometry{Type: "LineString", Coordinates: line},
+			Properties: properties,
+		}},
+	}
+
+	for _, cp := range course.Points {
+		p := course.Track[cp.Index]
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "Point", Coordinates: geoJSONPosition(p.Latitude, p.Longitude, p.Altitude, p.Altitude.Valid)},
+			Properties: map[string]any{
+				"type":       cp.Type,
+				"name":       cp.Name,
+				"distance_m": math.Round(p.DistanceM),
+			},
+		})
+	}
+
+	return json.Marshal(collection)
+}
+
+// geoJSONPosition returns a position in GeoJSON order, longitude first. Degrees are
+// rounded to 7 decimals, about a centimeter.
+func geoJSONPosition(lat, lon float64, altitude Optional[float64], withAltitude bool) []float64 {
+	position := []float64{roundTo(lon, 7), roundTo(lat, 7)}
+	if withAltitude {
+		position = append(position, roundTo(altitude.Value, 1))
+	}
+	return position
+}
+
+func activityGeoJSONProperties(act *Activity) map[string]any {
+	properties := map[string]any{
+		"sport":          act.Sport,
+		"start_time":     act.StartTime.UTC().Format(time.RFC3339),
+		"elapsed_time_s": act.ElapsedTime,
+		"moving_time_s":  act.MovingTime,
+		"distance_m":     act.Distance,
+		"avg_speed_mps":  act.AvgSpeed.MetersPerSecond(),
+	}
+
+	if act.Provider != "" {
+		properties["provider"] = act.Provider
+	}
+
+	if pace := formatPace(act.AvgSpeed.MetersPerSecond()); pace != "" {
+		properties["avg_pace"] = pace
+	}
+
+	if act.AvgHR.Valid {
+		properties["avg_hr"] = act.AvgHR.Value
+	}
+
+	if act.MaxHR.Valid {
+		properties["max_hr"] = act.MaxHR.Value
+	}
+
+	if act.ElevationGain.Valid {
+		properties["elevation_gain_m"] = act.ElevationGain.Value
+	}
+
+	if act.ElevationLoss.Valid {
+		properties["elevation_loss_m"] = act.ElevationLoss.Value
+	}
+
+	return properties
+}
+
+func entryGeoJSONProperties(startTime time.Time, d ActivityTimeseriesEntry) map[string]any {
+	properties := map[string]any{
+		"time":   startTime.Add(time.Duration(d.Offset) * time.Second).UTC().Format(time.RFC3339),
+		"offset": d.Offset,
+	}
+
+	if d.HeartRate.Valid {
+		properties["heart_rate"] = d.HeartRate.Value
+	}
+
+	if d.Velocity.Valid {
+		properties["speed_mps"] = d.Velocity.Value.MetersPerSecond()
+		if pace := formatPace(d.Velocity.Value.MetersPerSecond()); pace != "" {
+			properties["pace"] = pace
+		}
+	}
+
+	if d.Distance.Valid {
+		properties["distance_m"] = d.Distance.Value
+	}
+
+	if d.Cadence.Valid {
+		properties["cadence"] = d.Cadence.Value
+	}
+
+	if d.Power.Valid {
+		properties["power"] = d.Power.Value
+	}
+
+	return properties
+}
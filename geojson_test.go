@@ -0,0 +1,126 @@
+package stride_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+type geoJSON struct {
+	Type     string `json:"type"`
+	Features []struct {
+		Type     string `json:"type"`
+		Geometry struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	} `json:"features"`
+}
+
+func TestCreateGeoJSONFileInMemory(t *testing.T) {
+	start := time.Date(2025, 5, 1, 6, 30, 0, 0, time.UTC)
+	ts := steadyRun(start, 10)
+	ts.Data[3].Latitude = Optional[float64]{}
+	for i := range ts.Data {
+		ts.Data[i].Velocity = Optional[Speed]{Value: 3000, Valid: true}
+	}
+	act := &Activity{
+		Provider:    ProviderStrava,
+		Sport:       SportRunning,
+		StartTime:   start,
+		ElapsedTime: 10,
+		MovingTime:  10,
+		Distance:    30,
+		AvgSpeed:    3000,
+		AvgHR:       Optional[uint8]{Value: 150, Valid: true},
+	}
+
+	data, err := CreateGeoJSONFileInMemory(act, ts, GeoJSONExportConfig{})
+	require.NoError(t, err)
+
+	var doc geoJSON
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, "FeatureCollection", doc.Type)
+	require.Len(t, doc.Features, 1)
+
+	line := doc.Features[0]
+	assert.Equal(t, "LineString", line.Geometry.Type)
+	var coordinates [][]float64
+	require.NoError(t, json.Unmarshal(line.Geometry.Coordinates, &coordinates))
+	require.Len(t, coordinates, 10)
+	assert.Equal(t, []float64{7, 45, 100}, coordinates[0])
+	assert.Equal(t, []float64{7, 45.000108, 100.4}, coordinates[3])
+
+	assert.Equal(t, "running", line.Properties["sport"])
+	assert.Equal(t, "strava", line.Properties["provider"])
+	assert.Equal(t, "2025-05-01T06:30:00Z", line.Properties["start_time"])
+	assert.Equal(t, 30.0, line.Properties["distance_m"])
+	assert.Equal(t, "5:33", line.Properties["avg_pace"])
+	assert.Equal(t, 150.0, line.Properties["avg_hr"])
+	assert.NotContains(t, line.Properties, "max_hr")
+
+	t.Run("Points", func(t *testing.T) {
+		data, err := CreateGeoJSONFileInMemory(act, ts, GeoJSONExportConfig{Points: true})
+		require.NoError(t, err)
+
+		var doc geoJSON
+		require.NoError(t, json.Unmarshal(data, &doc))
+		require.Len(t, doc.Features, 11)
+
+		point := doc.Features[4]
+		assert.Equal(t, "Point", point.Geometry.Type)
+		assert.JSONEq(t, `[7, 45.000108, 100.4]`, string(point.Geometry.Coordinates))
+		assert.Equal(t, "2025-05-01T06:30:04Z", point.Properties["time"])
+		assert.Equal(t, 4.0, point.Properties["offset"])
+		assert.Equal(t, 150.0, point.Properties["heart_rate"])
+		assert.Equal(t, "5:33", point.Properties["pace"])
+		assert.Equal(t, 3.0, point.Properties["speed_mps"])
+	})
+
+	t.Run("MissingAltitude", func(t *testing.T) {
+		ts := steadyRun(start, 3)
+		ts.Data[2].Altitude = Optional[float64]{}
+
+		data, err := CreateGeoJSONFileInMemory(act, ts, GeoJSONExportConfig{})
+		require.NoError(t, err)
+
+		var doc geoJSON
+		require.NoError(t, json.Unmarshal(data, &doc))
+		var coordinates [][]float64
+		require.NoError(t, json.Unmarshal(doc.Features[0].Geometry.Coordinates, &coordinates))
+		for _, c := range coordinates {
+			assert.Len(t, c, 2)
+		}
+	})
+
+	t.Run("NoPositions", func(t *testing.T) {
+		_, err := CreateGeoJSONFileInMemory(act, &ActivityTimeseries{StartTime: start, Data: []ActivityTimeseriesEntry{{Offset: 0}}}, GeoJSONExportConfig{})
+		assert.ErrorIs(t, err, ErrNoPositionData)
+	})
+}
+
+func TestCreateGeoJSONCourseFileInMemory(t *testing.T) {
+	course, err := NewCourse("Hill", SportRunning, hillRoute(time.Date(2025, 5, 1, 6, 30, 0, 0, time.UTC)), CourseConfig{})
+	require.NoError(t, err)
+
+	data, err := CreateGeoJSONCourseFileInMemory(course)
+	require.NoError(t, err)
+
+	var doc geoJSON
+	require.NoError(t, json.Unmarshal(data, &doc))
+	require.Len(t, doc.Features, 1+len(course.Points))
+	assert.Equal(t, "Hill", doc.Features[0].Properties["name"])
+	assert.InDelta(t, 3000, doc.Features[0].Properties["distance_m"], 5)
+
+	for i, cp := range course.Points {
+		feature := doc.Features[i+1]
+		assert.Equal(t, "Point", feature.Geometry.Type)
+		assert.Equal(t, string(cp.Type), feature.Properties["type"])
+	}
+}
@@ -4,18 +4,42 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/tkrajina/gpxgo/gpx"
 )
 
 var (
-	ErrFailedToParseGPXFile = errors.New("failed to parse GPX file")
-	ErrNoTrackPoints        = errors.New("no track points found")
-	ErrNoTracksOrSegments   = errors.New("no tracks or segments found in GPX")
+	ErrFailedToParseGPXFile     = errors.New("failed to parse GPX file")
+	ErrNoTrackPoints            = errors.New("no track points found")
+	ErrNoTracksOrSegments       = errors.New("no tracks or segments found in GPX")
+	ErrInvalidGPXExtensionCodec = errors.New("invalid GPX extension codec")
 )
 
 func CreateGPXFileInMemory(act *Activity, ts *ActivityTimeseries) ([]byte, error) {
+	return CreateGPXFileWithConfig(act, ts, GPXExportConfig{})
+}
+
+type GPXExportConfig struct {
+	Extensions *GPXExtensionRegistry // Codecs of the track point extensions (default: DefaultGPXExtensions())
+}
+
+func (c GPXExportConfig) ApplyDefaults() GPXExportConfig {
+	if c.Extensions == nil {
+		c.Extensions = DefaultGPXExtensions()
+	}
+
+	return c
+}
+
+// CreateGPXFileWithConfig writes the activity as a GPX track. Each channel is written
+// by the first codec of config.Extensions that is not read only.
+func CreateGPXFileWithConfig(act *Activity, ts *ActivityTimeseries, config GPXExportConfig) ([]byte, error) {
+	config = config.ApplyDefaults()
+
 	sportGPXName := sportToGPXName(act.Sport)
 	sportGPXType := sportToGPXType(act.Sport)
 
@@ -26,6 +50,13 @@ func CreateGPXFileInMemory(act *Activity, ts *ActivityTimeseries) ([]byte, error
 		Time:    &act.StartTime,
 	}
 
+	writers := config.Extensions.writers()
+	for _, codec := range writers {
+		if codec.Namespace != "" {
+			gpxFile.RegisterNamespace(codec.Prefix, codec.Namespace)
+		}
+	}
+
 	track := gpx.GPXTrack{
 		Name: sportGPXName,
 		Type: sportGPXType,
@@ -56,14 +87,11 @@ func CreateGPXFileInMemory(act *Activity, ts *ActivityTimeseries) ([]byte, error
 			point.Point.Elevation = *gpx.NewNullableFloat64(elevation)
 		}
 
-		if d.HeartRate.Valid {
-			hrNode := point.Extensions.GetOrCreateNode("http://www.garmin.com/xmlschemas/TrackPointExtension/v1", "TrackPointExtension", "hr")
-			hrNode.Data = fmt.Sprintf("%d", d.HeartRate.Value)
-		}
-
-		if d.Cadence.Valid {
-			cadNode := point.Extensions.GetOrCreateNode("http://www.garmin.com/xmlschemas/TrackPointExtension/v1", "TrackPointExtension", "cad")
-			cadNode.Data = fmt.Sprintf("%d", d.Cadence.Value)
+		for _, codec := range writers {
+			if data, ok := codec.encode(d); ok {
+				node := point.Extensions.GetOrCreateNode(gpx.NamespaceURL(codec.Namespace), codec.Path...)
+				node.Data = data
+			}
 		}
 
 		segment.Points = append(segment.Points, point)
@@ -83,77 +111,432 @@ func CreateGPXFileInMemory(act *Activity, ts *ActivityTimeseries) ([]byte, error
 	return xmlBytes, nil
 }
 
-func ParseGPXFileFromMemory(data []byte) (*Activity, *ActivityTimeseries, error) {
+type GPXImportConfig struct {
+	SplitTracks bool                  // Return each track as a separate activity instead of joining them (default: false)
+	Extensions  *GPXExtensionRegistry // Codecs of the track point extensions (default: DefaultGPXExtensions())
+}
+
+func (c GPXImportConfig) ApplyDefaults() GPXImportConfig {
+	if c.Extensions == nil {
+		c.Extensions = DefaultGPXExtensions()
+	}
+
+	return c
+}
+
+// GPXWarning reports data of a GPX file that was skipped or only partially read.
+// Positions are zero based, with Point -1 for warnings about a whole segment and
+// Segment -1 for warnings about a whole track.
+type GPXWarning struct {
+	Track   int
+	Segment int
+	Point   int
+	Message string
+}
+
+type GPXParseResult struct {
+	Activities []*Activity
+	Timeseries []*ActivityTimeseries // Timeseries[i] belongs to Activities[i]
+	Warnings   []GPXWarning
+}
+
+func (r *GPXParseResult) warn(track, segment, point int, format string, args ...any) {
+	r.Warnings = append(r.Warnings, GPXWarning{Track: track, Segment: segment, Point: point, Message: fmt.Sprintf(format, args...)})
+}
+
+// ParseGPXFile reads every track and segment of a GPX file. Devices start a new segment
+// after a pause, so the time between segments, and between joined tracks, becomes a
+// pause of the timeseries. Points without a time or going back in time are skipped
+// with a warning.
+func ParseGPXFile(data []byte, config GPXImportConfig) (*GPXParseResult, error) {
+	config = config.ApplyDefaults()
+
 	gpxFile, err := gpx.ParseBytes(data)
 	if err != nil {
-		return nil, nil, fmt.Errorf("%w: %w", ErrFailedToParseGPXFile, err)
+		return nil, fmt.Errorf("%w: %w", ErrFailedToParseGPXFile, err)
+	}
+
+	result := &GPXParseResult{}
+
+	var act *Activity
+	var ts *ActivityTimeseries
+	finish := func() {
+		if ts != nil && len(ts.Data) > 0 {
+			act.ElapsedTime = uint32(ts.MaxOffset())
+			ts.ExtraChannels = config.Extensions.extraChannels(ts.Data)
+			result.Activities = append(result.Activities, act)
+			result.Timeseries = append(result.Timeseries, ts)
+		}
+		act, ts = nil, nil
+	}
+
+	segments := 0
+	for ti, track := range gpxFile.Tracks {
+		if config.SplitTracks {
+			finish()
+		}
+		if act == nil {
+			act = &Activity{Sport: gpxNameToSport(track.Type, track.Name)}
+			ts = &ActivityTimeseries{}
+		}
+
+		if len(track.Segments) == 0 {
+			result.warn(ti, -1, -1, "track has no segments")
+		}
+
+		for si, segment := range track.Segments {
+			segments++
+			if len(segment.Points) == 0 {
+				result.warn(ti, si, -1, "segment has no points")
+				continue
+			}
+
+			first := true
+			for pi, p := range segment.Points {
+				if p.Timestamp.IsZero() {
+					result.warn(ti, si, pi, "point has no time, skipped")
+					continue
+				}
+
+				if len(ts.Data) == 0 {
+					act.StartTime = p.Timestamp
+					ts.StartTime = p.Timestamp
+				}
+
+				offset := int(p.Timestamp.Sub(ts.StartTime).Seconds())
+				last := -1
+				if len(ts.Data) > 0 {
+					last = ts.Data[len(ts.Data)-1].Offset
+				}
+
+				if offset < last {
+					result.warn(ti, si, pi, "point at %s goes back in time, skipped", p.Timestamp.Format(time.RFC3339))
+					continue
+				}
+
+				// The pause starts after the last point, which was still recorded
+				if first && last >= 0 && offset > last+1 {
+					ts.Pauses = append(ts.Pauses, Pause{StartOffset: last + 1, EndOffset: offset, Reason: PauseReasonSegment})
+				}
+				first = false
+
+				entry := ActivityTimeseriesEntry{
+					Offset:    offset,
+					Latitude:  Optional[float64]{Value: p.Point.Latitude, Valid: p.Point.Latitude != 0},
+					Longitude: Optional[float64]{Value: p.Point.Longitude, Valid: p.Point.Longitude != 0},
+				}
+
+				if p.Point.Elevation.NotNull() && !math.IsNaN(p.Point.Elevation.Value()) {
+					entry.Altitude = Optional[float64]{Value: p.Point.Elevation.Value(), Valid: true}
+				}
+
+				for _, err := range config.Extensions.decode(&entry, p.Extensions.Nodes) {
+					result.warn(ti, si, pi, "%s", err)
+				}
+
+				ts.Data = append(ts.Data, entry)
+			}
+		}
+	}
+	finish()
+
+	if segments == 0 {
+		return nil, ErrNoTracksOrSegments
+	}
+
+	if len(result.Activities) == 0 {
+		return nil, ErrNoTrackPoints
+	}
+
+	return result, nil
+}
+
+// ParseGPXFileFromMemory reads all the tracks of a GPX file as a single activity. Use
+// ParseGPXFile for the warnings or for one activity per track.
+func ParseGPXFileFromMemory(data []byte) (*Activity, *ActivityTimeseries, error) {
+	result, err := ParseGPXFile(data, GPXImportConfig{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result.Activities[0], result.Timeseries[0], nil
+}
+
+// Namespaces of the built-in GPX extension codecs.
+const (
+	GPXNamespaceTrackPointV1 = "http://www.garmin.com/xmlschemas/TrackPointExtension/v1"
+	GPXNamespaceTrackPointV2 = "http://www.garmin.com/xmlschemas/TrackPointExtension/v2"
+	GPXNamespacePower        = "http://www.garmin.com/xmlschemas/PowerExtension/v1"
+	GPXNamespaceGPXData      = "http://www.cluetrust.com/XML/GPXDATA/1/0"
+)
+
+// gpxDocumentNamespaces are the namespaces of the GPX schema. Extension elements written
+// without a prefix end up in the default namespace of the document, so they match codecs
+// without a namespace.
+var gpxDocumentNamespaces = []string{
+	"http://www.topografix.com/GPX/1/1",
+	"http://www.topografix.com/GPX/1/0",
+}
+
+// GPXExtensionCodec maps a track point extension element to a timeseries channel.
+// Values are in the units of ActivityTimeseriesEntry, except velocity in m/s.
+type GPXExtensionCodec struct {
+	Namespace string   // Namespace URI, empty for elements without one
+	Prefix    string   // Namespace prefix when writing
+	Path      []string // Element names from <extensions> down to the value
+	Channel   Channel  // Channel of the value; position and moving are not supported
+	Extra     string   // Name of an extra channel, used instead of Channel
+	Units     string   // Units of the extra channel
+	Scale     float64  // File units per channel unit (default: 1)
+	ReadOnly  bool     // Only read the element, another codec writes the channel
+}
+
+func (c GPXExtensionCodec) scale() float64 {
+	if c.Scale == 0 {
+		return 1
+	}
+	return c.Scale
+}
+
+// target identifies the channel of the codec among both standard and extra channels.
+func (c GPXExtensionCodec) target() string {
+	if c.Extra != "" {
+		return "extra:" + c.Extra
+	}
+	return string(c.Channel)
+}
+
+func (c GPXExtensionCodec) matches(namespace string, path []string) bool {
+	if c.Namespace != namespace && (c.Namespace != "" || !slices.Contains(gpxDocumentNamespaces, namespace)) {
+		return false
+	}
+	return slices.Equal(c.Path, path)
+}
+
+func (c GPXExtensionCodec) decode(entry *ActivityTimeseriesEntry, data string) error {
+	value, err := strconv.ParseFloat(strings.TrimSpace(data), 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("invalid value %q for %s", data, strings.Join(c.Path, "/"))
 	}
+	value /= c.scale()
 
-	if len(gpxFile.Tracks) == 0 || len(gpxFile.Tracks[0].Segments) == 0 {
-		return nil, nil, ErrNoTracksOrSegments
+	if c.Extra != "" {
+		if entry.Extra == nil {
+			entry.Extra = make(map[string]float64)
+		}
+		entry.Extra[c.Extra] = value
+		return nil
 	}
 
-	track := gpxFile.Tracks[0]
-	segment := track.Segments[0]
-	points := segment.Points
+	if !setGPXChannelValue(entry, c.Channel, value) {
+		return fmt.Errorf("invalid value %q for %s", data, strings.Join(c.Path, "/"))
+	}
 
-	if len(points) == 0 {
-		return nil, nil, ErrNoTrackPoints
+	return nil
+}
+
+func (c GPXExtensionCodec) encode(entry ActivityTimeseriesEntry) (string, bool) {
+	var value float64
+	var ok bool
+	if c.Extra != "" {
+		value, ok = entry.Extra[c.Extra]
+	} else {
+		value, ok = entry.channelValue(c.Channel)
+	}
+	if !ok {
+		return "", false
 	}
 
-	sport := gpxNameToSport(track.Type, track.Name)
+	// Rounding first keeps float noise such as 10.799999999 out of the file
+	value = math.Round(value*c.scale()*1e6) / 1e6
+	return strconv.FormatFloat(value, 'f', -1, 64), true
+}
+
+// GPXExtensionRegistry holds the codecs used to read and write GPX track point extensions.
+type GPXExtensionRegistry struct {
+	codecs []GPXExtensionCodec
+}
 
-	startTime := points[0].Timestamp
-	endTime := points[len(points)-1].Timestamp
+// NewGPXExtensionRegistry returns a registry without codecs.
+func NewGPXExtensionRegistry() *GPXExtensionRegistry {
+	return &GPXExtensionRegistry{}
+}
 
-	ts := &ActivityTimeseries{
-		StartTime: startTime,
+// DefaultGPXExtensions returns a new registry with codecs for Garmin TrackPointExtension
+// v1 and v2, Garmin PowerExtension, Cluetrust gpxdata and the bare <power> element of
+// Strava and Wahoo exports. Files are written with TrackPointExtension v1 and <power>.
+func DefaultGPXExtensions() *GPXExtensionRegistry {
+	tpx := func(namespace, name string, ch Channel, readOnly bool) GPXExtensionCodec {
+		return GPXExtensionCodec{Namespace: namespace, Prefix: "gpxtpx", Path: []string{"TrackPointExtension", name}, Channel: ch, ReadOnly: readOnly}
+	}
+	gpxdata := func(name string, ch Channel) GPXExtensionCodec {
+		return GPXExtensionCodec{Namespace: GPXNamespaceGPXData, Prefix: "gpxdata", Path: []string{name}, Channel: ch, ReadOnly: true}
 	}
 
-	for _, p := range points {
-		offset := int(p.Timestamp.Sub(startTime).Seconds())
+	return &GPXExtensionRegistry{codecs: []GPXExtensionCodec{
+		tpx(GPXNamespaceTrackPointV1, "hr", ChannelHeartRate, false),
+		tpx(GPXNamespaceTrackPointV1, "cad", ChannelCadence, false),
+		tpx(GPXNamespaceTrackPointV1, "atemp", ChannelTemperature, false),
+		{Path: []string{"power"}, Channel: ChannelPower},
+
+		tpx(GPXNamespaceTrackPointV2, "hr", ChannelHeartRate, true),
+		tpx(GPXNamespaceTrackPointV2, "cad", ChannelCadence, true),
+		tpx(GPXNamespaceTrackPointV2, "atemp", ChannelTemperature, true),
+		tpx(GPXNamespaceTrackPointV2, "speed", ChannelVelocity, true),
+		{Namespace: GPXNamespacePower, Prefix: "pwr", Path: []string{"PowerInWatts"}, Channel: ChannelPower, ReadOnly: true},
+
+		gpxdata("hr", ChannelHeartRate),
+		gpxdata("cadence", ChannelCadence),
+		gpxdata("temp", ChannelTemperature),
+		gpxdata("distance", ChannelDistance),
+		gpxdata("speed", ChannelVelocity),
+	}}
+}
 
-		entry := ActivityTimeseriesEntry{
-			Offset:    offset,
-			Latitude:  Optional[float64]{Value: p.Point.Latitude, Valid: p.Point.Latitude != 0},
-			Longitude: Optional[float64]{Value: p.Point.Longitude, Valid: p.Point.Longitude != 0},
+// Register adds a codec, replacing the one with the same namespace and path.
+func (r *GPXExtensionRegistry) Register(codec GPXExtensionCodec) error {
+	if len(codec.Path) == 0 {
+		return fmt.Errorf("%w: empty path", ErrInvalidGPXExtensionCodec)
+	}
+
+	if codec.Extra == "" {
+		switch codec.Channel {
+		case ChannelPosition, ChannelMoving:
+			return fmt.Errorf("%w: unsupported channel %q", ErrInvalidGPXExtensionCodec, codec.Channel)
+		}
+		if _, err := ParseChannel(string(codec.Channel)); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidGPXExtensionCodec, err)
 		}
+	}
+
+	if !codec.ReadOnly && codec.Namespace != "" && codec.Prefix == "" {
+		return fmt.Errorf("%w: %s needs a prefix to be written", ErrInvalidGPXExtensionCodec, codec.Namespace)
+	}
 
-		if !math.IsNaN(p.Point.Elevation.Value()) {
-			entry.Altitude = Optional[float64]{Value: p.Point.Elevation.Value(), Valid: true}
+	codec.Path = slices.Clone(codec.Path)
+	for i, c := range r.codecs {
+		if c.Namespace == codec.Namespace && slices.Equal(c.Path, codec.Path) {
+			r.codecs[i] = codec
+			return nil
 		}
+	}
+	r.codecs = append(r.codecs, codec)
 
-		for _, ext := range p.Extensions.Nodes {
-			if ext.XMLName.Local == "TrackPointExtension" {
-				for _, sub := range ext.Nodes {
-					switch sub.XMLName.Local {
-					case "hr":
-						var hr uint8
-						fmt.Sscanf(sub.Data, "%d", &hr)
-						entry.HeartRate = Optional[uint8]{Value: hr, Valid: true}
-					case "cad":
-						var cad uint8
-						fmt.Sscanf(sub.Data, "%d", &cad)
-						entry.Cadence = Optional[uint8]{Value: cad, Valid: true}
-					}
-				}
+	return nil
+}
+
+// Codecs returns the registered codecs in order.
+func (r *GPXExtensionRegistry) Codecs() []GPXExtensionCodec {
+	return slices.Clone(r.codecs)
+}
+
+// writers returns the first codec of each channel that is not read only.
+func (r *GPXExtensionRegistry) writers() []GPXExtensionCodec {
+	var writers []GPXExtensionCodec
+	for _, c := range r.codecs {
+		if c.ReadOnly || slices.ContainsFunc(writers, func(w GPXExtensionCodec) bool { return w.target() == c.target() }) {
+			continue
+		}
+		writers = append(writers, c)
+	}
+	return writers
+}
+
+// decode stores the values of the extension elements in the entry. Elements without
+// a codec are ignored; malformed values are returned and leave the channel invalid.
+func (r *GPXExtensionRegistry) decode(entry *ActivityTimeseriesEntry, nodes []gpx.ExtensionNode) []error {
+	var errs []error
+
+	var walk func(nodes []gpx.ExtensionNode, path []string)
+	walk = func(nodes []gpx.ExtensionNode, path []string) {
+		for _, node := range nodes {
+			path := append(path[:len(path):len(path)], node.XMLName.Local)
+			if len(node.Nodes) > 0 {
+				walk(node.Nodes, path)
+				continue
+			}
+
+			i := slices.IndexFunc(r.codecs, func(c GPXExtensionCodec) bool { return c.matches(node.XMLName.Space, path) })
+			if i < 0 {
+				continue
+			}
+			if err := r.codecs[i].decode(entry, node.Data); err != nil {
+				errs = append(errs, err)
 			}
 		}
+	}
+	walk(nodes, nil)
 
-		ts.Data = append(ts.Data, entry)
+	return errs
+}
+
+// extraChannels returns the extra channels of the codecs that have values in data.
+func (r *GPXExtensionRegistry) extraChannels(data []ActivityTimeseriesEntry) []ExtraChannel {
+	var channels []ExtraChannel
+	for _, c := range r.codecs {
+		if c.Extra == "" || slices.ContainsFunc(channels, func(ch ExtraChannel) bool { return ch.Name == c.Extra }) {
+			continue
+		}
+		if slices.ContainsFunc(data, func(e ActivityTimeseriesEntry) bool { _, ok := e.Extra[c.Extra]; return ok }) {
+			channels = append(channels, ExtraChannel{Name: c.Extra, Units: c.Units})
+		}
 	}
+	return channels
+}
+
+// setGPXChannelValue stores a value in GPX extension units, reporting false when it
+// is out of the range of the channel.
+func setGPXChannelValue(entry *ActivityTimeseriesEntry, ch Channel, value float64) bool {
+	rounded := math.Round(value)
+
+	switch ch {
+	case ChannelHeartRate:
+		if rounded < 0 || rounded > math.MaxUint8 {
+			return false
+		}
+		entry.HeartRate = Optional[uint8]{Value: uint8(rounded), Valid: true}
+
+	case ChannelCadence:
+		if rounded < 0 || rounded > math.MaxUint8 {
+			return false
+		}
+		entry.Cadence = Optional[uint8]{Value: uint8(rounded), Valid: true}
+
+	case ChannelDistance:
+		if rounded < 0 || rounded > math.MaxUint32 {
+			return false
+		}
+		entry.Distance = Optional[Distance]{Value: Distance(rounded), Valid: true}
 
-	elapsed := endTime.Sub(startTime)
+	case ChannelAltitude:
+		entry.Altitude = Optional[float64]{Value: value, Valid: true}
 
-	activity := &Activity{
-		Sport:       sport,
-		StartTime:   startTime,
-		ElapsedTime: uint32(elapsed.Seconds()),
-		// We can’t directly infer MovingTime, AvgSpeed, etc., from GPX alone,
-		// so they’ll be left zeroed or estimated if desired.
+	case ChannelVelocity:
+		if value < 0 || value*1000 > math.MaxUint16 {
+			return false
+		}
+		entry.Velocity = Optional[Speed]{Value: SpeedFromMetersPerSecond(value), Valid: true}
+
+	case ChannelPower:
+		if rounded < 0 || rounded > math.MaxUint16 {
+			return false
+		}
+		entry.Power = Optional[uint16]{Value: uint16(rounded), Valid: true}
+
+	case ChannelTemperature:
+		if rounded < math.MinInt8 || rounded > math.MaxInt8 {
+			return false
+		}
+		entry.Temperature = Optional[int8]{Value: int8(rounded), Valid: true}
+
+	case ChannelGrade:
+		entry.Grade = Optional[float64]{Value: value, Valid: true}
+
+	default:
+		return false
 	}
 
-	return activity, ts, nil
+	return true
 }
 
 func gpxNameToSport(gpxType, gpxName string) Sport {
@@ -0,0 +1,244 @@
+package stride_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+// Two days of a hike: the first day was paused once, which started a new segment.
+const multiDayHikeGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"
+  xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+  <trk>
+    <name>Day 1</name>
+    <type>hiking</type>
+    <trkseg>
+      <trkpt lat="46.0000" lon="8.0000"><ele>1000</ele><time>2025-07-01T07:00:00Z</time>
+        <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>110</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions>
+      </trkpt>
+      <trkpt lat="46.0010" lon="8.0000"><ele>1010</ele><time>2025-07-01T07:01:00Z</time>
+        <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>fast</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions>
+      </trkpt>
+      <trkpt lat="46.0015" lon="8.0000"><ele>1015</ele></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="46.0020" lon="8.0000"><ele>1020</ele><time>2025-07-01T07:11:00Z</time></trkpt>
+      <trkpt lat="46.0025" lon="8.0000"><ele>1025</ele><time>2025-07-01T07:05:00Z</time></trkpt>
+      <trkpt lat="46.0030" lon="8.0000"><ele>1030</ele><time>2025-07-01T07:12:00Z</time></trkpt>
+    </trkseg>
+    <trkseg></trkseg>
+  </trk>
+  <trk>
+    <name>Day 2</name>
+    <type>hiking</type>
+    <trkseg>
+      <trkpt lat="46.1000" lon="8.1000"><ele>1500</ele><time>2025-07-02T07:00:00Z</time></trkpt>
+      <trkpt lat="46.1010" lon="8.1000"><ele>1510</ele><time>2025-07-02T07:01:00Z</time></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func TestParseGPXFile(t *testing.T) {
+	day1 := time.Date(2025, 7, 1, 7, 0, 0, 0, time.UTC)
+	day2 := time.Date(2025, 7, 2, 7, 0, 0, 0, time.UTC)
+
+	result, err := ParseGPXFile([]byte(multiDayHikeGPX), GPXImportConfig{})
+	require.NoError(t, err)
+
+	require.Len(t, result.Activities, 1)
+	require.Len(t, result.Timeseries, 1)
+	act, ts := result.Activities[0], result.Timeseries[0]
+
+	assert.Equal(t, SportHiking, act.Sport)
+	assert.Equal(t, day1, act.StartTime)
+	assert.Equal(t, uint32(day2.Sub(day1).Seconds()+60), act.ElapsedTime)
+
+	offsets := make([]int, len(ts.Data))
+	for i, entry := range ts.Data {
+		offsets[i] = entry.Offset
+	}
+	assert.Equal(t, []int{0, 60, 660, 720, 86400, 86460}, offsets)
+	assert.Equal(t, []Pause{
+		{StartOffset: 61, EndOffset: 660, Reason: PauseReasonSegment},
+		{StartOffset: 721, EndOffset: 86400, Reason: PauseReasonSegment},
+	}, ts.Pauses)
+
+	assert.Equal(t, Optional[uint8]{Value: 110, Valid: true}, ts.Data[0].HeartRate)
+	assert.False(t, ts.Data[1].HeartRate.Valid)
+
+	assert.Equal(t, []GPXWarning{
+		{Track: 0, Segment: 0, Point: 1, Message: `invalid value "fast" for TrackPointExtension/hr`},
+		{Track: 0, Segment: 0, Point: 2, Message: "point has no time, skipped"},
+		{Track: 0, Segment: 1, Point: 1, Message: "point at 2025-07-01T07:05:00Z goes back in time, skipped"},
+		{Track: 0, Segment: 2, Point: -1, Message: "segment has no points"},
+	}, result.Warnings)
+
+	t.Run("SplitTracks", func(t *testing.T) {
+		result, err := ParseGPXFile([]byte(multiDayHikeGPX), GPXImportConfig{SplitTracks: true})
+		require.NoError(t, err)
+
+		require.Len(t, result.Activities, 2)
+		assert.Equal(t, day1, result.Activities[0].StartTime)
+		assert.Equal(t, uint32(720), result.Activities[0].ElapsedTime)
+		assert.Len(t, result.Timeseries[0].Data, 4)
+		assert.Len(t, result.Timeseries[0].Pauses, 1)
+
+		assert.Equal(t, day2, result.Activities[1].StartTime)
+		assert.Equal(t, []int{0, 60}, []int{result.Timeseries[1].Data[0].Offset, result.Timeseries[1].Data[1].Offset})
+		assert.Empty(t, result.Timeseries[1].Pauses)
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		stream := StreamGPXFile(bytes.NewReader([]byte(multiDayHikeGPX)))
+		entries := slices.Collect(stream.Entries())
+		require.NoError(t, stream.Err())
+		assert.Equal(t, ts.Data, entries)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := ParseGPXFile([]byte(`<gpx version="1.1"></gpx>`), GPXImportConfig{})
+		assert.ErrorIs(t, err, ErrNoTracksOrSegments)
+
+		_, err = ParseGPXFile([]byte(`<gpx version="1.1"><trk><trkseg></trkseg></trk></gpx>`), GPXImportConfig{})
+		assert.ErrorIs(t, err, ErrNoTrackPoints)
+
+		_, _, err = ParseGPXFileFromMemory([]byte(`not xml`))
+		assert.ErrorIs(t, err, ErrFailedToParseGPXFile)
+	})
+}
+
+const vendorExtensionsGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"
+  xmlns:ns3="http://www.garmin.com/xmlschemas/TrackPointExtension/v2"
+  xmlns:pwr="http://www.garmin.com/xmlschemas/PowerExtension/v1"
+  xmlns:gpxdata="http://www.cluetrust.com/XML/GPXDATA/1/0"
+  xmlns:other="http://example.com/other">
+  <trk>
+    <type>biking</type>
+    <trkseg>
+      <trkpt lat="46.0" lon="8.0"><time>2025-07-01T07:00:00Z</time>
+        <extensions>
+          <ns3:TrackPointExtension><ns3:hr>140</ns3:hr><ns3:cad>88</ns3:cad><ns3:speed>8.25</ns3:speed></ns3:TrackPointExtension>
+          <pwr:PowerInWatts>231</pwr:PowerInWatts>
+        </extensions>
+      </trkpt>
+      <trkpt lat="46.001" lon="8.0"><time>2025-07-01T07:00:01Z</time>
+        <extensions>
+          <gpxdata:hr>141</gpxdata:hr><gpxdata:temp>21.6</gpxdata:temp><gpxdata:distance>9.2</gpxdata:distance>
+          <power>240</power>
+          <other:hr>99</other:hr>
+        </extensions>
+      </trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func TestGPXExtensions(t *testing.T) {
+	_, ts, err := ParseGPXFileFromMemory([]byte(vendorExtensionsGPX))
+	require.NoError(t, err)
+	require.Len(t, ts.Data, 2)
+
+	first, second := ts.Data[0], ts.Data[1]
+	assert.Equal(t, Optional[uint8]{Value: 140, Valid: true}, first.HeartRate)
+	assert.Equal(t, Optional[uint8]{Value: 88, Valid: true}, first.Cadence)
+	assert.Equal(t, Optional[Speed]{Value: 8250, Valid: true}, first.Velocity)
+	assert.Equal(t, Optional[uint16]{Value: 231, Valid: true}, first.Power)
+
+	// other:hr is in an unknown namespace and must not override gpxdata:hr
+	assert.Equal(t, Optional[uint8]{Value: 141, Valid: true}, second.HeartRate)
+	assert.Equal(t, Optional[int8]{Value: 22, Valid: true}, second.Temperature)
+	assert.Equal(t, Optional[Distance]{Value: 9, Valid: true}, second.Distance)
+	assert.Equal(t, Optional[uint16]{Value: 240, Valid: true}, second.Power)
+
+	entries := slices.Collect(StreamGPXFile(bytes.NewReader([]byte(vendorExtensionsGPX))).Entries())
+	assert.Equal(t, ts.Data, entries)
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		start := time.Date(2025, 7, 1, 7, 0, 0, 0, time.UTC)
+		written := steadyRun(start, 10)
+		for i := range written.Data {
+			written.Data[i].Distance = Optional[Distance]{}
+			written.Data[i].Velocity = Optional[Speed]{}
+			written.Data[i].Cadence = Optional[uint8]{Value: 85, Valid: true}
+			written.Data[i].Temperature = Optional[int8]{Value: -3, Valid: true}
+			written.Data[i].Power = Optional[uint16]{Value: uint16(200 + i), Valid: true}
+		}
+
+		data, err := CreateGPXFileInMemory(&Activity{Sport: SportRunning, StartTime: start}, written)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `xmlns:gpxtpx="`+GPXNamespaceTrackPointV1+`"`)
+		assert.Contains(t, string(data), "<gpxtpx:hr>")
+		assert.Contains(t, string(data), "<power>200</power>")
+
+		_, read, err := ParseGPXFileFromMemory(data)
+		require.NoError(t, err)
+		require.Len(t, read.Data, len(written.Data))
+		for i := range read.Data {
+			assert.Equal(t, written.Data[i].HeartRate, read.Data[i].HeartRate)
+			assert.Equal(t, written.Data[i].Cadence, read.Data[i].Cadence)
+			assert.Equal(t, written.Data[i].Temperature, read.Data[i].Temperature)
+			assert.Equal(t, written.Data[i].Power, read.Data[i].Power)
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		registry := DefaultGPXExtensions()
+		require.NoError(t, registry.Register(GPXExtensionCodec{
+			Namespace: GPXNamespaceTrackPointV2,
+			Prefix:    "gpxtpx2",
+			Path:      []string{"TrackPointExtension", "speed"},
+			Channel:   ChannelVelocity,
+			Scale:     3.6, // km/h
+		}))
+		require.NoError(t, registry.Register(GPXExtensionCodec{
+			Namespace: "http://example.com/stryd",
+			Prefix:    "stryd",
+			Path:      []string{"LegSpring"},
+			Extra:     "leg_spring",
+			Units:     "kN/m",
+		}))
+
+		start := time.Date(2025, 7, 1, 7, 0, 0, 0, time.UTC)
+		written := steadyRun(start, 3)
+		for i := range written.Data {
+			written.Data[i].Velocity = Optional[Speed]{Value: 3000, Valid: true}
+			written.Data[i].Extra = map[string]float64{"leg_spring": 9.5 + float64(i)/10}
+		}
+
+		data, err := CreateGPXFileWithConfig(&Activity{Sport: SportRunning, StartTime: start}, written, GPXExportConfig{Extensions: registry})
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "<gpxtpx2:speed>10.8</gpxtpx2:speed>")
+		assert.Contains(t, string(data), "<stryd:LegSpring>9.6</stryd:LegSpring>")
+
+		result, err := ParseGPXFile(data, GPXImportConfig{Extensions: registry})
+		require.NoError(t, err)
+		read := result.Timeseries[0]
+		assert.Equal(t, []ExtraChannel{{Name: "leg_spring", Units: "kN/m"}}, read.ExtraChannels)
+		for i := range read.Data {
+			assert.Equal(t, written.Data[i].Velocity, read.Data[i].Velocity)
+			assert.InDelta(t, written.Data[i].Extra["leg_spring"], read.Data[i].Extra["leg_spring"], 1e-9)
+		}
+
+		// Without the codec the extra channel is not read
+		_, read, err = ParseGPXFileFromMemory(data)
+		require.NoError(t, err)
+		assert.Empty(t, read.ExtraChannels)
+		assert.Nil(t, read.Data[0].Extra)
+	})
+
+	t.Run("InvalidCodec", func(t *testing.T) {
+		registry := NewGPXExtensionRegistry()
+		assert.ErrorIs(t, registry.Register(GPXExtensionCodec{Channel: ChannelPower}), ErrInvalidGPXExtensionCodec)
+		assert.ErrorIs(t, registry.Register(GPXExtensionCodec{Path: []string{"pos"}, Channel: ChannelPosition}), ErrInvalidGPXExtensionCodec)
+		assert.ErrorIs(t, registry.Register(GPXExtensionCodec{Path: []string{"x"}, Channel: "nope"}), ErrInvalidGPXExtensionCodec)
+		assert.ErrorIs(t, registry.Register(GPXExtensionCodec{Namespace: "http://example.com", Path: []string{"x"}, Channel: ChannelPower}), ErrInvalidGPXExtensionCodec)
+		assert.Empty(t, registry.Codecs())
+	})
+}
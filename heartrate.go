@@ -57,6 +57,9 @@ func CalculateAverageHeartRate(timeseries *ActivityTimeseries, config AvgHeartRa
 		return 0, ErrEmptyTimeseriesData
 	}
 
+	// Paused time is excluded so a stop does not weigh on the samples around it
+	timeseries = timeseries.ExcludePauses()
+
 	switch config.Method {
 	case HeartRateMethodSimple:
 		return calculateSimpleAverageFromTimeseries(timeseries.Data, config)
@@ -77,6 +80,9 @@ func CalculateMaxHeartRate(timeseries *ActivityTimeseries, config MaxHeartRateAn
 		return 0, ErrEmptyTimeseriesData
 	}
 
+	// Paused time is excluded so a stop does not weigh on the samples around it
+	timeseries = timeseries.ExcludePauses()
+
 	switch config.Method {
 	case MaxHeartRateMethodPeak:
 		return calculatePeakHeartRate(timeseries.Data, config)
@@ -92,6 +98,56 @@ func CalculateMaxHeartRate(timeseries *ActivityTimeseries, config MaxHeartRateAn
 	}
 }
 
+// LapHeartRateResult contains the heart rate breakdown of a single lap
+type LapHeartRateResult struct {
+	LapIndex    int     `json:"lapIndex"`
+	StartOffset int     `json:"startOffset"` // Seconds since the activity start
+	Duration    int     `json:"duration"`    // Seconds
+	AvgHR       float64 `json:"avgHR"`       // Zero when the lap has no valid heart rate data
+	MaxHR       int     `json:"maxHR"`       // Zero when the lap has no valid heart rate data
+}
+
+// CalculateLapHeartRates runs the average and max heart rate analyses on each lap of the
+// activity.
+func CalculateLapHeartRates(act *Activity, timeseries *ActivityTimeseries, avgConfig AvgHeartRateAnalysisConfig, maxConfig MaxHeartRateAnalysisConfig) ([]LapHeartRateResult, error) {
+	if len(timeseries.Data) == 0 {
+		return nil, ErrEmptyTimeseriesData
+	}
+
+	results := make([]LapHeartRateResult, 0, len(act.Laps))
+
+	for i, lap := range act.Laps {
+		result := LapHeartRateResult{
+			LapIndex:    i,
+			StartOffset: int(lap.StartOffset),
+			Duration:    int(lap.Duration),
+		}
+
+		lapTs := timeseries.LapTimeseries(lap, act.StartTime)
+		if len(lapTs.Data) == 0 {
+			results = append(results, result)
+			continue
+		}
+
+		avgHR, err := CalculateAverageHeartRate(lapTs, avgConfig)
+		if err != nil && !errors.Is(err, ErrNoValidData) {
+			return nil, err
+		}
+
+		maxHR, err := CalculateMaxHeartRate(lapTs, maxConfig)
+		if err != nil && !errors.Is(err, ErrNoValidData) {
+			return nil, err
+		}
+
+		result.AvgHR = round(avgHR)
+		result.MaxHR = maxHR
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
 func isValidMaxHeartRate(hr int, config MaxHeartRateAnalysisConfig) bool {
 	if config.ExcludeZeros && hr == 0 {
 		return false
@@ -887,6 +943,7 @@ func AnalyzeHeartRateDrift(timeseries *ActivityTimeseries, config HeartRateDrift
 	}
 
 	config = config.ApplyDefaults()
+	timeseries = timeseries.ExcludePauses()
 
 	buckets := createDriftBuckets(timeseries, config.BucketSizeSeconds)
 	if len(buckets) == 0 {
@@ -1004,7 +1061,7 @@ func createDriftBuckets(timeseries *ActivityTimeseries, bucketSize int) []driftB
 		}
 
 		if entry.Velocity.Valid {
-			bucketSums[idx].speedSum += float64(entry.Velocity.Value)
+			bucketSums[idx].speedSum += entry.Velocity.Value.MetersPerSecond()
 			bucketSums[idx].speedCount++
 		}
 	}
@@ -1064,11 +1121,6 @@ func round(val float64) float64 {
 // --- Aerobic Threshold Scoring (Garmin/Uphill Athlete Style) ---
 //
 
-type Pace struct {
-	Minutes int
-	Seconds int
-}
-
 // AerobicScoreConfig defines parameters for the AeT score calculation
 type AerobicScoreConfig struct {
 	RestingHeartRate int     // Essential for HRR calculation
@@ -1093,7 +1145,7 @@ func CalculateAerobicThresholdScore(driftResult HeartRateDriftResult, config Aer
 	var avgSpeedMMin float64
 
 	if config.ManualPace != nil {
-		totalMinutes := float64(config.ManualPace.Minutes) + float64(config.ManualPace.Seconds)/60.0
+		totalMinutes := config.ManualPace.Duration().Minutes()
 		if totalMinutes <= 0 {
 			return AerobicScoreResult{}, ErrMissingSpeedData
 		}
@@ -1147,6 +1199,7 @@ func CalculateAerobicThresholdScore(driftResult HeartRateDriftResult, config Aer
 }
 
 // ComputeTimeInZones returns the number of seconds spent in each heart rate zone (1-5).
+// Time inside the pauses of the timeseries is not counted.
 func ComputeTimeInZones(ts *ActivityTimeseries, athlete AthleteBaseline) (map[int]int, error) {
 	if athlete.MaxHR <= 0 {
 		return nil, fmt.Errorf("%w", ErrMissingMaxHR)
@@ -1169,6 +1222,7 @@ func ComputeTimeInZones(ts *ActivityTimeseries, athlete AthleteBaseline) (map[in
 
 	result := map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}
 
+	ts = ts.ExcludePauses()
 	if len(ts.Data) == 0 {
 		return result, nil
 	}
@@ -1184,10 +1238,10 @@ func ComputeTimeInZones(ts *ActivityTimeseries, athlete AthleteBaseline) (map[in
 			next := ts.Data[i+1]
 			delta := next.Offset - curr.Offset
 
-			// Gap Handling: If the gap is > 10s, we assume the device was
-			// paused or signal was lost. We only credit 1 second for the
-			// current reading rather than the whole gap.
-			if delta > 10 {
+			// Gap Handling: If the gap is longer than DefaultMaxGap, we assume
+			// the device was paused or signal was lost. We only credit 1 second
+			// for the current reading rather than the whole gap.
+			if delta > int(DefaultMaxGap.Seconds()) {
 				duration = 1
 			} else if delta <= 0 {
 				continue // Skip malformed/duplicate offsets
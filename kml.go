@@ -0,0 +1,218 @@
+package stride
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	kmlNamespace   = "http://www.opengis.net/kml/2.2"
+	kmlGxNamespace = "http://www.google.com/kml/ext/2.2"
+)
+
+// encoding/xml cannot write prefixed names, so the gx elements carry the prefix in
+// their name and the root declares it.
+type kmlFile struct {
+	XMLName  xml.Name    `xml:"kml"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	XmlnsGx  string      `xml:"xmlns:gx,attr"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name       string         `xml:"name"`
+	Schema     *kmlSchema     `xml:"Schema,omitempty"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlSchema struct {
+	ID     string           `xml:"id,attr"`
+	Fields []kmlSchemaField `xml:"gx:SimpleArrayField"`
+}
+
+type kmlSchemaField struct {
+	Name        string `xml:"name,attr"`
+	Type        string `xml:"type,attr"`
+	DisplayName string `xml:"displayName"`
+}
+
+type kmlPlacemark struct {
+	Name        string         `xml:"name"`
+	Description string         `xml:"description,omitempty"`
+	Track       *kmlTrack      `xml:"gx:Track,omitempty"`
+	LineString  *kmlLineString `xml:"LineString,omitempty"`
+	Point       *kmlPoint      `xml:"Point,omitempty"`
+}
+
+type kmlTrack struct {
+	AltitudeMode string        `xml:"altitudeMode"`
+	When         []string      `xml:"when"`
+	Coords       []string      `xml:"gx:coord"`
+	Data         *kmlTrackData `xml:"ExtendedData>SchemaData,omitempty"`
+}
+
+type kmlTrackData struct {
+	SchemaURL string         `xml:"schemaUrl,attr"`
+	Arrays    []kmlArrayData `xml:"gx:SimpleArrayData"`
+}
+
+type kmlArrayData struct {
+	Name   string   `xml:"name,attr"`
+	Values []string `xml:"gx:value"`
+}
+
+type kmlLineString struct {
+	AltitudeMode string `xml:"altitudeMode"`
+	Coordinates  string `xml:"coordinates"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// kmlTrackChannels are the channels written as per-sample data of a gx:Track.
+var kmlTrackChannels = []struct {
+	channel     Channel
+	displayName string
+}{
+	{ChannelHeartRate, "Heart Rate"},
+	{ChannelCadence, "Cadence"},
+	{ChannelPower, "Power"},
+	{ChannelVelocity, "Speed (m/s)"},
+}
+
+// CreateKMLFileInMemory writes the activity as a time-stamped gx:Track, which Google
+// Earth can play back. Heart rate, cadence, power and speed are attached as per-sample
+// data when present; samples without a position are left out.
+func CreateKMLFileInMemory(act *Activity, ts *ActivityTimeseries) ([]byte, error) {
+	var positions []ActivityTimeseriesEntry
+	for _, d := range ts.Data {
+		if d.HasGPS() {
+			positions = append(positions, d)
+		}
+	}
+
+	if len(positions) == 0 {
+		return nil, ErrNoPositionData
+	}
+
+	// A partial altitude channel would put the other samples at sea level
+	withAltitude := true
+	for _, d := range positions {
+		withAltitude = withAltitude && d.Altitude.Valid
+	}
+
+	track := &kmlTrack{AltitudeMode: kmlAltitudeMode(withAltitude)}
+	for _, d := range positions {
+		t := ts.StartTime.Add(time.Duration(d.Offset) * time.Second)
+		track.When = append(track.When, t.UTC().Format(time.RFC3339))
+		track.Coords = append(track.Coords, fmt.Sprintf("%s %s %s", kmlFloat(d.Longitude.Value, 7), kmlFloat(d.Latitude.Value, 7), kmlFloat(d.Altitude.Value, 1)))
+	}
+
+	schema := &kmlSchema{ID: "stride"}
+	data := &kmlTrackData{SchemaURL: "#stride"}
+	for _, c := range kmlTrackChannels {
+		if !hasChannel(positions, c.channel) {
+			continue
+		}
+
+		schema.Fields = append(schema.Fields, kmlSchemaField{Name: string(c.channel), Type: "float", DisplayName: c.displayName})
+
+		array := kmlArrayData{Name: string(c.channel)}
+		for _, d := range positions {
+			value, ok := d.channelValue(c.channel)
+			if !ok {
+				array.Values = append(array.Values, "")
+				continue
+			}
+			array.Values = append(array.Values, kmlFloat(value, 3))
+		}
+		data.Arrays = append(data.Arrays, array)
+	}
+
+	if len(schema.Fields) > 0 {
+		track.Data = data
+	} else {
+		schema = nil
+	}
+
+	doc := kmlDocument{
+		Name:   sportToGPXName(act.Sport),
+		Schema: schema,
+		Placemarks: []kmlPlacemark{{
+			Name:        sportToGPXName(act.Sport),
+			Description: act.StartTime.UTC().Format(time.RFC3339),
+			Track:       track,
+		}},
+	}
+
+	return marshalKML(doc)
+}
+
+// CreateKMLCourseFileInMemory writes the course track as a LineString followed by a
+// Point placemark per course point.
+func CreateKMLCourseFileInMemory(course *Course) ([]byte, error) {
+	if len(course.Track) == 0 {
+		return nil, ErrNoPositionData
+	}
+	if err := course.checkPoints(); err != nil {
+		return nil, err
+	}
+
+	withAltitude := true
+	for _, p := range course.Track {
+		withAltitude = withAltitude && p.Altitude.Valid
+	}
+
+	coordinates := make([]byte, 0, len(course.Track)*32)
+	for i, p := range course.Track {
+		if i > 0 {
+			coordinates = append(coordinates, ' ')
+		}
+		coordinates = append(coordinates, kmlCoordinates(p)...)
+	}
+
+	doc := kmlDocument{
+		Name: course.Name,
+		Placemarks: []kmlPlacemark{{
+			Name:       course.Name,
+			LineString: &kmlLineString{AltitudeMode: kmlAltitudeMode(withAltitude), Coordinates: string(coordinates)},
+		}},
+	}
+
+	for _, cp := range course.Points {
+		doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+			Name:        cp.Name,
+			Description: string(cp.Type),
+			Point:       &kmlPoint{Coordinates: kmlCoordinates(course.Track[cp.Index])},
+		})
+	}
+
+	return marshalKML(doc)
+}
+
+func marshalKML(doc kmlDocument) ([]byte, error) {
+	data, err := xml.MarshalIndent(kmlFile{Xmlns: kmlNamespace, XmlnsGx: kmlGxNamespace, Document: doc}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+func kmlAltitudeMode(withAltitude bool) string {
+	if withAltitude {
+		return "absolute"
+	}
+	return "clampToGround"
+}
+
+func kmlCoordinates(p CourseTrackPoint) string {
+	return kmlFloat(p.Longitude, 7) + "," + kmlFloat(p.Latitude, 7) + "," + kmlFloat(p.Altitude.Value, 1)
+}
+
+func kmlFloat(val float64, decimals int) string {
+	return strconv.FormatFloat(roundTo(val, decimals), 'f', -1, 64)
+}
@@ -0,0 +1,111 @@
+package stride_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+// kmlTrack reads the gx:Track back with namespaces resolved, as Google Earth does.
+type kmlTrack struct {
+	XMLName  xml.Name `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Document struct {
+		Name   string `xml:"name"`
+		Fields []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"Schema>SimpleArrayField"`
+		Placemarks []struct {
+			Name  string `xml:"name"`
+			Track struct {
+				AltitudeMode string   `xml:"altitudeMode"`
+				When         []string `xml:"when"`
+				Coords       []string `xml:"http://www.google.com/kml/ext/2.2 coord"`
+				Arrays       []struct {
+					Name   string   `xml:"name,attr"`
+					Values []string `xml:"http://www.google.com/kml/ext/2.2 value"`
+				} `xml:"ExtendedData>SchemaData>SimpleArrayData"`
+			} `xml:"http://www.google.com/kml/ext/2.2 Track"`
+			LineString struct {
+				Coordinates string `xml:"coordinates"`
+			} `xml:"LineString"`
+			Point struct {
+				Coordinates string `xml:"coordinates"`
+			} `xml:"Point"`
+		} `xml:"Placemark"`
+	} `xml:"Document"`
+}
+
+func TestCreateKMLFileInMemory(t *testing.T) {
+	start := time.Date(2025, 5, 1, 6, 30, 0, 0, time.UTC)
+	ts := steadyRun(start, 5)
+	ts.Data[1].Longitude = Optional[float64]{}
+	ts.Data[4].HeartRate = Optional[uint8]{}
+	ts.Data[2].Power = Optional[uint16]{Value: 250, Valid: true}
+
+	data, err := CreateKMLFileInMemory(&Activity{Sport: SportTrailRunning, StartTime: start}, ts)
+	require.NoError(t, err)
+
+	var doc kmlTrack
+	require.NoError(t, xml.Unmarshal(data, &doc))
+	assert.Equal(t, "Trail Running", doc.Document.Name)
+
+	require.Len(t, doc.Document.Placemarks, 1)
+	track := doc.Document.Placemarks[0].Track
+	assert.Equal(t, "absolute", track.AltitudeMode)
+	assert.Equal(t, []string{"2025-05-01T06:30:00Z", "2025-05-01T06:30:02Z", "2025-05-01T06:30:03Z", "2025-05-01T06:30:04Z", "2025-05-01T06:30:05Z"}, track.When)
+	require.Len(t, track.Coords, 5)
+	assert.Equal(t, "7 45 100", track.Coords[0])
+	assert.Equal(t, "7 45.000054 100.2", track.Coords[1])
+
+	require.Len(t, doc.Document.Fields, 2)
+	assert.Equal(t, "heart_rate", doc.Document.Fields[0].Name)
+	assert.Equal(t, "power", doc.Document.Fields[1].Name)
+
+	require.Len(t, track.Arrays, 2)
+	assert.Equal(t, []string{"150", "150", "150", "", "150"}, track.Arrays[0].Values)
+	assert.Equal(t, []string{"", "250", "", "", ""}, track.Arrays[1].Values)
+
+	t.Run("PartialAltitude", func(t *testing.T) {
+		partial := steadyRun(start, 3)
+		partial.Data[2].Altitude = Optional[float64]{}
+
+		data, err := CreateKMLFileInMemory(&Activity{StartTime: start}, partial)
+		require.NoError(t, err)
+
+		var doc kmlTrack
+		require.NoError(t, xml.Unmarshal(data, &doc))
+		assert.Equal(t, "clampToGround", doc.Document.Placemarks[0].Track.AltitudeMode, "a sample without altitude must not be drawn at sea level")
+	})
+
+	t.Run("NoPositions", func(t *testing.T) {
+		_, err := CreateKMLFileInMemory(&Activity{}, &ActivityTimeseries{StartTime: start, Data: []ActivityTimeseriesEntry{{Offset: 0}}})
+		assert.ErrorIs(t, err, ErrNoPositionData)
+	})
+}
+
+func TestCreateKMLCourseFileInMemory(t *testing.T) {
+	course, err := NewCourse("Hill", SportRunning, hillRoute(time.Date(2025, 5, 1, 6, 30, 0, 0, time.UTC)), CourseConfig{})
+	require.NoError(t, err)
+	require.NotEmpty(t, course.Points)
+
+	data, err := CreateKMLCourseFileInMemory(course)
+	require.NoError(t, err)
+
+	var doc kmlTrack
+	require.NoError(t, xml.Unmarshal(data, &doc))
+	require.Len(t, doc.Document.Placemarks, 1+len(course.Points))
+	assert.Equal(t, "Hill", doc.Document.Placemarks[0].Name)
+	assert.Contains(t, doc.Document.Placemarks[0].LineString.Coordinates, "7,45,500 ")
+
+	for i, cp := range course.Points {
+		placemark := doc.Document.Placemarks[i+1]
+		assert.Equal(t, cp.Name, placemark.Name)
+		assert.Len(t, strings.Split(placemark.Point.Coordinates, ","), 3)
+	}
+}
@@ -0,0 +1,110 @@
+package stride
+
+import (
+	"math"
+	"time"
+)
+
+type LapTrigger string
+
+const (
+	LapTriggerManual     LapTrigger = "manual"
+	LapTriggerTime       LapTrigger = "time"
+	LapTriggerDistance   LapTrigger = "distance"
+	LapTriggerPosition   LapTrigger = "position"
+	LapTriggerSessionEnd LapTrigger = "session-end"
+	LapTriggerUnknown    LapTrigger = "unknown"
+)
+
+type Lap struct {
+	StartOffset uint32 // seconds since Activity.StartTime
+	Duration    uint32 // seconds, including pauses
+	Distance    Distance
+	AvgSpeed    Speed
+	AvgHR       Optional[uint8] // beats / minute
+	MaxHR       Optional[uint8] // beats / minute
+	Trigger     LapTrigger
+}
+
+func (l Lap) EndOffset() uint32 {
+	return l.StartOffset + l.Duration
+}
+
+// Session is one sport block of an activity. Single-sport activities have at most one,
+// multisport activities (e.g. triathlon) have one per leg.
+type Session struct {
+	Sport         Sport
+	StartOffset   uint32 // seconds since Activity.StartTime
+	ElapsedTime   uint32 // seconds
+	MovingTime    uint32 // seconds
+	Distance      Distance
+	AvgSpeed      Speed
+	AvgHR         Optional[uint8]  // beats / minute
+	MaxHR         Optional[uint8]  // beats / minute
+	ElevationGain Optional[uint16] // meters
+	ElevationLoss Optional[uint16] // meters
+	FirstLapIndex int              // index into Activity.Laps
+	NumLaps       int
+}
+
+// sessionsOrDefault returns the activity sessions, or a single session spanning the
+// whole activity and all its laps when none were recorded.
+func (a *Activity) sessionsOrDefault(sport Sport) []Session {
+	if len(a.Sessions) > 0 {
+		return a.Sessions
+	}
+
+	return []Session{{
+		Sport:         sport,
+		ElapsedTime:   a.ElapsedTime,
+		MovingTime:    a.MovingTime,
+		Distance:      a.Distance,
+		AvgSpeed:      a.AvgSpeed,
+		AvgHR:         a.AvgHR,
+		MaxHR:         a.MaxHR,
+		ElevationGain: a.ElevationGain,
+		ElevationLoss: a.ElevationLoss,
+		NumLaps:       len(a.Laps),
+	}}
+}
+
+// lap returns a single lap spanning the whole session.
+func (s Session) lap() Lap {
+	return Lap{
+		StartOffset: s.StartOffset,
+		Duration:    s.ElapsedTime,
+		Distance:    s.Distance,
+		AvgSpeed:    s.AvgSpeed,
+		AvgHR:       s.AvgHR,
+		MaxHR:       s.MaxHR,
+		Trigger:     LapTriggerSessionEnd,
+	}
+}
+
+// LapTimeseries returns the portion of the timeseries that belongs to the lap. Lap
+// offsets count from activityStart, which may differ from ts.StartTime (e.g. after Clean
+// or a crop); a zero activityStart means they start together. Samples keep their offsets
+// from ts.StartTime.
+func (ts *ActivityTimeseries) LapTimeseries(lap Lap, activityStart time.Time) *ActivityTimeseries {
+	lapTs := &ActivityTimeseries{StartTime: ts.StartTime, ExtraChannels: ts.ExtraChannels}
+
+	shift := 0
+	if !activityStart.IsZero() {
+		shift = int(math.Round(activityStart.Sub(ts.StartTime).Seconds()))
+	}
+	start, end := int(lap.StartOffset)+shift, int(lap.EndOffset())+shift
+
+	for _, entry := range ts.Data {
+		if entry.Offset < start || entry.Offset >= end {
+			continue
+		}
+		lapTs.Data = append(lapTs.Data, entry)
+	}
+	lapTs.Pauses = clipPauses(ts.Pauses, 0, start, end)
+
+	return lapTs
+}
+
+func (l Lap) startTime(activityStart time.Time) time.Time {
+	return activityStart.Add(time.Duration(l.StartOffset) * time.Second)
+}
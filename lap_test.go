@@ -0,0 +1,129 @@
+package stride_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func TestFITLapsRoundTrip(t *testing.T) {
+	start := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	act := &Activity{
+		Sport:       SportRunning,
+		StartTime:   start,
+		ElapsedTime: 600,
+		MovingTime:  590,
+		Distance:    2000,
+		Laps: []Lap{
+			{StartOffset: 0, Duration: 300, Distance: 1000, AvgHR: Optional[uint8]{Value: 140, Valid: true}, Trigger: LapTriggerDistance},
+			{StartOffset: 300, Duration: 300, Distance: 1000, AvgHR: Optional[uint8]{Value: 155, Valid: true}, Trigger: LapTriggerManual},
+		},
+	}
+
+	ts := &ActivityTimeseries{StartTime: start}
+	for i := 0; i <= 600; i += 10 {
+		ts.Data = append(ts.Data, ActivityTimeseriesEntry{Offset: i, HeartRate: Optional[uint8]{Value: 140, Valid: true}})
+	}
+
+	data, err := CreateFITFileInMemory(act, ts, SportRunning)
+	require.NoError(t, err)
+
+	laps, err := FITFileToLaps(data)
+	require.NoError(t, err)
+	require.Len(t, laps, 2)
+
+	assert.Equal(t, uint32(300), laps[1].StartOffset)
+	assert.Equal(t, uint32(300), laps[1].Duration)
+	assert.Equal(t, Distance(1000), laps[1].Distance)
+	assert.Equal(t, Optional[uint8]{Value: 155, Valid: true}, laps[1].AvgHR)
+	assert.False(t, laps[1].MaxHR.Valid)
+	assert.Equal(t, LapTriggerDistance, laps[0].Trigger)
+	assert.Equal(t, LapTriggerManual, laps[1].Trigger)
+
+	sessions, err := FITFileToSessions(data)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+
+	assert.Equal(t, SportRunning, sessions[0].Sport)
+	assert.Equal(t, uint32(600), sessions[0].ElapsedTime)
+	assert.Equal(t, uint32(590), sessions[0].MovingTime)
+	assert.Equal(t, Distance(2000), sessions[0].Distance)
+	assert.Equal(t, 2, sessions[0].NumLaps)
+}
+
+func TestFITMultipleSessions(t *testing.T) {
+	start := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	act := &Activity{
+		StartTime:   start,
+		ElapsedTime: 3600,
+		Sessions: []Session{
+			{Sport: SportCycling, StartOffset: 0, ElapsedTime: 2400, Distance: 20000},
+			{Sport: SportRunning, StartOffset: 2400, ElapsedTime: 1200, Distance: 4000},
+		},
+	}
+
+	data, err := CreateFITFileInMemory(act, &ActivityTimeseries{StartTime: start}, SportUnknown)
+	require.NoError(t, err)
+
+	sessions, err := FITFileToSessions(data)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	assert.Equal(t, SportCycling, sessions[0].Sport)
+	assert.Equal(t, SportRunning, sessions[1].Sport)
+	assert.Equal(t, uint32(2400), sessions[1].StartOffset)
+}
+
+func TestCalculateLapHeartRates(t *testing.T) {
+	start := time.Date(2025, 2, 1, 7, 0, 0, 0, time.UTC)
+	ts := &ActivityTimeseries{StartTime: start}
+	for i := 0; i < 120; i++ {
+		hr := uint8(130)
+		if i >= 60 {
+			hr = 160
+		}
+		ts.Data = append(ts.Data, ActivityTimeseriesEntry{Offset: i, HeartRate: Optional[uint8]{Value: hr, Valid: true}})
+	}
+
+	act := &Activity{StartTime: start, Laps: []Lap{
+		{StartOffset: 0, Duration: 60},
+		{StartOffset: 60, Duration: 60},
+		{StartOffset: 300, Duration: 60}, // Beyond the recording
+	}}
+
+	results, err := CalculateLapHeartRates(act, ts,
+		AvgHeartRateAnalysisConfig{Method: HeartRateMethodSimple},
+		MaxHeartRateAnalysisConfig{Method: MaxHeartRateMethodPeak},
+	)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.InDelta(t, 130.0, results[0].AvgHR, 0.01)
+	assert.InDelta(t, 160.0, results[1].AvgHR, 0.01)
+	assert.Equal(t, 160, results[1].MaxHR)
+	assert.Zero(t, results[2].AvgHR)
+
+	t.Run("LateFirstSample", func(t *testing.T) {
+		// The timeseries starts 30s after the activity, as Strava data often does
+		late := &ActivityTimeseries{StartTime: start.Add(30 * time.Second), Data: ts.Data}
+
+		lapTs := late.LapTimeseries(act.Laps[1], act.StartTime)
+		require.Len(t, lapTs.Data, 60)
+		assert.Equal(t, 30, lapTs.Data[0].Offset)
+		assert.Equal(t, 89, lapTs.Data[59].Offset)
+
+		results, err := CalculateLapHeartRates(act, late,
+			AvgHeartRateAnalysisConfig{Method: HeartRateMethodSimple},
+			MaxHeartRateAnalysisConfig{Method: MaxHeartRateMethodPeak},
+		)
+		require.NoError(t, err)
+		assert.InDelta(t, 130.0, results[0].AvgHR, 0.01, "the first lap only covers 30s of recording")
+		assert.InDelta(t, 145.0, results[1].AvgHR, 0.01)
+	})
+}
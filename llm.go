@@ -1,6 +1,9 @@
 package stride
 
-import "math"
+import (
+	"math"
+	"slices"
+)
 
 type LLMRunSummary struct {
 	Metadata       RunMetadata        `json:"runMetadata"`
@@ -158,7 +161,7 @@ func (c LLMSummaryConfig) ApplyDefaults() LLMSummaryConfig {
 		config.MinGradeDeltaM = 5.0
 	}
 	if config.MinMovingSpeedMS == 0 {
-		config.MinMovingSpeedMS = 0.5
+		config.MinMovingSpeedMS = DefaultMinMovingSpeedMS
 	}
 	if config.PhaseThresholdM == 0 {
 		config.PhaseThresholdM = 30.0
@@ -227,10 +230,19 @@ var dhBands = []dhBand{
 	{"<-10%", -999.0, -10.0},
 }
 
-// SummarizeForLLM processes augmented timeseries into the compressed LLM format.
+// SummarizeForLLM processes augmented timeseries into the compressed LLM format. Samples
+// out of offset order are summarized from a sorted copy, leaving ts as it is.
 func SummarizeForLLM(act *Activity, ts *ActivityTimeseries, config LLMSummaryConfig) (*LLMRunSummary, error) {
 	config = config.ApplyDefaults()
 
+	byOffset := func(a, b ActivityTimeseriesEntry) int { return a.Offset - b.Offset }
+	if !slices.IsSortedFunc(ts.Data, byOffset) {
+		sorted := *ts
+		sorted.Data = slices.Clone(ts.Data)
+		slices.SortStableFunc(sorted.Data, byOffset)
+		ts = &sorted
+	}
+
 	if act.Distance == 0 || len(ts.Data) == 0 || !ts.Data[len(ts.Data)-1].Distance.Valid {
 		AugmentGPXData(act, ts, AugmentConfig{ElevationHysteresisM: config.ElevationHysteresisM})
 	}
@@ -238,7 +250,7 @@ func SummarizeForLLM(act *Activity, ts *ActivityTimeseries, config LLMSummaryCon
 	summary := &LLMRunSummary{Athlete: config.Athlete}
 
 	// 1. Metadata & Global Averages
-	summary.Metadata.DistanceKm = float64(act.Distance) / 1000.0
+	summary.Metadata.DistanceKm = act.Distance.Kilometers()
 	summary.Metadata.MovingTimeMin = int(act.MovingTime) / 60
 	if act.ElevationGain.Valid {
 		summary.Metadata.TotalAscentM = int(act.ElevationGain.Value)
@@ -256,7 +268,7 @@ func SummarizeForLLM(act *Activity, ts *ActivityTimeseries, config LLMSummaryCon
 		summary.GlobalAverages.HRMax = int(hrMetrics.MaxHR)
 	}
 	if act.AvgSpeed > 0 {
-		summary.GlobalAverages.PaceAvg = formatPace(float64(act.AvgSpeed) / 1000.0)
+		summary.GlobalAverages.PaceAvg = formatPace(act.AvgSpeed.MetersPerSecond())
 	}
 
 	// Thresholds setup
@@ -307,11 +319,17 @@ func SummarizeForLLM(act *Activity, ts *ActivityTimeseries, config LLMSummaryCon
 
 		gradePct := 0.0
 		gradeFraction := 0.0
-		if deltaDist > config.MinGradeDeltaM {
+		if curr.Grade.Valid {
+			// Provider grade streams are already smoothed against the barometric altitude
+			gradePct = curr.Grade.Value
+			gradeFraction = gradePct / 100.0
+		} else if deltaDist > config.MinGradeDeltaM {
 			gradeFraction = deltaElev / deltaDist
 			gradePct = gradeFraction * 100.0
 		}
 
+		moving := ts.movingInterval(prev, *curr, actualSpeed, config.MinMovingSpeedMS)
+
 		gapSpeed := calculateGAP(actualSpeed, gradeFraction)
 
 		enriched = append(enriched, EnrichedPoint{
@@ -322,9 +340,10 @@ func SummarizeForLLM(act *Activity, ts *ActivityTimeseries, config LLMSummaryCon
 			GradePct:    gradePct,
 			DistanceM:   float64(curr.Distance.Value),
 			DeltaElevM:  curr.Altitude.Value - prev.Altitude.Value, // Point-to-point elevate for VAM
+			Moving:      moving,
 		})
 
-		if gradePct > config.GradeUpThreshold && actualSpeed > config.MinMovingSpeedMS {
+		if gradePct > config.GradeUpThreshold && moving {
 			totalUpDist += pointDist
 		}
 	}
@@ -376,7 +395,7 @@ func SummarizeForLLM(act *Activity, ts *ActivityTimeseries, config LLMSummaryCon
 	smoothedRunGrade := 0.0
 
 	for _, pt := range enriched {
-		if pt.ActualSpeed <= config.MinMovingSpeedMS {
+		if !pt.Moving {
 			continue
 		}
 
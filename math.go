@@ -18,7 +18,7 @@ func haversine(lat1, lon1, lat2, lon2 float64) float64 {
 
 // calculateGAP computes Grade Adjusted Pace speed (m/s) using Minetti's energy cost formula.
 func calculateGAP(actualSpeedMs float64, gradeFraction float64) float64 {
-	if actualSpeedMs < 0.5 {
+	if actualSpeedMs < DefaultMinMovingSpeedMS {
 		return 0 // Not moving
 	}
 
@@ -37,3 +37,9 @@ func calculateGAP(actualSpeedMs float64, gradeFraction float64) float64 {
 
 	return actualSpeedMs * costRatio
 }
+
+// roundTo rounds val to the given number of decimals.
+func roundTo(val float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(val*scale) / scale
+}
@@ -0,0 +1,292 @@
+package stride
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+var (
+	ErrNoMergeSources     = errors.New("at least one timeseries is required to merge")
+	ErrInvalidMergeSource = errors.New("invalid merge source index")
+)
+
+type MergeConfig struct {
+	Sport Sport
+
+	// Priority lists, per channel, the source indices from most to least preferred. A
+	// source missing from the list is never used for that channel. Channels without an
+	// entry use the order of the sources.
+	Priority map[Channel][]int
+
+	DetectClockSkew bool          // Align sources by cross-correlating their heart rate
+	MaxClockSkew    time.Duration // Largest skew searched for (default: 60s)
+	MinCorrelation  float64       // Minimum correlation to accept a skew (default: 0.5)
+	MinOverlap      time.Duration // Minimum shared heart rate data to estimate skew (default: 5m)
+
+	Augment AugmentConfig // Used to compute the summary of the merged activity
+}
+
+type MergeResult struct {
+	Activity   *Activity
+	Timeseries *ActivityTimeseries
+
+	// ClockSkews holds, per source, the correction that was subtracted from its clock.
+	// The first source is the reference and always has zero skew.
+	ClockSkews []time.Duration
+}
+
+func (c MergeConfig) ApplyDefaults() MergeConfig {
+	config := c
+	if config.MaxClockSkew == 0 {
+		config.MaxClockSkew = 60 * time.Second
+	}
+	if config.MinCorrelation == 0 {
+		config.MinCorrelation = 0.5
+	}
+	if config.MinOverlap == 0 {
+		config.MinOverlap = 5 * time.Minute
+	}
+	return config
+}
+
+// MergeTimeseries combines recordings of the same activity made by different devices
+// (e.g. a chest strap paired to a watch and a bike computer with GPS). Samples are
+// aligned on wall-clock time, StartTime plus Offset, optionally after correcting each
+// device's clock skew against the first source. The merged timeseries is sampled at 1 Hz
+// and every channel is taken from the highest priority source that has it. Extra
+// channels have no priority and come from the first source, in order, that has them. A
+// second is paused only when no source was recording it, because every source was paused
+// or had not started or had already stopped.
+func MergeTimeseries(sources []*ActivityTimeseries, config MergeConfig) (*MergeResult, error) {
+	if len(sources) == 0 {
+		return nil, ErrNoMergeSources
+	}
+
+	config = config.ApplyDefaults()
+
+	priority := make(map[Channel][]int, len(AllChannels))
+	for _, ch := range AllChannels {
+		order, ok := config.Priority[ch]
+		if !ok {
+			order = make([]int, len(sources))
+			for i := range sources {
+				order[i] = i
+			}
+		}
+
+		for _, idx := range order {
+			if idx < 0 || idx >= len(sources) {
+				return nil, fmt.Errorf("%w: %d for channel %s", ErrInvalidMergeSource, idx, ch)
+			}
+		}
+
+		priority[ch] = order
+	}
+
+	// Put every source on a 1 Hz grid keyed by absolute unix second
+	grids := make([]map[int64]ActivityTimeseriesEntry, len(sources))
+	for i, src := range sources {
+		resampled, err := src.Resample(time.Second, ResamplePolicy{Gap: GapPolicySkip})
+		if err != nil {
+			return nil, err
+		}
+
+		grid := make(map[int64]ActivityTimeseriesEntry, len(resampled.Data))
+		base := resampled.StartTime.Unix()
+		for _, entry := range resampled.Data {
+			grid[base+int64(entry.Offset)] = entry
+		}
+		grids[i] = grid
+	}
+
+	skews := make([]time.Duration, len(sources))
+	if config.DetectClockSkew {
+		for i := 1; i < len(sources); i++ {
+			lag, ok := estimateClockSkew(grids[0], grids[i], config)
+			if !ok || lag == 0 {
+				continue
+			}
+
+			skews[i] = time.Duration(lag) * time.Second
+
+			shifted := make(map[int64]ActivityTimeseriesEntry, len(grids[i]))
+			for t, entry := range grids[i] {
+				shifted[t-lag] = entry
+			}
+			grids[i] = shifted
+		}
+	}
+
+	seen := make(map[int64]struct{})
+	var timestamps []int64
+	for _, grid := range grids {
+		for t := range grid {
+			if _, ok := seen[t]; !ok {
+				seen[t] = struct{}{}
+				timestamps = append(timestamps, t)
+			}
+		}
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	merged := &ActivityTimeseries{}
+	for _, src := range sources {
+		merged.ExtraChannels = mergeExtraChannels(merged.ExtraChannels, src.ExtraChannels)
+	}
+	if len(timestamps) > 0 {
+		merged.StartTime = time.Unix(timestamps[0], 0).UTC()
+		merged.Pauses = mergeSourcePauses(sources, grids, skews, timestamps[0], timestamps[len(timestamps)-1])
+	}
+
+	for _, t := range timestamps {
+		entry := ActivityTimeseriesEntry{Offset: int(t - timestamps[0])}
+
+		for _, ch := range AllChannels {
+			for _, idx := range priority[ch] {
+				src, ok := grids[idx][t]
+				if ok && src.Has(ch) {
+					entry.CopyChannel(ch, src)
+					break
+				}
+			}
+		}
+
+		for _, ch := range merged.ExtraChannels {
+			for _, grid := range grids {
+				if value, ok := grid[t].Extra[ch.Name]; ok {
+					if entry.Extra == nil {
+						entry.Extra = make(map[string]float64, len(merged.ExtraChannels))
+					}
+					entry.Extra[ch.Name] = value
+					break
+				}
+			}
+		}
+
+		merged.Data = append(merged.Data, entry)
+	}
+
+	act := &Activity{Sport: config.Sport}
+	if err := RecomputeSummary(act, merged, config.Augment); err != nil {
+		return nil, err
+	}
+
+	return &MergeResult{
+		Activity:   act,
+		Timeseries: merged,
+		ClockSkews: skews,
+	}, nil
+}
+
+// mergeSourcePauses returns, as offsets from origin, the seconds up to last in which no
+// source was recording. The reason is taken from the first source paused at that time.
+func mergeSourcePauses(sources []*ActivityTimeseries, grids []map[int64]ActivityTimeseriesEntry, skews []time.Duration, origin, last int64) []Pause {
+	type span struct{ first, last int64 }
+	spans := make([]span, len(sources))
+	for i, grid := range grids {
+		spans[i] = span{first: math.MaxInt64, last: math.MinInt64}
+		for t := range grid {
+			spans[i].first = min(spans[i].first, t)
+			spans[i].last = max(spans[i].last, t)
+		}
+	}
+
+	var pauses []Pause
+	for t := origin; t <= last; t++ {
+		paused := true
+		var reason PauseReason
+		for i, src := range sources {
+			if t < spans[i].first || t > spans[i].last {
+				continue
+			}
+
+			offset := int(t + int64(skews[i]/time.Second) - src.StartTime.Unix())
+			idx := slices.IndexFunc(src.Pauses, func(p Pause) bool { return p.Contains(offset) })
+			if idx < 0 {
+				paused = false
+				break
+			}
+			if reason == "" {
+				reason = src.Pauses[idx].Reason
+			}
+		}
+
+		if !paused {
+			continue
+		}
+		if reason == "" {
+			reason = PauseReasonGap // Between two sources that do not overlap
+		}
+
+		offset := int(t - origin)
+		if n := len(pauses); n > 0 && pauses[n-1].EndOffset == offset && pauses[n-1].Reason == reason {
+			pauses[n-1].EndOffset++
+		} else {
+			pauses = append(pauses, Pause{StartOffset: offset, EndOffset: offset + 1, Reason: reason})
+		}
+	}
+
+	return pauses
+}
+
+// estimateClockSkew finds the lag (seconds) that maximises the Pearson correlation of
+// the heart rate recorded by both devices: other[t+lag] best matches reference[t].
+func estimateClockSkew(reference, other map[int64]ActivityTimeseriesEntry, config MergeConfig) (int64, bool) {
+	maxLag := int64(config.MaxClockSkew.Seconds())
+	minOverlap := int(config.MinOverlap.Seconds())
+
+	bestLag := int64(0)
+	bestCorr := math.Inf(-1)
+
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		var n int
+		var sumX, sumY, sumXX, sumYY, sumXY float64
+
+		for t, ref := range reference {
+			if !ref.HeartRate.Valid {
+				continue
+			}
+			o, ok := other[t+lag]
+			if !ok || !o.HeartRate.Valid {
+				continue
+			}
+
+			x := float64(ref.HeartRate.Value)
+			y := float64(o.HeartRate.Value)
+			sumX += x
+			sumY += y
+			sumXX += x * x
+			sumYY += y * y
+			sumXY += x * y
+			n++
+		}
+
+		if n < minOverlap {
+			continue
+		}
+
+		fn := float64(n)
+		cov := sumXY - sumX*sumY/fn
+		varX := sumXX - sumX*sumX/fn
+		varY := sumYY - sumY*sumY/fn
+		if varX <= 0 || varY <= 0 {
+			continue
+		}
+
+		corr := cov / math.Sqrt(varX*varY)
+		if corr > bestCorr {
+			bestCorr = corr
+			bestLag = lag
+		}
+	}
+
+	if bestCorr < config.MinCorrelation {
+		return 0, false
+	}
+
+	return bestLag, true
+}
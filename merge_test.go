@@ -0,0 +1,129 @@
+package stride_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func syntheticHR(second int) uint8 {
+	return uint8(140 + 15*math.Sin(float64(second)/20.0) + 5*math.Sin(float64(second)/3.0))
+}
+
+func TestMergeTimeseries(t *testing.T) {
+	start := time.Date(2025, 6, 1, 7, 0, 0, 0, time.UTC)
+
+	// Bike computer: GPS and (noisy) optical HR, starts 5s before the strap
+	computer := &ActivityTimeseries{StartTime: start}
+	for i := 0; i <= 900; i++ {
+		computer.Data = append(computer.Data, ActivityTimeseriesEntry{
+			Offset:    i,
+			HeartRate: Optional[uint8]{Value: syntheticHR(i), Valid: true},
+			Latitude:  Optional[float64]{Value: 45 + float64(i)*0.00005, Valid: true},
+			Longitude: Optional[float64]{Value: 7, Valid: true},
+			Distance:  Optional[Distance]{Value: Distance(i * 5), Valid: true},
+		})
+	}
+
+	// Chest strap on a watch whose clock runs 7s ahead
+	const skew = 7
+	strap := &ActivityTimeseries{StartTime: start.Add((5 + skew) * time.Second)}
+	for i := 0; i <= 880; i++ {
+		strap.Data = append(strap.Data, ActivityTimeseriesEntry{
+			Offset:    i,
+			HeartRate: Optional[uint8]{Value: syntheticHR(i + 5), Valid: true},
+			Cadence:   Optional[uint8]{Value: 90, Valid: true},
+		})
+	}
+
+	result, err := MergeTimeseries([]*ActivityTimeseries{computer, strap}, MergeConfig{
+		Sport:           SportCycling,
+		Priority:        map[Channel][]int{ChannelHeartRate: {1, 0}},
+		DetectClockSkew: true,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []time.Duration{0, skew * time.Second}, result.ClockSkews)
+	assert.Equal(t, start, result.Timeseries.StartTime)
+
+	entry := result.Timeseries.Data[100]
+	assert.Equal(t, 100, entry.Offset)
+	assert.True(t, entry.HasGPS(), "position comes from the computer")
+	assert.Equal(t, uint8(90), entry.Cadence.Value, "cadence only exists on the strap")
+	assert.Equal(t, syntheticHR(100), entry.HeartRate.Value, "strap HR is aligned after removing the skew")
+
+	assert.Equal(t, SportCycling, result.Activity.Sport)
+	assert.Equal(t, Distance(4500), result.Activity.Distance)
+	assert.True(t, result.Activity.AvgHR.Valid)
+
+	t.Run("ExtraChannels", func(t *testing.T) {
+		pod := steadyRun(start, 60)
+		pod.ExtraChannels = []ExtraChannel{{Name: "form_power", Units: "watts"}, {Name: "core_temperature", Units: "°C"}}
+		watch := steadyRun(start, 60)
+		watch.ExtraChannels = []ExtraChannel{{Name: "core_temperature", Units: "°C"}, {Name: "smo2", Units: "%"}}
+		for i := range pod.Data {
+			pod.Data[i].Extra = map[string]float64{"form_power": 210}
+			if i >= 30 {
+				pod.Data[i].Extra["core_temperature"] = 38.5
+			}
+			watch.Data[i].Extra = map[string]float64{"core_temperature": 37.5, "smo2": 65}
+		}
+
+		result, err := MergeTimeseries([]*ActivityTimeseries{pod, watch}, MergeConfig{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []ExtraChannel{
+			{Name: "form_power", Units: "watts"},
+			{Name: "core_temperature", Units: "°C"},
+			{Name: "smo2", Units: "%"},
+		}, result.Timeseries.ExtraChannels)
+		assert.Equal(t, map[string]float64{"form_power": 210, "core_temperature": 37.5, "smo2": 65}, result.Timeseries.Data[10].Extra)
+		assert.Equal(t, map[string]float64{"form_power": 210, "core_temperature": 38.5, "smo2": 65}, result.Timeseries.Data[40].Extra, "the first source wins when both have the channel")
+	})
+}
+
+func TestMergeTimeseriesPauses(t *testing.T) {
+	start := time.Date(2025, 6, 1, 7, 0, 0, 0, time.UTC)
+
+	withPause := func(ts *ActivityTimeseries, p Pause) *ActivityTimeseries {
+		var data []ActivityTimeseriesEntry
+		for _, entry := range ts.Data {
+			if !p.Contains(entry.Offset) {
+				data = append(data, entry)
+			}
+		}
+		ts.Data = data
+		ts.Pauses = append(ts.Pauses, p)
+		return ts
+	}
+
+	// The watch was paused from 200s to 260s, the strap, started 10s later, from 220s to 300s
+	watch := withPause(steadyRun(start, 600), Pause{StartOffset: 200, EndOffset: 260, Reason: PauseReasonTimer})
+	watch = withPause(watch, Pause{StartOffset: 400, EndOffset: 420, Reason: PauseReasonTimer})
+	strap := withPause(steadyRun(start.Add(10*time.Second), 590), Pause{StartOffset: 210, EndOffset: 290, Reason: PauseReasonSegment})
+
+	result, err := MergeTimeseries([]*ActivityTimeseries{watch, strap}, MergeConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, []Pause{{StartOffset: 220, EndOffset: 260, Reason: PauseReasonTimer}}, result.Timeseries.Pauses,
+		"only the time no source recorded is paused")
+
+	data, err := CreateFITFileInMemory(result.Activity, result.Timeseries, SportRunning)
+	require.NoError(t, err)
+	_, parsed, err := ParseFITFile(data)
+	require.NoError(t, err)
+	assert.Equal(t, result.Timeseries.Pauses, parsed.Pauses)
+}
+
+func TestMergeTimeseriesErrors(t *testing.T) {
+	_, err := MergeTimeseries(nil, MergeConfig{})
+	assert.ErrorIs(t, err, ErrNoMergeSources)
+
+	ts := &ActivityTimeseries{Data: []ActivityTimeseriesEntry{{Offset: 0}}}
+	_, err = MergeTimeseries([]*ActivityTimeseries{ts}, MergeConfig{Priority: map[Channel][]int{ChannelPower: {3}}})
+	assert.ErrorIs(t, err, ErrInvalidMergeSource)
+}
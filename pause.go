@@ -0,0 +1,295 @@
+package stride
+
+import (
+	"sort"
+	"time"
+
+	"github.com/muktihari/fit/profile/filedef"
+	"github.com/muktihari/fit/profile/typedef"
+)
+
+// DefaultMinMovingSpeedMS is the speed (m/s) below which an athlete is considered stopped
+// when the provider does not report a moving flag.
+const DefaultMinMovingSpeedMS = 0.5
+
+type PauseReason string
+
+const (
+	PauseReasonTimer   PauseReason = "timer"   // The device timer was stopped
+	PauseReasonGap     PauseReason = "gap"     // Nothing was recorded for longer than the max gap
+	PauseReasonStopped PauseReason = "stopped" // Not moving, from the provider flag or speed
+	PauseReasonSegment PauseReason = "segment" // Between two GPX track segments or tracks
+)
+
+// Pause is a stopped interval of the activity. Offsets are seconds from the timeseries
+// StartTime: the pause covers [StartOffset, EndOffset).
+type Pause struct {
+	StartOffset int
+	EndOffset   int
+	Reason      PauseReason
+}
+
+func (p Pause) Duration() int {
+	return p.EndOffset - p.StartOffset
+}
+
+// Contains reports whether the offset falls inside the pause.
+func (p Pause) Contains(offset int) bool {
+	return offset >= p.StartOffset && offset < p.EndOffset
+}
+
+type PauseDetectionConfig struct {
+	MinMovingSpeedMS float64       // Speed below which the athlete is stopped (default: 0.5)
+	MaxGap           time.Duration // Recording gaps longer than this are pauses (default: DefaultMaxGap)
+	MinPauseDuration time.Duration // Shorter stops are ignored (default: 5s). Timer and gap pauses are always kept.
+}
+
+func (c PauseDetectionConfig) ApplyDefaults() PauseDetectionConfig {
+	config := c
+	if config.MinMovingSpeedMS == 0 {
+		config.MinMovingSpeedMS = DefaultMinMovingSpeedMS
+	}
+	if config.MaxGap == 0 {
+		config.MaxGap = DefaultMaxGap
+	}
+	if config.MinPauseDuration == 0 {
+		config.MinPauseDuration = 5 * time.Second
+	}
+	return config
+}
+
+// DetectPauses finds the stopped intervals of the activity. It combines the pauses
+// already on the timeseries (e.g. FIT timer events), recording gaps longer than MaxGap,
+// and runs of samples where the athlete is not moving according to the provider moving
+// flag or, when missing, the speed. Overlapping intervals are merged and the result is
+// sorted, ready to be assigned to ts.Pauses.
+func DetectPauses(ts *ActivityTimeseries, config PauseDetectionConfig) []Pause {
+	config = config.ApplyDefaults()
+
+	candidates := make([]Pause, len(ts.Pauses))
+	copy(candidates, ts.Pauses)
+
+	maxGap := int(config.MaxGap.Seconds())
+	minStop := int(config.MinPauseDuration.Seconds())
+
+	stopStart := -1
+	closeStop := func(end int) {
+		if stopStart >= 0 && end-stopStart >= minStop {
+			candidates = append(candidates, Pause{StartOffset: stopStart, EndOffset: end, Reason: PauseReasonStopped})
+		}
+		stopStart = -1
+	}
+
+	for i := 1; i < len(ts.Data); i++ {
+		prev := ts.Data[i-1]
+		curr := ts.Data[i]
+
+		delta := curr.Offset - prev.Offset
+		if delta <= 0 {
+			continue
+		}
+
+		if delta > maxGap {
+			closeStop(prev.Offset + 1)
+			// The last sample before the gap is credited one second, like ComputeTimeInZones does
+			candidates = append(candidates, Pause{StartOffset: prev.Offset + 1, EndOffset: curr.Offset, Reason: PauseReasonGap})
+			continue
+		}
+
+		speed, ok := intervalSpeed(prev, curr)
+		if !curr.Moving.Valid && !ok {
+			// Without a flag or a speed there is nothing to tell, keep the current state
+			continue
+		}
+
+		if isMoving(curr, speed, config.MinMovingSpeedMS) {
+			closeStop(curr.Offset)
+		} else if stopStart < 0 {
+			stopStart = curr.Offset
+		}
+	}
+
+	if stopStart >= 0 && len(ts.Data) > 0 {
+		closeStop(ts.Data[len(ts.Data)-1].Offset + 1)
+	}
+
+	return mergePauses(candidates)
+}
+
+// intervalSpeed returns the speed (m/s) between two samples, from GPS or the distance channel.
+func intervalSpeed(prev, curr ActivityTimeseriesEntry) (float64, bool) {
+	timeDelta := float64(curr.Offset - prev.Offset)
+	if timeDelta <= 0 {
+		return 0, false
+	}
+
+	if curr.HasGPS() && prev.HasGPS() {
+		return haversine(prev.Latitude.Value, prev.Longitude.Value, curr.Latitude.Value, curr.Longitude.Value) / timeDelta, true
+	}
+
+	if curr.Distance.Valid && prev.Distance.Valid {
+		return (float64(curr.Distance.Value) - float64(prev.Distance.Value)) / timeDelta, true
+	}
+
+	return 0, false
+}
+
+// mergePauses sorts the pauses and joins overlapping or touching ones. A merged pause
+// keeps the strongest reason: timer or segment, then gap, then stopped.
+func mergePauses(pauses []Pause) []Pause {
+	if len(pauses) == 0 {
+		return nil
+	}
+
+	sorted := make([]Pause, 0, len(pauses))
+	for _, p := range pauses {
+		if p.EndOffset > p.StartOffset {
+			sorted = append(sorted, p)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].StartOffset < sorted[j].StartOffset })
+
+	var merged []Pause
+	for _, p := range sorted {
+		if len(merged) == 0 || p.StartOffset > merged[len(merged)-1].EndOffset {
+			merged = append(merged, p)
+			continue
+		}
+
+		last := &merged[len(merged)-1]
+		if p.EndOffset > last.EndOffset {
+			last.EndOffset = p.EndOffset
+		}
+		if pauseReasonRank(p.Reason) > pauseReasonRank(last.Reason) {
+			last.Reason = p.Reason
+		}
+	}
+
+	return merged
+}
+
+func pauseReasonRank(reason PauseReason) int {
+	switch reason {
+	case PauseReasonTimer, PauseReasonSegment:
+		return 2
+
+	case PauseReasonGap:
+		return 1
+
+	default:
+		return 0
+	}
+}
+
+// IsPaused reports whether the offset falls inside one of the pauses of the timeseries.
+func (ts *ActivityTimeseries) IsPaused(offset int) bool {
+	for _, p := range ts.Pauses {
+		if p.Contains(offset) {
+			return true
+		}
+	}
+	return false
+}
+
+// PausedTime returns the total duration of the pauses of the timeseries.
+func (ts *ActivityTimeseries) PausedTime() time.Duration {
+	var seconds int
+	for _, p := range ts.Pauses {
+		seconds += p.Duration()
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// pausedBetween reports whether any pause overlaps the interval between two offsets.
+func (ts *ActivityTimeseries) pausedBetween(start, end int) bool {
+	for _, p := range ts.Pauses {
+		if p.StartOffset < end && p.EndOffset > start {
+			return true
+		}
+	}
+	return false
+}
+
+// ExcludePauses returns the timeseries on the moving clock: samples inside a pause are
+// dropped and later offsets are shifted back by the paused time, so durations computed
+// from consecutive samples never span a stop. The analyses call it on their input, which
+// makes them all ignore the same stopped intervals. Without pauses ts is returned as is,
+// so assign DetectPauses first to exclude stops that no parser recorded.
+func (ts *ActivityTimeseries) ExcludePauses() *ActivityTimeseries {
+	if len(ts.Pauses) == 0 {
+		return ts
+	}
+
+	moving := &ActivityTimeseries{
+		StartTime: ts.StartTime,
+		Data:      make([]ActivityTimeseriesEntry, 0, len(ts.Data)),
+
+		ExtraChannels: ts.ExtraChannels,
+	}
+
+	for _, entry := range ts.Data {
+		paused := 0
+		skip := false
+		for _, p := range ts.Pauses {
+			if p.Contains(entry.Offset) {
+				skip = true
+				break
+			}
+			if p.EndOffset <= entry.Offset {
+				paused += p.Duration()
+			}
+		}
+		if skip {
+			continue
+		}
+
+		entry.Offset -= paused
+		moving.Data = append(moving.Data, entry)
+	}
+
+	return moving
+}
+
+// clipPauses keeps the part of the pauses that falls within [minOffset, maxOffset] and
+// shifts them by shift seconds.
+func clipPauses(pauses []Pause, shift, minOffset, maxOffset int) []Pause {
+	var clipped []Pause
+	for _, p := range pauses {
+		start := max(p.StartOffset, minOffset)
+		end := min(p.EndOffset, maxOffset)
+		if end <= start {
+			continue
+		}
+		clipped = append(clipped, Pause{StartOffset: start + shift, EndOffset: end + shift, Reason: p.Reason})
+	}
+	return clipped
+}
+
+// fitTimerPauses turns the timer stop/start events of a FIT activity into pauses.
+func fitTimerPauses(activity *filedef.Activity, startTime time.Time) []Pause {
+	var pauses []Pause
+	stoppedAt := -1
+
+	for _, event := range activity.Events {
+		if event.Event != typedef.EventTimer {
+			continue
+		}
+
+		offset := int(event.Timestamp.Unix() - startTime.Unix())
+
+		switch event.EventType {
+		case typedef.EventTypeStop, typedef.EventTypeStopAll, typedef.EventTypeStopDisable, typedef.EventTypeStopDisableAll:
+			if stoppedAt < 0 {
+				stoppedAt = offset
+			}
+
+		case typedef.EventTypeStart:
+			if stoppedAt >= 0 && offset > stoppedAt {
+				pauses = append(pauses, Pause{StartOffset: stoppedAt, EndOffset: offset, Reason: PauseReasonTimer})
+			}
+			stoppedAt = -1
+		}
+	}
+
+	return pauses
+}
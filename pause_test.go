@@ -0,0 +1,128 @@
+package stride_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+	"github.com/gabrieleangeletti/stride/strava"
+)
+
+// pausedRun is steadyRun with a one minute stop at 200s, a recording gap after 400s and
+// a timer pause between 500s and 520s.
+func pausedRun(start time.Time) *ActivityTimeseries {
+	ts := steadyRun(start, 600)
+
+	stoppedAt := ts.Data[199]
+	for i := 200; i < 260; i++ {
+		ts.Data[i].Latitude = stoppedAt.Latitude
+		ts.Data[i].Distance = stoppedAt.Distance
+		ts.Data[i].HeartRate = Optional[uint8]{Value: 100, Valid: true}
+	}
+
+	var data []ActivityTimeseriesEntry
+	for _, entry := range ts.Data {
+		if entry.Offset > 400 && entry.Offset < 430 {
+			continue
+		}
+		data = append(data, entry)
+	}
+	ts.Data = data
+	ts.Pauses = []Pause{{StartOffset: 500, EndOffset: 520, Reason: PauseReasonTimer}}
+
+	return ts
+}
+
+func TestDetectPauses(t *testing.T) {
+	ts := pausedRun(time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC))
+
+	pauses := DetectPauses(ts, PauseDetectionConfig{})
+	assert.Equal(t, []Pause{
+		{StartOffset: 200, EndOffset: 260, Reason: PauseReasonStopped},
+		{StartOffset: 401, EndOffset: 430, Reason: PauseReasonGap},
+		{StartOffset: 500, EndOffset: 520, Reason: PauseReasonTimer},
+	}, pauses)
+
+	ts.Pauses = pauses
+	assert.Equal(t, 109*time.Second, ts.PausedTime())
+	assert.True(t, ts.IsPaused(230))
+	assert.False(t, ts.IsPaused(260))
+
+	t.Run("ProviderMovingFlag", func(t *testing.T) {
+		flagged := steadyRun(time.Now(), 120)
+		for i := range flagged.Data {
+			flagged.Data[i].Moving = Optional[bool]{Value: i < 30 || i >= 90, Valid: true}
+		}
+
+		pauses := DetectPauses(flagged, PauseDetectionConfig{})
+		assert.Equal(t, []Pause{{StartOffset: 30, EndOffset: 90, Reason: PauseReasonStopped}}, pauses)
+	})
+
+	t.Run("StravaMovingStream", func(t *testing.T) {
+		var stream strava.ActivityStream
+		for i := 0; i < 120; i++ {
+			stream.Time.Data = append(stream.Time.Data, i)
+			stream.Moving.Data = append(stream.Moving.Data, i < 30 || i >= 90)
+		}
+
+		ts, err := stream.ToTimeseries(time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, []Pause{{StartOffset: 30, EndOffset: 90, Reason: PauseReasonStopped}}, ts.Pauses)
+	})
+
+	t.Run("ShortStopsIgnored", func(t *testing.T) {
+		short := steadyRun(time.Now(), 120)
+		for i := 50; i < 53; i++ {
+			short.Data[i].Distance = short.Data[49].Distance
+			short.Data[i].Latitude = short.Data[49].Latitude
+		}
+
+		assert.Empty(t, DetectPauses(short, PauseDetectionConfig{}))
+	})
+}
+
+func TestExcludePauses(t *testing.T) {
+	ts := pausedRun(time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC))
+	ts.Pauses = DetectPauses(ts, PauseDetectionConfig{})
+
+	moving := ts.ExcludePauses()
+	require.Len(t, moving.Data, 492)
+	assert.Equal(t, 491, moving.MaxOffset())
+	assert.Empty(t, moving.Pauses)
+
+	avgHR, err := CalculateAverageHeartRate(ts, AvgHeartRateAnalysisConfig{Method: HeartRateMethodTimeWeighted})
+	require.NoError(t, err)
+	assert.Equal(t, 150.0, avgHR, "the stop at 100 bpm is excluded")
+
+	zones, err := ComputeTimeInZones(ts, AthleteBaseline{MaxHR: 190, AeTHR: 140, AnTHR: 165})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 0, 2: 0, 3: 492, 4: 0, 5: 0}, zones)
+
+	act := &Activity{}
+	require.NoError(t, RecomputeSummary(act, ts, AugmentConfig{}))
+	assert.Equal(t, uint32(600), act.ElapsedTime)
+	assert.Equal(t, uint32(489), act.MovingTime, "the sample reaching the stop is not moving either")
+
+	t.Run("StandstillWithUnrelatedPause", func(t *testing.T) {
+		ts := steadyRun(time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC), 600)
+		for i := 300; i <= 500; i++ {
+			ts.Data[i].Latitude = ts.Data[299].Latitude
+			ts.Data[i].Distance = ts.Data[299].Distance
+		}
+
+		act := &Activity{}
+		require.NoError(t, RecomputeSummary(act, ts, AugmentConfig{}))
+		assert.Equal(t, uint32(399), act.MovingTime)
+
+		ts.Pauses = []Pause{{StartOffset: 100, EndOffset: 110, Reason: PauseReasonTimer}}
+		require.NoError(t, RecomputeSummary(act, ts, AugmentConfig{}))
+		assert.Equal(t, uint32(389), act.MovingTime, "the standstill is not moving because of a pause elsewhere")
+	})
+
+	cropped, err := ts.Crop(100*time.Second, 300*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []Pause{{StartOffset: 100, EndOffset: 160, Reason: PauseReasonStopped}}, cropped.Pauses)
+}
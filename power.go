@@ -0,0 +1,194 @@
+package stride
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// PowerAnalysisConfig defines the configuration for power analysis
+type PowerAnalysisConfig struct {
+	FTP            int            // Functional threshold power in watts. Required for IF and TSS.
+	ExcludeZeros   bool           // Ignore zero readings (coasting). Default false, as coasting is part of the effort.
+	MaxValidPower  int            // Readings above this are treated as sensor errors (default: 2500)
+	WindowDuration *time.Duration // Rolling window used for Normalized Power (default: 30s)
+	MaxGapSeconds  int            // Gaps longer than this are treated as pauses and not credited (default: DefaultMaxGap)
+}
+
+// PowerAnalysisResult contains the power metrics of an activity
+type PowerAnalysisResult struct {
+	AvgPower         float64 `json:"avgPower"`         // Time-weighted average power (watts)
+	MaxPower         int     `json:"maxPower"`         // Peak power (watts)
+	NormalizedPower  float64 `json:"normalizedPower"`  // Coggan Normalized Power (watts)
+	IntensityFactor  float64 `json:"intensityFactor"`  // NP / FTP
+	TSS              float64 `json:"tss"`              // Training Stress Score
+	VariabilityIndex float64 `json:"variabilityIndex"` // NP / AvgPower
+	WorkKJ           float64 `json:"workKj"`           // Total mechanical work (kilojoules)
+	DurationSeconds  int     `json:"durationSeconds"`  // Seconds of power data credited
+}
+
+var ErrNoValidPowerData = errors.New("no valid power data points found")
+
+func (c PowerAnalysisConfig) ApplyDefaults() PowerAnalysisConfig {
+	config := c
+	if config.MaxValidPower == 0 {
+		config.MaxValidPower = 2500
+	}
+	if config.WindowDuration == nil {
+		thirtySec := 30 * time.Second
+		config.WindowDuration = &thirtySec
+	}
+	if config.MaxGapSeconds == 0 {
+		config.MaxGapSeconds = int(DefaultMaxGap.Seconds())
+	}
+	return config
+}
+
+// AnalyzePower computes average power, Normalized Power, Intensity Factor, TSS,
+// variability index and work. IF and TSS are left at zero when FTP is not set.
+func AnalyzePower(timeseries *ActivityTimeseries, config PowerAnalysisConfig) (PowerAnalysisResult, error) {
+	if timeseries == nil {
+		return PowerAnalysisResult{}, errors.New("timeseries cannot be nil")
+	}
+	if len(timeseries.Data) == 0 {
+		return PowerAnalysisResult{}, ErrEmptyTimeseriesData
+	}
+
+	config = config.ApplyDefaults()
+	timeseries = timeseries.ExcludePauses()
+
+	samples := expandPowerToSeconds(timeseries.Data, config)
+	if len(samples) == 0 {
+		return PowerAnalysisResult{}, ErrNoValidPowerData
+	}
+
+	var sum float64
+	maxPower := 0
+	for _, p := range samples {
+		sum += p
+		if int(p) > maxPower {
+			maxPower = int(p)
+		}
+	}
+
+	avgPower := sum / float64(len(samples))
+	np := normalizedPower(samples, int(config.WindowDuration.Seconds()))
+
+	result := PowerAnalysisResult{
+		AvgPower:        round(avgPower),
+		MaxPower:        maxPower,
+		NormalizedPower: round(np),
+		WorkKJ:          round(sum / 1000.0),
+		DurationSeconds: len(samples),
+	}
+
+	if avgPower > 0 {
+		result.VariabilityIndex = round(np / avgPower)
+	}
+
+	if config.FTP > 0 {
+		intensityFactor := np / float64(config.FTP)
+		result.IntensityFactor = round(intensityFactor)
+		result.TSS = round((float64(len(samples)) * np * intensityFactor) / (float64(config.FTP) * 3600.0) * 100.0)
+	}
+
+	return result, nil
+}
+
+// CalculateNormalizedPower returns Coggan's Normalized Power: the fourth root of the
+// mean of the fourth powers of the rolling-average power.
+func CalculateNormalizedPower(timeseries *ActivityTimeseries, config PowerAnalysisConfig) (float64, error) {
+	if len(timeseries.Data) == 0 {
+		return 0, ErrEmptyTimeseriesData
+	}
+
+	config = config.ApplyDefaults()
+	timeseries = timeseries.ExcludePauses()
+
+	samples := expandPowerToSeconds(timeseries.Data, config)
+	if len(samples) == 0 {
+		return 0, ErrNoValidPowerData
+	}
+
+	return normalizedPower(samples, int(config.WindowDuration.Seconds())), nil
+}
+
+func isValidPower(watts int, config PowerAnalysisConfig) bool {
+	if config.ExcludeZeros && watts == 0 {
+		return false
+	}
+
+	if config.MaxValidPower > 0 && watts > config.MaxValidPower {
+		return false
+	}
+
+	return true
+}
+
+// expandPowerToSeconds holds each reading until the next one, producing one value per
+// second. Gaps longer than MaxGapSeconds only credit a single second, like ComputeTimeInZones.
+func expandPowerToSeconds(data []ActivityTimeseriesEntry, config PowerAnalysisConfig) []float64 {
+	var samples []float64
+
+	for i := range data {
+		if !data[i].Power.Valid {
+			continue
+		}
+
+		watts := int(data[i].Power.Value)
+		if !isValidPower(watts, config) {
+			continue
+		}
+
+		duration := 1
+		if i < len(data)-1 {
+			delta := data[i+1].Offset - data[i].Offset
+			if delta <= 0 {
+				continue
+			}
+			if delta <= config.MaxGapSeconds {
+				duration = delta
+			}
+		}
+
+		for range duration {
+			samples = append(samples, float64(watts))
+		}
+	}
+
+	return samples
+}
+
+func normalizedPower(samples []float64, windowSeconds int) float64 {
+	if windowSeconds <= 0 {
+		windowSeconds = 30
+	}
+
+	// Rides shorter than the window fall back to a plain fourth-power mean
+	if len(samples) < windowSeconds {
+		windowSeconds = len(samples)
+	}
+
+	var windowSum, fourthSum float64
+	count := 0
+
+	for i, p := range samples {
+		windowSum += p
+		if i >= windowSeconds {
+			windowSum -= samples[i-windowSeconds]
+		}
+		if i < windowSeconds-1 {
+			continue
+		}
+
+		rolling := windowSum / float64(windowSeconds)
+		fourthSum += math.Pow(rolling, 4)
+		count++
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	return math.Pow(fourthSum/float64(count), 0.25)
+}
@@ -0,0 +1,87 @@
+package stride_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func constantPowerTimeseries(watts uint16, seconds int) *ActivityTimeseries {
+	ts := &ActivityTimeseries{StartTime: time.Now()}
+	for i := range seconds {
+		ts.Data = append(ts.Data, ActivityTimeseriesEntry{
+			Offset: i,
+			Power:  Optional[uint16]{Value: watts, Valid: true},
+		})
+	}
+	return ts
+}
+
+func TestAnalyzePower(t *testing.T) {
+	t.Run("ConstantPowerAtFTP", func(t *testing.T) {
+		// One hour at FTP is by definition IF 1.0 and TSS 100
+		ts := constantPowerTimeseries(250, 3600)
+
+		result, err := AnalyzePower(ts, PowerAnalysisConfig{FTP: 250})
+		require.NoError(t, err)
+
+		assert.InDelta(t, 250.0, result.AvgPower, 0.01)
+		assert.InDelta(t, 250.0, result.NormalizedPower, 0.01)
+		assert.InDelta(t, 1.0, result.IntensityFactor, 0.01)
+		assert.InDelta(t, 100.0, result.TSS, 0.01)
+		assert.InDelta(t, 1.0, result.VariabilityIndex, 0.01)
+		assert.InDelta(t, 900.0, result.WorkKJ, 0.01) // 250 W * 3600 s
+		assert.Equal(t, 250, result.MaxPower)
+	})
+
+	t.Run("VariablePowerRaisesNP", func(t *testing.T) {
+		ts := &ActivityTimeseries{StartTime: time.Now()}
+		for i := range 1200 {
+			watts := uint16(100)
+			if (i/60)%2 == 1 {
+				watts = 400
+			}
+			ts.Data = append(ts.Data, ActivityTimeseriesEntry{Offset: i, Power: Optional[uint16]{Value: watts, Valid: true}})
+		}
+
+		result, err := AnalyzePower(ts, PowerAnalysisConfig{})
+		require.NoError(t, err)
+
+		assert.InDelta(t, 250.0, result.AvgPower, 0.01)
+		assert.Greater(t, result.NormalizedPower, result.AvgPower)
+		assert.Greater(t, result.VariabilityIndex, 1.0)
+		assert.Zero(t, result.TSS, "TSS requires FTP")
+	})
+
+	t.Run("GapsAreNotCredited", func(t *testing.T) {
+		ts := &ActivityTimeseries{
+			StartTime: time.Now(),
+			Data: []ActivityTimeseriesEntry{
+				{Offset: 0, Power: Optional[uint16]{Value: 200, Valid: true}},
+				{Offset: 1, Power: Optional[uint16]{Value: 200, Valid: true}},
+				{Offset: 600, Power: Optional[uint16]{Value: 200, Valid: true}}, // 10 minute pause
+			},
+		}
+
+		result, err := AnalyzePower(ts, PowerAnalysisConfig{})
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.DurationSeconds)
+	})
+
+	t.Run("NoPowerData", func(t *testing.T) {
+		ts := &ActivityTimeseries{
+			Data: []ActivityTimeseriesEntry{{Offset: 0, HeartRate: Optional[uint8]{Value: 140, Valid: true}}},
+		}
+
+		_, err := AnalyzePower(ts, PowerAnalysisConfig{})
+		assert.ErrorIs(t, err, ErrNoValidPowerData)
+
+		metrics, err := ts.PowerMetrics(250)
+		require.NoError(t, err)
+		assert.Zero(t, metrics.NormalizedPower)
+	})
+}
@@ -0,0 +1,247 @@
+package stride
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultMaxGap is the longest interval between two samples that is still treated as
+// continuous recording. Longer intervals are assumed to be pauses or signal loss.
+const DefaultMaxGap = 10 * time.Second
+
+var ErrInvalidResampleInterval = errors.New("resample interval must be a positive whole number of seconds")
+
+type GapPolicy int
+
+const (
+	// GapPolicySkip leaves no samples inside gaps, so the grid has holes where the
+	// recording was paused.
+	GapPolicySkip GapPolicy = iota
+	// GapPolicyEmpty keeps the grid continuous but leaves every channel invalid inside
+	// gaps, and marks those samples as not moving.
+	GapPolicyEmpty
+	// GapPolicyInterpolate fills gaps of any length as if recording never stopped.
+	GapPolicyInterpolate
+)
+
+type ResamplePolicy struct {
+	Gap    GapPolicy
+	MaxGap time.Duration // Intervals longer than this are gaps (default: DefaultMaxGap)
+}
+
+func (p ResamplePolicy) ApplyDefaults() ResamplePolicy {
+	policy := p
+	if policy.MaxGap == 0 {
+		policy.MaxGap = DefaultMaxGap
+	}
+	return policy
+}
+
+// Resample returns a copy of the timeseries on a uniform grid of the given interval,
+// starting at the first sample. Altitude, distance, speed and grade are interpolated
+// linearly, position along the great circle, and heart rate, cadence, power,
+// temperature, the moving flag and the extra channels hold their last value. A channel
+// is only interpolated between two of its own valid samples, so a sensor dropout longer
+// than MaxGap stays invalid unless the policy is GapPolicyInterpolate.
+func (ts *ActivityTimeseries) Resample(interval time.Duration, policy ResamplePolicy) (*ActivityTimeseries, error) {
+	if interval < time.Second || interval%time.Second != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidResampleInterval, interval)
+	}
+
+	policy = policy.ApplyDefaults()
+
+	resampled := &ActivityTimeseries{
+		StartTime:     ts.StartTime,
+		Pauses:        append([]Pause(nil), ts.Pauses...),
+		ExtraChannels: append([]ExtraChannel(nil), ts.ExtraChannels...),
+	}
+	if len(ts.Data) == 0 {
+		return resampled, nil
+	}
+
+	data := make([]ActivityTimeseriesEntry, len(ts.Data))
+	copy(data, ts.Data)
+	sort.SliceStable(data, func(i, j int) bool { return data[i].Offset < data[j].Offset })
+
+	step := int(interval / time.Second)
+	maxGap := int(policy.MaxGap / time.Second)
+	bridgeGaps := policy.Gap == GapPolicyInterpolate
+
+	all := newResampleCursor(data, func(*ActivityTimeseriesEntry) bool { return true })
+	heartRate := newResampleCursor(data, func(e *ActivityTimeseriesEntry) bool { return e.HeartRate.Valid })
+	cadence := newResampleCursor(data, func(e *ActivityTimeseriesEntry) bool { return e.Cadence.Valid })
+	distance := newResampleCursor(data, func(e *ActivityTimeseriesEntry) bool { return e.Distance.Valid })
+	altitude := newResampleCursor(data, func(e *ActivityTimeseriesEntry) bool { return e.Altitude.Valid })
+	velocity := newResampleCursor(data, func(e *ActivityTimeseriesEntry) bool { return e.Velocity.Valid })
+	position := newResampleCursor(data, func(e *ActivityTimeseriesEntry) bool { return e.HasGPS() })
+	power := newResampleCursor(data, func(e *ActivityTimeseriesEntry) bool { return e.Power.Valid })
+	temperature := newResampleCursor(data, func(e *ActivityTimeseriesEntry) bool { return e.Temperature.Valid })
+	moving := newResampleCursor(data, func(e *ActivityTimeseriesEntry) bool { return e.Moving.Valid })
+	grade := newResampleCursor(data, func(e *ActivityTimeseriesEntry) bool { return e.Grade.Valid })
+
+	extra := make([]*resampleCursor, len(ts.ExtraChannels))
+	for i, ch := range ts.ExtraChannels {
+		extra[i] = newResampleCursor(data, func(e *ActivityTimeseriesEntry) bool { _, ok := e.Extra[ch.Name]; return ok })
+	}
+
+	first := data[0].Offset
+	last := data[len(data)-1].Offset
+
+	for t := first; t <= last; t += step {
+		entry := ActivityTimeseriesEntry{Offset: t}
+
+		if _, _, _, ok := all.at(t, maxGap, bridgeGaps); !ok {
+			switch policy.Gap {
+			case GapPolicySkip:
+				continue
+			case GapPolicyEmpty:
+				entry.Moving = Optional[bool]{Value: false, Valid: true}
+				resampled.Data = append(resampled.Data, entry)
+				continue
+			}
+		}
+
+		if p, _, _, ok := heartRate.at(t, maxGap, bridgeGaps); ok {
+			entry.HeartRate = data[p].HeartRate
+		}
+
+		if p, _, _, ok := cadence.at(t, maxGap, bridgeGaps); ok {
+			entry.Cadence = data[p].Cadence
+		}
+
+		if p, n, f, ok := distance.at(t, maxGap, bridgeGaps); ok {
+			value := lerp(float64(data[p].Distance.Value), float64(data[n].Distance.Value), f)
+			entry.Distance = Optional[Distance]{Value: Distance(math.Round(value)), Valid: true}
+		}
+
+		if p, n, f, ok := altitude.at(t, maxGap, bridgeGaps); ok {
+			entry.Altitude = Optional[float64]{Value: lerp(data[p].Altitude.Value, data[n].Altitude.Value, f), Valid: true}
+		}
+
+		if p, n, f, ok := velocity.at(t, maxGap, bridgeGaps); ok {
+			value := lerp(float64(data[p].Velocity.Value), float64(data[n].Velocity.Value), f)
+			entry.Velocity = Optional[Speed]{Value: Speed(math.Round(value)), Valid: true}
+		}
+
+		if p, n, f, ok := position.at(t, maxGap, bridgeGaps); ok {
+			lat, lon := greatCircleInterpolate(data[p].Latitude.Value, data[p].Longitude.Value, data[n].Latitude.Value, data[n].Longitude.Value, f)
+			entry.Latitude = Optional[float64]{Value: lat, Valid: true}
+			entry.Longitude = Optional[float64]{Value: lon, Valid: true}
+		}
+
+		if p, _, _, ok := power.at(t, maxGap, bridgeGaps); ok {
+			entry.Power = data[p].Power
+		}
+
+		if p, _, _, ok := temperature.at(t, maxGap, bridgeGaps); ok {
+			entry.Temperature = data[p].Temperature
+		}
+
+		if p, _, _, ok := moving.at(t, maxGap, bridgeGaps); ok {
+			entry.Moving = data[p].Moving
+		}
+
+		if p, n, f, ok := grade.at(t, maxGap, bridgeGaps); ok {
+			entry.Grade = Optional[float64]{Value: lerp(data[p].Grade.Value, data[n].Grade.Value, f), Valid: true}
+		}
+
+		for i, ch := range ts.ExtraChannels {
+			if p, _, _, ok := extra[i].at(t, maxGap, bridgeGaps); ok {
+				if entry.Extra == nil {
+					entry.Extra = make(map[string]float64, len(ts.ExtraChannels))
+				}
+				entry.Extra[ch.Name] = data[p].Extra[ch.Name]
+			}
+		}
+
+		resampled.Data = append(resampled.Data, entry)
+	}
+
+	return resampled, nil
+}
+
+// resampleCursor walks the samples of a single channel in offset order. Targets must
+// be requested in increasing order.
+type resampleCursor struct {
+	data    []ActivityTimeseriesEntry
+	indices []int
+	pos     int
+}
+
+func newResampleCursor(data []ActivityTimeseriesEntry, valid func(*ActivityTimeseriesEntry) bool) *resampleCursor {
+	c := &resampleCursor{data: data}
+	for i := range data {
+		if valid(&data[i]) {
+			c.indices = append(c.indices, i)
+		}
+	}
+	return c
+}
+
+// at returns the samples bracketing the target offset and the fractional position of
+// the target between them. ok is false outside the channel's range, or inside a gap
+// longer than maxGap unless bridgeGaps is set.
+func (c *resampleCursor) at(target, maxGap int, bridgeGaps bool) (prev, next int, fraction float64, ok bool) {
+	if len(c.indices) == 0 {
+		return 0, 0, 0, false
+	}
+
+	for c.pos+1 < len(c.indices) && c.data[c.indices[c.pos+1]].Offset <= target {
+		c.pos++
+	}
+
+	prev = c.indices[c.pos]
+	if c.data[prev].Offset > target {
+		return 0, 0, 0, false
+	}
+	if c.data[prev].Offset == target {
+		return prev, prev, 0, true
+	}
+	if c.pos+1 >= len(c.indices) {
+		return 0, 0, 0, false
+	}
+
+	next = c.indices[c.pos+1]
+	span := c.data[next].Offset - c.data[prev].Offset
+	if !bridgeGaps && span > maxGap {
+		return 0, 0, 0, false
+	}
+
+	return prev, next, float64(target-c.data[prev].Offset) / float64(span), true
+}
+
+func lerp(a, b, fraction float64) float64 {
+	return a + (b-a)*fraction
+}
+
+// greatCircleInterpolate returns the point at the given fraction along the great circle
+// between two lat/lon points (spherical linear interpolation).
+func greatCircleInterpolate(lat1, lon1, lat2, lon2, fraction float64) (float64, float64) {
+	rad := math.Pi / 180.0
+
+	phi1, lambda1 := lat1*rad, lon1*rad
+	phi2, lambda2 := lat2*rad, lon2*rad
+
+	x1, y1, z1 := math.Cos(phi1)*math.Cos(lambda1), math.Cos(phi1)*math.Sin(lambda1), math.Sin(phi1)
+	x2, y2, z2 := math.Cos(phi2)*math.Cos(lambda2), math.Cos(phi2)*math.Sin(lambda2), math.Sin(phi2)
+
+	dot := math.Max(-1, math.Min(1, x1*x2+y1*y2+z1*z2))
+	delta := math.Acos(dot)
+
+	// Sub-millimetre steps: the chord is indistinguishable from the arc
+	if delta < 1e-9 {
+		return lerp(lat1, lat2, fraction), lerp(lon1, lon2, fraction)
+	}
+
+	a := math.Sin((1-fraction)*delta) / math.Sin(delta)
+	b := math.Sin(fraction*delta) / math.Sin(delta)
+
+	x := a*x1 + b*x2
+	y := a*y1 + b*y2
+	z := a*z1 + b*z2
+
+	return math.Atan2(z, math.Sqrt(x*x+y*y)) / rad, math.Atan2(y, x) / rad
+}
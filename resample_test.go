@@ -0,0 +1,97 @@
+package stride_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func TestResample(t *testing.T) {
+	ts := &ActivityTimeseries{
+		StartTime: time.Now(),
+		Data: []ActivityTimeseriesEntry{
+			{
+				Offset:    0,
+				HeartRate: Optional[uint8]{Value: 120, Valid: true},
+				Altitude:  Optional[float64]{Value: 100, Valid: true},
+				Distance:  Optional[Distance]{Value: 0, Valid: true},
+				Latitude:  Optional[float64]{Value: 0, Valid: true},
+				Longitude: Optional[float64]{Value: 0, Valid: true},
+			},
+			{
+				Offset:    4,
+				HeartRate: Optional[uint8]{Value: 140, Valid: true},
+				Altitude:  Optional[float64]{Value: 108, Valid: true},
+				Distance:  Optional[Distance]{Value: 40, Valid: true},
+				Latitude:  Optional[float64]{Value: 0.0004, Valid: true},
+				Longitude: Optional[float64]{Value: 0, Valid: true},
+			},
+			{
+				Offset:    64, // One minute pause
+				HeartRate: Optional[uint8]{Value: 100, Valid: true},
+				Altitude:  Optional[float64]{Value: 108, Valid: true},
+				Distance:  Optional[Distance]{Value: 40, Valid: true},
+			},
+		},
+	}
+
+	t.Run("InterpolatesPerChannel", func(t *testing.T) {
+		resampled, err := ts.Resample(time.Second, ResamplePolicy{Gap: GapPolicySkip})
+		require.NoError(t, err)
+
+		require.Len(t, resampled.Data, 6) // 0..4 and 64
+		mid := resampled.Data[2]
+
+		assert.Equal(t, 2, mid.Offset)
+		assert.Equal(t, uint8(120), mid.HeartRate.Value, "heart rate holds the previous value")
+		assert.InDelta(t, 104.0, mid.Altitude.Value, 0.001, "altitude is linear")
+		assert.Equal(t, Distance(20), mid.Distance.Value, "distance is linear")
+		assert.InDelta(t, 0.0002, mid.Latitude.Value, 1e-9, "position follows the great circle")
+		assert.Equal(t, 64, resampled.Data[5].Offset)
+	})
+
+	t.Run("ExtraChannels", func(t *testing.T) {
+		withExtra := *ts
+		withExtra.Data = append([]ActivityTimeseriesEntry(nil), ts.Data...)
+		withExtra.Data[0].Extra = map[string]float64{"form_power": 52.5}
+		withExtra.Data[1].Extra = map[string]float64{"form_power": 61}
+		withExtra.ExtraChannels = []ExtraChannel{{Name: "form_power", Units: "watts"}}
+
+		resampled, err := withExtra.Resample(time.Second, ResamplePolicy{Gap: GapPolicySkip})
+		require.NoError(t, err)
+
+		assert.Equal(t, withExtra.ExtraChannels, resampled.ExtraChannels)
+		assert.Equal(t, map[string]float64{"form_power": 52.5}, resampled.Data[2].Extra, "extra channels hold the previous value")
+		assert.Equal(t, map[string]float64{"form_power": 61}, resampled.Data[4].Extra)
+		assert.Nil(t, resampled.Data[5].Extra, "not carried across the pause")
+	})
+
+	t.Run("EmptyGapPolicy", func(t *testing.T) {
+		resampled, err := ts.Resample(10*time.Second, ResamplePolicy{Gap: GapPolicyEmpty})
+		require.NoError(t, err)
+
+		require.Len(t, resampled.Data, 7) // 0, 10, ..., 60
+		gap := resampled.Data[3]
+		assert.False(t, gap.HeartRate.Valid)
+		assert.Equal(t, Optional[bool]{Value: false, Valid: true}, gap.Moving)
+	})
+
+	t.Run("InterpolateGapPolicy", func(t *testing.T) {
+		resampled, err := ts.Resample(10*time.Second, ResamplePolicy{Gap: GapPolicyInterpolate})
+		require.NoError(t, err)
+
+		gap := resampled.Data[3]
+		assert.Equal(t, uint8(140), gap.HeartRate.Value)
+		assert.InDelta(t, 108.0, gap.Altitude.Value, 0.001)
+		assert.False(t, gap.Latitude.Valid, "no GPS after the pause to interpolate towards")
+	})
+
+	t.Run("InvalidInterval", func(t *testing.T) {
+		_, err := ts.Resample(500*time.Millisecond, ResamplePolicy{})
+		assert.ErrorIs(t, err, ErrInvalidResampleInterval)
+	})
+}
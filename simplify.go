@@ -0,0 +1,236 @@
+package stride
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrUnknownSimplifyMethod = errors.New("unknown track simplification method")
+
+type SimplifyMethod string
+
+const (
+	SimplifyDouglasPeucker SimplifyMethod = "douglas-peucker" // Keeps the shape within a distance of the full track
+	SimplifyVisvalingam    SimplifyMethod = "visvalingam"     // Drops the positions adding the least area, smoother at low detail
+)
+
+type SimplifyConfig struct {
+	Method SimplifyMethod // (default: SimplifyDouglasPeucker)
+
+	// ToleranceM is the largest distance between a removed position and the simplified
+	// track for Douglas-Peucker, and the side of the square with the area of the
+	// smallest triangle kept for Visvalingam. Zero keeps every position (default: 0)
+	ToleranceM float64
+}
+
+func (c SimplifyConfig) ApplyDefaults() SimplifyConfig {
+	if c.Method == "" {
+		c.Method = SimplifyDouglasPeucker
+	}
+
+	return c
+}
+
+// SimplifyTrack returns the positions of the timeseries that are kept by the
+// simplification, with all their channels. Entries without a position are dropped.
+func (ts *ActivityTimeseries) SimplifyTrack(config SimplifyConfig) (*ActivityTimeseries, error) {
+	config = config.ApplyDefaults()
+
+	var positions []ActivityTimeseriesEntry
+	for _, d := range ts.Data {
+		if d.HasGPS() {
+			positions = append(positions, d)
+		}
+	}
+
+	var kept []int
+	switch config.Method {
+	case SimplifyDouglasPeucker:
+		kept = douglasPeucker(projectTrack(positions), config.ToleranceM)
+
+	case SimplifyVisvalingam:
+		kept = visvalingam(projectTrack(positions), config.ToleranceM*config.ToleranceM)
+
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSimplifyMethod, config.Method)
+	}
+
+	simplified := &ActivityTimeseries{
+		StartTime:     ts.StartTime,
+		Data:          make([]ActivityTimeseriesEntry, len(kept)),
+		Pauses:        ts.Pauses,
+		ExtraChannels: ts.ExtraChannels,
+	}
+	for i, k := range kept {
+		simplified.Data[i] = positions[k]
+	}
+
+	return simplified, nil
+}
+
+// planarPoint is a position projected on a plane tangent to the start of the track, in
+// meters. The projection is accurate enough for the size of an activity.
+type planarPoint struct {
+	x, y float64
+}
+
+func projectTrack(positions []ActivityTimeseriesEntry) []planarPoint {
+	const R = 6371000.0 // Earth radius in meters, as in haversine
+	rad := math.Pi / 180.0
+
+	points := make([]planarPoint, len(positions))
+	if len(positions) == 0 {
+		return points
+	}
+
+	lat0, lon0 := positions[0].Latitude.Value, positions[0].Longitude.Value
+	cosLat0 := math.Cos(lat0 * rad)
+	for i, d := range positions {
+		points[i] = planarPoint{
+			x: (d.Longitude.Value - lon0) * rad * cosLat0 * R,
+			y: (d.Latitude.Value - lat0) * rad * R,
+		}
+	}
+
+	return points
+}
+
+// douglasPeucker returns the indexes of the points kept so that no removed point is
+// farther than tolerance from the simplified line.
+func douglasPeucker(points []planarPoint, tolerance float64) []int {
+	n := len(points)
+	if n <= 2 || tolerance <= 0 {
+		return allIndexes(n)
+	}
+
+	keep := make([]bool, n)
+	keep[0], keep[n-1] = true, true
+
+	// Iterative, long tracks would otherwise recurse thousands of levels deep
+	stack := [][2]int{{0, n - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		farthest, maxDistance := -1, tolerance
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance(points[i], points[first], points[last]); d > maxDistance {
+				farthest, maxDistance = i, d
+			}
+		}
+
+		if farthest >= 0 {
+			keep[farthest] = true
+			stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+		}
+	}
+
+	var kept []int
+	for i, k := range keep {
+		if k {
+			kept = append(kept, i)
+		}
+	}
+	return kept
+}
+
+// visvalingam returns the indexes of the points kept after repeatedly removing the point
+// whose triangle with its neighbors has the smallest area, until all are minArea or more.
+func visvalingam(points []planarPoint, minArea float64) []int {
+	n := len(points)
+	if n <= 2 || minArea <= 0 {
+		return allIndexes(n)
+	}
+
+	prev := make([]int, n)
+	next := make([]int, n)
+	h := &areaHeap{areas: make([]float64, n), positions: make([]int, n)}
+	for i := range points {
+		prev[i], next[i] = i-1, i+1
+		if i > 0 && i < n-1 {
+			h.areas[i] = triangleArea(points[i-1], points[i], points[i+1])
+			heap.Push(h, i)
+		}
+	}
+
+	removed := make([]bool, n)
+	for h.Len() > 0 && h.areas[h.items[0]] < minArea {
+		i := heap.Pop(h).(int)
+		removed[i] = true
+
+		p, q := prev[i], next[i]
+		next[p], prev[q] = q, p
+
+		for _, j := range []int{p, q} {
+			if j == 0 || j == n-1 {
+				continue
+			}
+			// A neighbor never gets a smaller area than the point just removed, so
+			// removals stay in order of increasing significance
+			h.areas[j] = math.Max(triangleArea(points[prev[j]], points[j], points[next[j]]), h.areas[i])
+			heap.Fix(h, h.positions[j])
+		}
+	}
+
+	var kept []int
+	for i, r := range removed {
+		if !r {
+			kept = append(kept, i)
+		}
+	}
+	return kept
+}
+
+// areaHeap is a min-heap of point indexes by area, tracking where each index is so that
+// neighbors can be fixed after a removal.
+type areaHeap struct {
+	items     []int
+	areas     []float64
+	positions []int
+}
+
+func (h *areaHeap) Len() int           { return len(h.items) }
+func (h *areaHeap) Less(a, b int) bool { return h.areas[h.items[a]] < h.areas[h.items[b]] }
+
+func (h *areaHeap) Swap(a, b int) {
+	h.items[a], h.items[b] = h.items[b], h.items[a]
+	h.positions[h.items[a]] = a
+	h.positions[h.items[b]] = b
+}
+
+func (h *areaHeap) Push(x any) {
+	h.positions[x.(int)] = len(h.items)
+	h.items = append(h.items, x.(int))
+}
+
+func (h *areaHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// segmentDistance is the distance from p to the segment between a and b.
+func segmentDistance(p, a, b planarPoint) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	lengthSq := dx*dx + dy*dy
+	if lengthSq == 0 {
+		return math.Hypot(p.x-a.x, p.y-a.y)
+	}
+
+	t := math.Max(0, math.Min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/lengthSq))
+	return math.Hypot(p.x-(a.x+t*dx), p.y-(a.y+t*dy))
+}
+
+func triangleArea(a, b, c planarPoint) float64 {
+	return math.Abs((b.x-a.x)*(c.y-a.y)-(c.x-a.x)*(b.y-a.y)) / 2
+}
+
+func allIndexes(n int) []int {
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
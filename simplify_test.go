@@ -0,0 +1,88 @@
+package stride_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func offsets(ts *ActivityTimeseries) []int {
+	result := make([]int, len(ts.Data))
+	for i, d := range ts.Data {
+		result[i] = d.Offset
+	}
+	return result
+}
+
+func TestSimplifyTrack(t *testing.T) {
+	start := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+
+	// 1 km north, 2 km east, no noise: only the corners matter
+	ts := hillRoute(start)
+	ts.Data[50].Latitude = Optional[float64]{}
+
+	for _, method := range []SimplifyMethod{SimplifyDouglasPeucker, SimplifyVisvalingam} {
+		t.Run(string(method), func(t *testing.T) {
+			simplified, err := ts.SimplifyTrack(SimplifyConfig{Method: method, ToleranceM: 5})
+			require.NoError(t, err)
+			assert.Equal(t, []int{0, 300, 900}, offsets(simplified))
+			assert.Equal(t, ts.Data[100], simplified.Data[1])
+
+			full, err := ts.SimplifyTrack(SimplifyConfig{Method: method})
+			require.NoError(t, err)
+			assert.Len(t, full.Data, len(ts.Data)-1)
+		})
+	}
+
+	t.Run("Tolerance", func(t *testing.T) {
+		const metersPerDegree = 111195.0
+		zigzag := &ActivityTimeseries{StartTime: start}
+		for i := 0; i <= 100; i++ {
+			// A 3 m wobble every 10 points and a 20 m detour at the middle
+			east := 0.0
+			if i%10 == 5 {
+				east = 3
+			}
+			if i == 50 {
+				east = 20
+			}
+			zigzag.Data = append(zigzag.Data, ActivityTimeseriesEntry{
+				Offset:    i,
+				Latitude:  Optional[float64]{Value: 45 + float64(i)*10/metersPerDegree, Valid: true},
+				Longitude: Optional[float64]{Value: 7 + east/(metersPerDegree*math.Cos(45*math.Pi/180)), Valid: true},
+			})
+		}
+
+		detail, err := zigzag.SimplifyTrack(SimplifyConfig{ToleranceM: 1})
+		require.NoError(t, err)
+		for i := 5; i < 100; i += 10 {
+			assert.Contains(t, offsets(detail), i)
+		}
+
+		summary, err := zigzag.SimplifyTrack(SimplifyConfig{ToleranceM: 5})
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 49, 50, 51, 100}, offsets(summary))
+
+		coarse, err := zigzag.SimplifyTrack(SimplifyConfig{ToleranceM: 25})
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 100}, offsets(coarse))
+
+		detail, err = zigzag.SimplifyTrack(SimplifyConfig{Method: SimplifyVisvalingam, ToleranceM: 1})
+		require.NoError(t, err)
+		summary, err = zigzag.SimplifyTrack(SimplifyConfig{Method: SimplifyVisvalingam, ToleranceM: 10})
+		require.NoError(t, err)
+		assert.Less(t, len(summary.Data), len(detail.Data))
+		assert.Contains(t, offsets(summary), 50)
+		assert.NotContains(t, offsets(summary), 45)
+	})
+
+	t.Run("UnknownMethod", func(t *testing.T) {
+		_, err := ts.SimplifyTrack(SimplifyConfig{Method: "nope"})
+		assert.ErrorIs(t, err, ErrUnknownSimplifyMethod)
+	})
+}
@@ -258,7 +258,7 @@ type ActivityDetailed struct {
 	EmbedToken         string      `json:"embed_token"`            // The token used to embed a Strava activity
 	SplitsMetric       []any       `json:"splits_metric"`          // The splits of this activity in metric units (for runs)
 	SplitsStandard     []any       `json:"splits_standard"`        // The splits of this activity in imperial units (for runs)
-	Laps               []any       `json:"laps"`                   // The laps of this activity
+	Laps               []Lap       `json:"laps"`                   // The laps of this activity
 	BestEfforts        []any       `json:"best_efforts"`           // The best efforts of this activity
 }
 
@@ -328,18 +328,69 @@ func (a ActivityDetailed) ToActivity() (*stride.Activity, error) {
 		return nil, err
 	}
 
+	laps := make([]stride.Lap, 0, len(a.Laps))
+	for _, lap := range a.Laps {
+		laps = append(laps, lap.ToLap(a.StartDate))
+	}
+
 	return &stride.Activity{
 		Provider:      stride.ProviderStrava,
 		Sport:         sport,
 		StartTime:     a.StartDate,
 		ElapsedTime:   uint32(a.ElapsedTime),
 		MovingTime:    uint32(a.MovingTime),
-		Distance:      uint32(a.Distance),
-		AvgSpeed:      uint16(a.AverageSpeed),
+		Distance:      stride.DistanceFromMeters(a.Distance),
+		AvgSpeed:      stride.SpeedFromMetersPerSecond(a.AverageSpeed),
 		ElevationGain: stride.Optional[uint16]{Valid: true, Value: uint16(a.TotalElevationGain)},
+		Laps:          laps,
 	}, nil
 }
 
+type Lap struct {
+	ID                 int64       `json:"id"`                   // The unique identifier of this lap
+	ResourceState      int         `json:"resource_state"`       // Resource state, indicates level of detail
+	Name               string      `json:"name"`                 // The name of the lap
+	Activity           MetaAthlete `json:"activity"`             // The activity this lap belongs to
+	Athlete            MetaAthlete `json:"athlete"`              // The athlete who performed this lap
+	ElapsedTime        int         `json:"elapsed_time"`         // The lap's elapsed time, in seconds
+	MovingTime         int         `json:"moving_time"`          // The lap's moving time, in seconds
+	StartDate          time.Time   `json:"start_date"`           // The time at which the lap was started
+	StartDateLocal     time.Time   `json:"start_date_local"`     // The time at which the lap was started in the local timezone
+	Distance           float64     `json:"distance"`             // The lap's distance, in meters
+	StartIndex         int         `json:"start_index"`          // The start index of this lap in the activity's stream
+	EndIndex           int         `json:"end_index"`            // The end index of this lap in the activity's stream
+	TotalElevationGain float64     `json:"total_elevation_gain"` // The elevation gain of this lap, in meters
+	AverageSpeed       float64     `json:"average_speed"`        // The lap's average speed, in meters per second
+	MaxSpeed           float64     `json:"max_speed"`            // The lap's max speed, in meters per second
+	AverageCadence     float64     `json:"average_cadence"`      // The lap's average cadence
+	AverageWatts       float64     `json:"average_watts"`        // The lap's average watts
+	DeviceWatts        bool        `json:"device_watts"`         // Whether the watts are from a power meter, false if estimated
+	AverageHeartrate   float64     `json:"average_heartrate"`    // The lap's average heart rate
+	MaxHeartrate       float64     `json:"max_heartrate"`        // The lap's max heart rate
+	LapIndex           int         `json:"lap_index"`            // The index of this lap in the activity it belongs to
+	Split              int         `json:"split"`                // The split number of this lap
+	PaceZone           int         `json:"pace_zone"`            // The athlete's pace zone during this lap
+}
+
+// ToLap converts the Strava lap into a stride.Lap. Strava does not say what triggered
+// a lap, so the trigger is always unknown.
+func (l Lap) ToLap(activityStart time.Time) stride.Lap {
+	startOffset := l.StartDate.Sub(activityStart)
+	if startOffset < 0 {
+		startOffset = 0
+	}
+
+	return stride.Lap{
+		StartOffset: uint32(startOffset.Seconds()),
+		Duration:    uint32(l.ElapsedTime),
+		Distance:    stride.DistanceFromMeters(l.Distance),
+		AvgSpeed:    stride.SpeedFromMetersPerSecond(l.AverageSpeed),
+		AvgHR:       stride.Optional[uint8]{Value: uint8(l.AverageHeartrate), Valid: l.AverageHeartrate > 0},
+		MaxHR:       stride.Optional[uint8]{Value: uint8(l.MaxHeartrate), Valid: l.MaxHeartrate > 0},
+		Trigger:     stride.LapTriggerUnknown,
+	}
+}
+
 type ActivityMap struct {
 	ID              string `json:"id"`
 	SummaryPolyline string `json:"summary_polyline"`
@@ -380,7 +431,7 @@ func (s *ActivityStream) ToTimeseries(startTime time.Time) (*stride.ActivityTime
 		}
 
 		if i < len(s.Distance.Data) {
-			data.Distance = stride.Optional[uint32]{Value: uint32(s.Distance.Data[i]), Valid: s.Distance.Data[i] > 0}
+			data.Distance = stride.Optional[stride.Distance]{Value: stride.DistanceFromMeters(s.Distance.Data[i]), Valid: s.Distance.Data[i] > 0}
 		}
 
 		if i < len(s.Altitude.Data) {
@@ -388,7 +439,23 @@ func (s *ActivityStream) ToTimeseries(startTime time.Time) (*stride.ActivityTime
 		}
 
 		if i < len(s.VelocitySmooth.Data) {
-			data.Velocity = stride.Optional[uint16]{Value: uint16(s.VelocitySmooth.Data[i]), Valid: s.VelocitySmooth.Data[i] > 0}
+			data.Velocity = stride.Optional[stride.Speed]{Value: stride.SpeedFromMetersPerSecond(s.VelocitySmooth.Data[i]), Valid: s.VelocitySmooth.Data[i] > 0}
+		}
+
+		if i < len(s.Watts.Data) {
+			data.Power = stride.Optional[uint16]{Value: uint16(s.Watts.Data[i]), Valid: s.Watts.Data[i] >= 0}
+		}
+
+		if i < len(s.Temperature.Data) {
+			data.Temperature = stride.Optional[int8]{Value: int8(s.Temperature.Data[i]), Valid: true}
+		}
+
+		if i < len(s.Moving.Data) {
+			data.Moving = stride.Optional[bool]{Value: s.Moving.Data[i], Valid: true}
+		}
+
+		if i < len(s.GradeSmooth.Data) {
+			data.Grade = stride.Optional[float64]{Value: s.GradeSmooth.Data[i], Valid: true}
 		}
 
 		if i < len(s.LatLng.Data) {
@@ -400,6 +467,9 @@ func (s *ActivityStream) ToTimeseries(startTime time.Time) (*stride.ActivityTime
 		ts.Data = append(ts.Data, data)
 	}
 
+	// Strava streams have no timer events, stops come from the moving stream and gaps
+	ts.Pauses = stride.DetectPauses(&ts, stride.PauseDetectionConfig{})
+
 	return &ts, nil
 }
 
@@ -0,0 +1,235 @@
+package stride
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/muktihari/fit/decoder"
+	"github.com/muktihari/fit/profile/mesgdef"
+	"github.com/muktihari/fit/profile/typedef"
+	"github.com/muktihari/fit/profile/untyped/mesgnum"
+	"github.com/muktihari/fit/proto"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// errStreamStopped aborts decoding once the consumer stops ranging over a stream.
+var errStreamStopped = errors.New("stream stopped")
+
+// TimeseriesStream reads the entries of an activity file one at a time, without
+// materializing the whole timeseries. Range over Entries once, then check Err, the same
+// way as a bufio.Scanner.
+type TimeseriesStream struct {
+	startTime time.Time
+	err       error
+	read      func(s *TimeseriesStream, yield func(ActivityTimeseriesEntry) bool) error
+}
+
+// Entries yields the entries in file order. Offsets are relative to StartTime.
+func (s *TimeseriesStream) Entries() iter.Seq[ActivityTimeseriesEntry] {
+	return func(yield func(ActivityTimeseriesEntry) bool) {
+		err := s.read(s, yield)
+		if !errors.Is(err, errStreamStopped) {
+			s.err = err
+		}
+	}
+}
+
+// StartTime is the time entry offsets are relative to. It is known once the first
+// entry has been yielded.
+func (s *TimeseriesStream) StartTime() time.Time {
+	return s.startTime
+}
+
+// Err returns the first error met while reading, if any.
+func (s *TimeseriesStream) Err() error {
+	return s.err
+}
+
+// Entries yields the entries of an in-memory timeseries, so it can be fed to the same
+// streaming calculators as a file.
+func (ts *ActivityTimeseries) Entries() iter.Seq[ActivityTimeseriesEntry] {
+	return func(yield func(ActivityTimeseriesEntry) bool) {
+		for _, entry := range ts.Data {
+			if !yield(entry) {
+				return
+			}
+		}
+	}
+}
+
+// StreamFITFile streams the records of a FIT activity file. Offsets start from the
+// first timer start event, or from the first record when the timer events come later.
+// Numeric developer fields are kept in the Extra of entries.
+func StreamFITFile(r io.Reader) *TimeseriesStream {
+	return &TimeseriesStream{read: readFITStream(r)}
+}
+
+func readFITStream(r io.Reader) func(*TimeseriesStream, func(ActivityTimeseriesEntry) bool) error {
+	return func(s *TimeseriesStream, yield func(ActivityTimeseriesEntry) bool) error {
+		reader := &stoppableReader{r: r}
+		var started bool
+		developer := newFitDeveloperFields()
+
+		listener := fitListener(func(mesg proto.Message) {
+			if reader.stopped {
+				return
+			}
+
+			switch mesg.Num {
+			case mesgnum.Event:
+				event := mesgdef.NewEvent(&mesg)
+				if !started && event.Event == typedef.EventTimer && event.EventType == typedef.EventTypeStart {
+					s.startTime = event.Timestamp
+					started = true
+				}
+
+			case mesgnum.DeveloperDataId:
+				developer.addDeveloperDataId(mesgdef.NewDeveloperDataId(&mesg))
+
+			case mesgnum.FieldDescription:
+				developer.addFieldDescription(mesgdef.NewFieldDescription(&mesg))
+
+			case mesgnum.Record:
+				record := mesgdef.NewRecord(&mesg)
+				if !started {
+					s.startTime = record.Timestamp
+					started = true
+				}
+				entry := fitRecordToEntry(record, s.startTime)
+				entry.Extra = developer.values(record.DeveloperFields)
+				if !yield(entry) {
+					reader.stopped = true
+				}
+			}
+		})
+
+		dec := decoder.New(reader, decoder.WithMesgListener(listener), decoder.WithBroadcastOnly())
+		if _, err := dec.Decode(); err != nil {
+			if reader.stopped {
+				return errStreamStopped
+			}
+			return err
+		}
+
+		return nil
+	}
+}
+
+type fitListener func(mesg proto.Message)
+
+func (f fitListener) OnMesg(mesg proto.Message) {
+	f(mesg)
+}
+
+// stoppableReader fails every read once stopped, making the decoder return early.
+type stoppableReader struct {
+	r       io.Reader
+	stopped bool
+}
+
+func (r *stoppableReader) Read(p []byte) (int, error) {
+	if r.stopped {
+		return 0, errStreamStopped
+	}
+	return r.r.Read(p)
+}
+
+// StreamGPXFile streams the points of all the tracks and segments of a GPX file, like
+// ParseGPXFileFromMemory. Offsets start from the first point. Points without a time or
+// going back in time are skipped.
+func StreamGPXFile(r io.Reader) *TimeseriesStream {
+	return &TimeseriesStream{read: readGPXStream(r)}
+}
+
+type gpxStreamPoint struct {
+	Lat        float64        `xml:"lat,attr"`
+	Lon        float64        `xml:"lon,attr"`
+	Ele        *float64       `xml:"ele"`
+	Time       string         `xml:"time"`
+	Extensions gpxStreamNodes `xml:"extensions"`
+}
+
+type gpxStreamNodes struct {
+	Nodes []gpx.ExtensionNode `xml:",any"`
+}
+
+func readGPXStream(r io.Reader) func(*TimeseriesStream, func(ActivityTimeseriesEntry) bool) error {
+	return func(s *TimeseriesStream, yield func(ActivityTimeseriesEntry) bool) error {
+		dec := xml.NewDecoder(r)
+		extensions := DefaultGPXExtensions()
+
+		var segments, points int
+		var last time.Time
+		for {
+			token, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrFailedToParseGPXFile, err)
+			}
+
+			start, ok := token.(xml.StartElement)
+			if !ok {
+				continue
+			}
+
+			switch start.Name.Local {
+			case "trkseg":
+				segments++
+
+			case "trkpt":
+				var point gpxStreamPoint
+				if err := dec.DecodeElement(&point, &start); err != nil {
+					return fmt.Errorf("%w: %w", ErrFailedToParseGPXFile, err)
+				}
+
+				timestamp, err := time.Parse(time.RFC3339, strings.TrimSpace(point.Time))
+				if err != nil || (points > 0 && timestamp.Before(last)) {
+					continue
+				}
+				if points == 0 {
+					s.startTime = timestamp
+				}
+				points++
+				last = timestamp
+
+				if !yield(point.toEntry(s.startTime, timestamp, extensions)) {
+					return nil
+				}
+			}
+		}
+
+		if points == 0 {
+			if segments == 0 {
+				return ErrNoTracksOrSegments
+			}
+			return ErrNoTrackPoints
+		}
+
+		return nil
+	}
+}
+
+func (p gpxStreamPoint) toEntry(startTime, timestamp time.Time, extensions *GPXExtensionRegistry) ActivityTimeseriesEntry {
+	entry := ActivityTimeseriesEntry{
+		Offset:    int(timestamp.Sub(startTime).Seconds()),
+		Latitude:  Optional[float64]{Value: p.Lat, Valid: p.Lat != 0},
+		Longitude: Optional[float64]{Value: p.Lon, Valid: p.Lon != 0},
+	}
+
+	if p.Ele != nil && !math.IsNaN(*p.Ele) {
+		entry.Altitude = Optional[float64]{Value: *p.Ele, Valid: true}
+	}
+
+	// Malformed values are left invalid, a stream has no way to report them
+	_ = extensions.decode(&entry, p.Extensions.Nodes)
+
+	return entry
+}
@@ -0,0 +1,133 @@
+package stride_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func TestStreamFITFile(t *testing.T) {
+	start := time.Date(2025, 9, 1, 6, 0, 0, 0, time.UTC)
+	ts := steadyRun(start, 900)
+	act := &Activity{Sport: SportRunning, StartTime: start, ElapsedTime: 900}
+
+	data, err := CreateFITFileInMemory(act, ts, SportRunning)
+	require.NoError(t, err)
+
+	expected, err := FITFileToActivityTimeseries(data)
+	require.NoError(t, err)
+
+	stream := StreamFITFile(bytes.NewReader(data))
+	entries := slices.Collect(stream.Entries())
+	require.NoError(t, stream.Err())
+
+	assert.Equal(t, expected.StartTime, stream.StartTime())
+	assert.Equal(t, expected.Data, entries)
+
+	t.Run("StopEarly", func(t *testing.T) {
+		stream := StreamFITFile(bytes.NewReader(data))
+
+		var count int
+		for range stream.Entries() {
+			count++
+			if count == 10 {
+				break
+			}
+		}
+
+		assert.Equal(t, 10, count)
+		assert.NoError(t, stream.Err())
+	})
+
+	t.Run("InvalidFile", func(t *testing.T) {
+		stream := StreamFITFile(bytes.NewReader([]byte("not a fit file")))
+		for range stream.Entries() {
+		}
+		assert.Error(t, stream.Err())
+	})
+}
+
+func TestStreamGPXFile(t *testing.T) {
+	start := time.Date(2025, 9, 1, 6, 0, 0, 0, time.UTC)
+	ts := steadyRun(start, 300)
+	for i := range ts.Data {
+		ts.Data[i].Distance = Optional[Distance]{}
+	}
+
+	data, err := CreateGPXFileInMemory(&Activity{Sport: SportRunning, StartTime: start}, ts)
+	require.NoError(t, err)
+
+	_, expected, err := ParseGPXFileFromMemory(data)
+	require.NoError(t, err)
+
+	stream := StreamGPXFile(bytes.NewReader(data))
+	entries := slices.Collect(stream.Entries())
+	require.NoError(t, stream.Err())
+
+	assert.Equal(t, expected.StartTime, stream.StartTime())
+	assert.Equal(t, expected.Data, entries)
+
+	stream = StreamGPXFile(bytes.NewReader([]byte(`<gpx><trk><trkseg></trkseg></trk></gpx>`)))
+	for range stream.Entries() {
+	}
+	assert.ErrorIs(t, stream.Err(), ErrNoTrackPoints)
+}
+
+func TestSummarizeStream(t *testing.T) {
+	start := time.Date(2025, 9, 1, 6, 0, 0, 0, time.UTC)
+	ts := steadyRun(start, 1800)
+	for i := range ts.Data {
+		ts.Data[i].HeartRate.Value = uint8(130 + i%40)
+	}
+	ts.Data = slices.Delete(ts.Data, 600, 630) // recording gap
+
+	expected := &Activity{}
+	require.NoError(t, RecomputeSummary(expected, ts, AugmentConfig{}))
+
+	streamed := &Activity{}
+	SummarizeStream(streamed, ts.StartTime, ts.Entries(), AugmentConfig{})
+
+	assert.Equal(t, expected, streamed)
+}
+
+func TestHeartRateAccumulator(t *testing.T) {
+	ts := &ActivityTimeseries{}
+	for i := 0; i < 500; i++ {
+		ts.Data = append(ts.Data, ActivityTimeseriesEntry{
+			Offset:    i * (1 + i%3),
+			HeartRate: Optional[uint8]{Value: uint8(100 + (i*37)%90), Valid: i%11 != 0},
+		})
+	}
+	slices.SortFunc(ts.Data, func(a, b ActivityTimeseriesEntry) int { return a.Offset - b.Offset })
+
+	for _, method := range []AvgHeartRateMethod{HeartRateMethodSimple, HeartRateMethodTimeWeighted, HeartRateMethodZoneWeighted} {
+		avgConfig := AvgHeartRateAnalysisConfig{Method: method, MaxHeartRate: 190}
+		maxConfig := MaxHeartRateAnalysisConfig{Method: MaxHeartRateMethodPercentile, PercentileValue: 90}
+
+		acc := NewHeartRateAccumulator(avgConfig, maxConfig)
+		for entry := range ts.Entries() {
+			acc.Add(entry)
+		}
+
+		expectedAvg, err := CalculateAverageHeartRate(ts, avgConfig)
+		require.NoError(t, err)
+		avg, err := acc.AverageHeartRate()
+		require.NoError(t, err)
+		assert.InDelta(t, expectedAvg, avg, 1e-9)
+
+		expectedMax, err := CalculateMaxHeartRate(ts, maxConfig)
+		require.NoError(t, err)
+		maxHR, err := acc.MaxHeartRate()
+		require.NoError(t, err)
+		assert.Equal(t, expectedMax, maxHR)
+	}
+
+	_, err := NewHeartRateAccumulator(AvgHeartRateAnalysisConfig{}, MaxHeartRateAnalysisConfig{}).AverageHeartRate()
+	assert.ErrorIs(t, err, ErrEmptyTimeseriesData)
+}
@@ -0,0 +1,391 @@
+package stride
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrFailedToParseTCXFile = errors.New("failed to parse TCX file")
+	ErrNoTCXActivity        = errors.New("no activity found in TCX")
+)
+
+const (
+	tcxNamespace          = "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+	tcxExtensionNamespace = "http://www.garmin.com/xmlschemas/ActivityExtension/v2"
+)
+
+// TCX documents. Elements are matched by local name when reading, so files with unusual
+// namespace prefixes still parse; the extension namespace is set for writing.
+type tcxDatabase struct {
+	XMLName    xml.Name      `xml:"TrainingCenterDatabase"`
+	Xmlns      string        `xml:"xmlns,attr,omitempty"`
+	Activities []tcxActivity `xml:"Activities>Activity"`
+}
+
+type tcxActivity struct {
+	Sport string   `xml:"Sport,attr"`
+	ID    string   `xml:"Id"`
+	Laps  []tcxLap `xml:"Lap"`
+}
+
+type tcxLap struct {
+	StartTime        time.Time        `xml:"StartTime,attr"`
+	TotalTimeSeconds float64          `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64          `xml:"DistanceMeters"`
+	Calories         int              `xml:"Calories"`
+	AvgHeartRate     *tcxValue        `xml:"AverageHeartRateBpm,omitempty"`
+	MaxHeartRate     *tcxValue        `xml:"MaximumHeartRateBpm,omitempty"`
+	Intensity        string           `xml:"Intensity"`
+	TriggerMethod    string           `xml:"TriggerMethod"`
+	Trackpoints      []tcxTrackpoint  `xml:"Track>Trackpoint"`
+	Extensions       *tcxLapExtension `xml:"Extensions>LX,omitempty"`
+}
+
+type tcxLapExtension struct {
+	Xmlns    string   `xml:"xmlns,attr,omitempty"`
+	AvgSpeed *float64 `xml:"AvgSpeed,omitempty"`
+}
+
+type tcxTrackpoint struct {
+	Time           time.Time               `xml:"Time"`
+	Position       *tcxPosition            `xml:"Position,omitempty"`
+	AltitudeMeters *float64                `xml:"AltitudeMeters,omitempty"`
+	DistanceMeters *float64                `xml:"DistanceMeters,omitempty"`
+	HeartRate      *tcxValue               `xml:"HeartRateBpm,omitempty"`
+	Cadence        *float64                `xml:"Cadence,omitempty"`
+	Extensions     *tcxTrackpointExtension `xml:"Extensions>TPX,omitempty"`
+}
+
+type tcxPosition struct {
+	Latitude  float64 `xml:"LatitudeDegrees"`
+	Longitude float64 `xml:"LongitudeDegrees"`
+}
+
+type tcxValue struct {
+	Value float64 `xml:"Value"`
+}
+
+type tcxTrackpointExtension struct {
+	Xmlns      string   `xml:"xmlns,attr,omitempty"`
+	Speed      *float64 `xml:"Speed,omitempty"`
+	RunCadence *float64 `xml:"RunCadence,omitempty"`
+	Watts      *float64 `xml:"Watts,omitempty"`
+}
+
+// CreateTCXFileInMemory writes the activity as a TCX file with one Lap per activity lap,
+// or a single lap when the activity has none. Speed and power go in the
+// ActivityExtension/v2 trackpoint extension, as do running cadences.
+func CreateTCXFileInMemory(act *Activity, ts *ActivityTimeseries) ([]byte, error) {
+	laps := act.Laps
+	if len(laps) == 0 {
+		laps = []Lap{{
+			Duration: uint32(ts.MaxOffset()),
+			Distance: act.Distance,
+			AvgSpeed: act.AvgSpeed,
+			AvgHR:    act.AvgHR,
+			MaxHR:    act.MaxHR,
+			Trigger:  LapTriggerManual,
+		}}
+	}
+
+	activity := tcxActivity{
+		Sport: sportToTCXSport(act.Sport),
+		ID:    act.StartTime.UTC().Format(time.RFC3339),
+	}
+
+	for _, lap := range laps {
+		tcxLap := tcxLap{
+			StartTime:        act.StartTime.Add(time.Duration(lap.StartOffset) * time.Second).UTC(),
+			TotalTimeSeconds: float64(lap.Duration),
+			DistanceMeters:   lap.Distance.Meters(),
+			Intensity:        "Active",
+			TriggerMethod:    lapTriggerToTCXTriggerMethod(lap.Trigger),
+		}
+
+		if lap.AvgHR.Valid {
+			tcxLap.AvgHeartRate = &tcxValue{Value: float64(lap.AvgHR.Value)}
+		}
+		if lap.MaxHR.Valid {
+			tcxLap.MaxHeartRate = &tcxValue{Value: float64(lap.MaxHR.Value)}
+		}
+		if lap.AvgSpeed > 0 {
+			avgSpeed := lap.AvgSpeed.MetersPerSecond()
+			tcxLap.Extensions = &tcxLapExtension{Xmlns: tcxExtensionNamespace, AvgSpeed: &avgSpeed}
+		}
+
+		activity.Laps = append(activity.Laps, tcxLap)
+	}
+
+	runningCadence := act.Sport == SportRunning || act.Sport == SportTrailRunning
+	for _, d := range ts.Data {
+		if d.IsEmpty() {
+			continue
+		}
+
+		// Samples before the first lap go in it, the others in the last lap started
+		lapIndex := 0
+		for i, lap := range laps {
+			if uint32(max(d.Offset, 0)) >= lap.StartOffset {
+				lapIndex = i
+			}
+		}
+
+		activity.Laps[lapIndex].Trackpoints = append(activity.Laps[lapIndex].Trackpoints, entryToTCXTrackpoint(ts.StartTime, d, runningCadence))
+	}
+
+	db := tcxDatabase{
+		Xmlns:      tcxNamespace,
+		Activities: []tcxActivity{activity},
+	}
+
+	xmlBytes, err := xml.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), xmlBytes...), nil
+}
+
+func entryToTCXTrackpoint(startTime time.Time, d ActivityTimeseriesEntry, runningCadence bool) tcxTrackpoint {
+	point := tcxTrackpoint{
+		Time: startTime.Add(time.Duration(d.Offset) * time.Second).UTC(),
+	}
+
+	if d.HasGPS() {
+		point.Position = &tcxPosition{Latitude: d.Latitude.Value, Longitude: d.Longitude.Value}
+	}
+
+	if d.Altitude.Valid {
+		altitude := d.Altitude.Value
+		point.AltitudeMeters = &altitude
+	}
+
+	if d.Distance.Valid {
+		distance := d.Distance.Value.Meters()
+		point.DistanceMeters = &distance
+	}
+
+	if d.HeartRate.Valid {
+		point.HeartRate = &tcxValue{Value: float64(d.HeartRate.Value)}
+	}
+
+	ext := tcxTrackpointExtension{Xmlns: tcxExtensionNamespace}
+	if d.Cadence.Valid {
+		cadence := float64(d.Cadence.Value)
+		if runningCadence {
+			ext.RunCadence = &cadence
+		} else {
+			point.Cadence = &cadence
+		}
+	}
+
+	if d.Velocity.Valid {
+		speed := d.Velocity.Value.MetersPerSecond()
+		ext.Speed = &speed
+	}
+
+	if d.Power.Valid {
+		watts := float64(d.Power.Value)
+		ext.Watts = &watts
+	}
+
+	if ext.Speed != nil || ext.RunCadence != nil || ext.Watts != nil {
+		point.Extensions = &ext
+	}
+
+	return point
+}
+
+// ParseTCXFileFromMemory reads the first activity of a TCX file. Offsets and lap starts
+// are relative to the activity Id, the TCX start time, and the summary is recomputed
+// from the trackpoints.
+func ParseTCXFileFromMemory(data []byte) (*Activity, *ActivityTimeseries, error) {
+	var db tcxDatabase
+	if err := xml.Unmarshal(data, &db); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrFailedToParseTCXFile, err)
+	}
+
+	if len(db.Activities) == 0 {
+		return nil, nil, ErrNoTCXActivity
+	}
+	activity := db.Activities[0]
+
+	var points []tcxTrackpoint
+	for _, lap := range activity.Laps {
+		points = append(points, lap.Trackpoints...)
+	}
+	if len(points) == 0 {
+		return nil, nil, ErrNoTrackPoints
+	}
+
+	startTime, err := time.Parse(time.RFC3339, activity.ID)
+	if err != nil {
+		// The Id is only required to be unique, fall back to the first lap or point
+		startTime = points[0].Time
+		if len(activity.Laps) > 0 && !activity.Laps[0].StartTime.IsZero() {
+			startTime = activity.Laps[0].StartTime
+		}
+	}
+	startTime = startTime.UTC()
+
+	ts := &ActivityTimeseries{StartTime: startTime}
+	for _, p := range points {
+		entry := tcxTrackpointToEntry(startTime, p)
+		if entry.IsEmpty() {
+			continue // Pause markers only carry a time
+		}
+		ts.Data = append(ts.Data, entry)
+	}
+	if len(ts.Data) == 0 {
+		return nil, nil, ErrNoTrackPoints
+	}
+
+	act := &Activity{
+		Sport: tcxSportToSport(activity.Sport),
+	}
+
+	if err := RecomputeSummary(act, ts, AugmentConfig{}); err != nil {
+		return nil, nil, err
+	}
+	// The summary is measured from the activity start, not from the first trackpoint
+	act.ElapsedTime = uint32(max(ts.MaxOffset(), 0))
+
+	for _, l := range activity.Laps {
+		lap := Lap{
+			StartOffset: uint32(max(l.StartTime.Sub(startTime).Seconds(), 0)),
+			Duration:    uint32(math.Round(l.TotalTimeSeconds)),
+			Distance:    DistanceFromMeters(l.DistanceMeters),
+			Trigger:     tcxTriggerMethodToLapTrigger(l.TriggerMethod),
+		}
+
+		if l.AvgHeartRate != nil {
+			lap.AvgHR = Optional[uint8]{Value: uint8(math.Round(l.AvgHeartRate.Value)), Valid: true}
+		}
+		if l.MaxHeartRate != nil {
+			lap.MaxHR = Optional[uint8]{Value: uint8(math.Round(l.MaxHeartRate.Value)), Valid: true}
+		}
+
+		if l.Extensions != nil && l.Extensions.AvgSpeed != nil {
+			lap.AvgSpeed = SpeedFromMetersPerSecond(*l.Extensions.AvgSpeed)
+		} else if l.TotalTimeSeconds > 0 {
+			lap.AvgSpeed = SpeedFromMetersPerSecond(l.DistanceMeters / l.TotalTimeSeconds)
+		}
+
+		act.Laps = append(act.Laps, lap)
+	}
+
+	return act, ts, nil
+}
+
+func tcxTrackpointToEntry(startTime time.Time, p tcxTrackpoint) ActivityTimeseriesEntry {
+	entry := ActivityTimeseriesEntry{
+		Offset: int(p.Time.Sub(startTime).Seconds()),
+	}
+
+	if p.Position != nil {
+		entry.Latitude = Optional[float64]{Value: p.Position.Latitude, Valid: true}
+		entry.Longitude = Optional[float64]{Value: p.Position.Longitude, Valid: true}
+	}
+
+	if p.AltitudeMeters != nil {
+		entry.Altitude = Optional[float64]{Value: *p.AltitudeMeters, Valid: true}
+	}
+
+	if p.DistanceMeters != nil {
+		entry.Distance = Optional[Distance]{Value: DistanceFromMeters(*p.DistanceMeters), Valid: true}
+	}
+
+	if p.HeartRate != nil {
+		entry.HeartRate = Optional[uint8]{Value: uint8(min(math.Round(p.HeartRate.Value), math.MaxUint8)), Valid: true}
+	}
+
+	if p.Cadence != nil {
+		entry.Cadence = Optional[uint8]{Value: uint8(min(math.Round(*p.Cadence), math.MaxUint8)), Valid: true}
+	}
+
+	if ext := p.Extensions; ext != nil {
+		if ext.RunCadence != nil {
+			entry.Cadence = Optional[uint8]{Value: uint8(min(math.Round(*ext.RunCadence), math.MaxUint8)), Valid: true}
+		}
+
+		if ext.Speed != nil {
+			entry.Velocity = Optional[Speed]{Value: SpeedFromMetersPerSecond(*ext.Speed), Valid: true}
+		}
+
+		if ext.Watts != nil {
+			entry.Power = Optional[uint16]{Value: uint16(min(math.Round(*ext.Watts), math.MaxUint16)), Valid: true}
+		}
+	}
+
+	return entry
+}
+
+func tcxSportToSport(sport string) Sport {
+	switch sport {
+	case "Running":
+		return SportRunning
+
+	case "Biking":
+		return SportCycling
+
+	default:
+		return SportUnknown
+
+	}
+}
+
+// sportToTCXSport maps to the three sports of the TCX schema.
+func sportToTCXSport(sport Sport) string {
+	switch sport {
+	case SportRunning, SportTrailRunning:
+		return "Running"
+
+	case SportCycling, SportGravelCycling:
+		return "Biking"
+
+	default:
+		return "Other"
+
+	}
+}
+
+func tcxTriggerMethodToLapTrigger(method string) LapTrigger {
+	switch method {
+	case "Manual":
+		return LapTriggerManual
+
+	case "Distance":
+		return LapTriggerDistance
+
+	case "Location":
+		return LapTriggerPosition
+
+	case "Time":
+		return LapTriggerTime
+
+	default:
+		return LapTriggerUnknown
+
+	}
+}
+
+func lapTriggerToTCXTriggerMethod(trigger LapTrigger) string {
+	switch trigger {
+	case LapTriggerDistance:
+		return "Distance"
+
+	case LapTriggerPosition:
+		return "Location"
+
+	case LapTriggerTime:
+		return "Time"
+
+	default:
+		return "Manual"
+
+	}
+}
@@ -0,0 +1,167 @@
+package stride_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func TestTCXRoundTrip(t *testing.T) {
+	start := time.Date(2025, 12, 5, 6, 30, 0, 0, time.UTC)
+	ts := steadyRun(start, 1200)
+	for i := range ts.Data {
+		ts.Data[i].Cadence = Optional[uint8]{Value: 88, Valid: true}
+		ts.Data[i].Power = Optional[uint16]{Value: uint16(240 + i%20), Valid: true}
+		ts.Data[i].Velocity = Optional[Speed]{Value: 3000, Valid: true}
+	}
+
+	act := &Activity{Sport: SportRunning}
+	require.NoError(t, RecomputeSummary(act, ts, AugmentConfig{}))
+	act.Laps = []Lap{
+		{StartOffset: 0, Duration: 600, Distance: 1800, AvgSpeed: 3000, AvgHR: Optional[uint8]{Value: 150, Valid: true}, MaxHR: Optional[uint8]{Value: 150, Valid: true}, Trigger: LapTriggerDistance},
+		{StartOffset: 600, Duration: 600, Distance: 1800, AvgSpeed: 3000, Trigger: LapTriggerManual},
+	}
+
+	data, err := CreateTCXFileInMemory(act, ts)
+	require.NoError(t, err)
+
+	parsedAct, parsedTs, err := ParseTCXFileFromMemory(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, ts.Data, parsedTs.Data)
+	assert.Equal(t, start, parsedTs.StartTime)
+	assert.Equal(t, act.Laps, parsedAct.Laps)
+	assert.Equal(t, SportRunning, parsedAct.Sport)
+	assert.Equal(t, act.Distance, parsedAct.Distance)
+	assert.Equal(t, act.ElapsedTime, parsedAct.ElapsedTime)
+	assert.Equal(t, act.AvgHR, parsedAct.AvgHR)
+
+	t.Run("Cycling", func(t *testing.T) {
+		data, err := CreateTCXFileInMemory(&Activity{Sport: SportGravelCycling, StartTime: start}, ts)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `<Activity Sport="Biking">`)
+		assert.Contains(t, string(data), `<Cadence>88</Cadence>`)
+		assert.Contains(t, string(data), `<TPX xmlns="http://www.garmin.com/xmlschemas/ActivityExtension/v2">`)
+
+		parsedAct, parsedTs, err := ParseTCXFileFromMemory(data)
+		require.NoError(t, err)
+		assert.Equal(t, SportCycling, parsedAct.Sport)
+		assert.Len(t, parsedAct.Laps, 1)
+		assert.Equal(t, ts.Data, parsedTs.Data)
+	})
+}
+
+func TestParseTCXFileFromMemory(t *testing.T) {
+	// Garmin Connect style export, with prefixed extensions and a pause marker
+	data := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase
+  xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+  xmlns:ns3="http://www.garmin.com/xmlschemas/ActivityExtension/v2">
+  <Activities>
+    <Activity Sport="Biking">
+      <Id>2024-05-01T07:00:00.000Z</Id>
+      <Lap StartTime="2024-05-01T07:00:00.000Z">
+        <TotalTimeSeconds>10.0</TotalTimeSeconds>
+        <DistanceMeters>80.5</DistanceMeters>
+        <Calories>3</Calories>
+        <AverageHeartRateBpm><Value>131</Value></AverageHeartRateBpm>
+        <Intensity>Active</Intensity>
+        <TriggerMethod>Time</TriggerMethod>
+        <Track>
+          <Trackpoint>
+            <Time>2024-05-01T07:00:00.000Z</Time>
+            <Position><LatitudeDegrees>46.5</LatitudeDegrees><LongitudeDegrees>11.3</LongitudeDegrees></Position>
+            <AltitudeMeters>250.4</AltitudeMeters>
+            <DistanceMeters>0.0</DistanceMeters>
+            <HeartRateBpm><Value>128</Value></HeartRateBpm>
+            <Cadence>85</Cadence>
+            <Extensions><ns3:TPX><ns3:Speed>8.1</ns3:Speed><ns3:Watts>210</ns3:Watts></ns3:TPX></Extensions>
+          </Trackpoint>
+          <Trackpoint>
+            <Time>2024-05-01T07:00:05.000Z</Time>
+          </Trackpoint>
+          <Trackpoint>
+            <Time>2024-05-01T07:00:10.000Z</Time>
+            <Position><LatitudeDegrees>46.5007</LatitudeDegrees><LongitudeDegrees>11.3</LongitudeDegrees></Position>
+            <DistanceMeters>80.5</DistanceMeters>
+            <HeartRateBpm><Value>134</Value></HeartRateBpm>
+          </Trackpoint>
+        </Track>
+      </Lap>
+    </Activity>
+  </Activities>
+</TrainingCenterDatabase>`)
+
+	act, ts, err := ParseTCXFileFromMemory(data)
+	require.NoError(t, err)
+
+	require.Len(t, ts.Data, 2)
+	assert.Equal(t, ActivityTimeseriesEntry{
+		Offset:    0,
+		HeartRate: Optional[uint8]{Value: 128, Valid: true},
+		Cadence:   Optional[uint8]{Value: 85, Valid: true},
+		Distance:  Optional[Distance]{Value: 0, Valid: true},
+		Altitude:  Optional[float64]{Value: 250.4, Valid: true},
+		Velocity:  Optional[Speed]{Value: 8100, Valid: true},
+		Latitude:  Optional[float64]{Value: 46.5, Valid: true},
+		Longitude: Optional[float64]{Value: 11.3, Valid: true},
+		Power:     Optional[uint16]{Value: 210, Valid: true},
+	}, ts.Data[0])
+	assert.Equal(t, 10, ts.Data[1].Offset)
+
+	assert.Equal(t, SportCycling, act.Sport)
+	assert.Equal(t, uint32(10), act.ElapsedTime)
+	assert.Equal(t, Distance(81), act.Distance)
+	require.Len(t, act.Laps, 1)
+	assert.Equal(t, Lap{
+		Duration: 10,
+		Distance: 81,
+		AvgSpeed: 8050,
+		AvgHR:    Optional[uint8]{Value: 131, Valid: true},
+		Trigger:  LapTriggerTime,
+	}, act.Laps[0])
+
+	t.Run("UnqualifiedExtensions", func(t *testing.T) {
+		data := []byte(`<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+  <Activities>
+    <Activity Sport="Running">
+      <Id>2024-05-01T07:00:00Z</Id>
+      <Lap StartTime="2024-05-01T07:00:00Z">
+        <TotalTimeSeconds>5</TotalTimeSeconds>
+        <DistanceMeters>15</DistanceMeters>
+        <Track>
+          <Trackpoint>
+            <Time>2024-05-01T07:00:00Z</Time>
+            <Extensions><TPX><Speed>3</Speed><RunCadence>86</RunCadence></TPX></Extensions>
+          </Trackpoint>
+          <Trackpoint>
+            <Time>2024-05-01T07:00:05Z</Time>
+            <Extensions><x:TPX xmlns:x="urn:example:tpx"><x:Speed>3.2</x:Speed></x:TPX></Extensions>
+          </Trackpoint>
+        </Track>
+        <Extensions><LX><AvgSpeed>3.1</AvgSpeed></LX></Extensions>
+      </Lap>
+    </Activity>
+  </Activities>
+</TrainingCenterDatabase>`)
+
+		act, ts, err := ParseTCXFileFromMemory(data)
+		require.NoError(t, err)
+		require.Len(t, ts.Data, 2)
+		assert.Equal(t, Optional[Speed]{Value: 3000, Valid: true}, ts.Data[0].Velocity)
+		assert.Equal(t, Optional[uint8]{Value: 86, Valid: true}, ts.Data[0].Cadence)
+		assert.Equal(t, Optional[Speed]{Value: 3200, Valid: true}, ts.Data[1].Velocity)
+		require.Len(t, act.Laps, 1)
+		assert.Equal(t, Speed(3100), act.Laps[0].AvgSpeed)
+	})
+
+	_, _, err = ParseTCXFileFromMemory([]byte("not xml"))
+	assert.ErrorIs(t, err, ErrFailedToParseTCXFile)
+
+	_, _, err = ParseTCXFileFromMemory([]byte(`<TrainingCenterDatabase><Activities></Activities></TrainingCenterDatabase>`))
+	assert.ErrorIs(t, err, ErrNoTCXActivity)
+}
@@ -139,8 +139,8 @@ func (s TrainingPeaksWorkoutSummary) ToActivity() (*stride.Activity, error) {
 	return &stride.Activity{
 		StartTime:     s.GetStartTime(),
 		ElapsedTime:   uint32(s.TotalTime * 3600),
-		Distance:      uint32(s.Distance),
-		AvgSpeed:      uint16(s.VelocityAverage),
+		Distance:      stride.DistanceFromMeters(s.Distance),
+		AvgSpeed:      stride.SpeedFromMetersPerSecond(s.VelocityAverage),
 		ElevationGain: stride.Optional[uint16]{Valid: true, Value: uint16(s.ElevationGain)},
 		ElevationLoss: stride.Optional[uint16]{Valid: true, Value: uint16(s.ElevationLoss)},
 		AvgHR:         stride.Optional[uint8]{Valid: true, Value: uint8(s.HeartRateAverage)},
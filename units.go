@@ -0,0 +1,182 @@
+package stride
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const metersPerMile = 1609.344
+
+// Speed is stored in millimeters per second, the resolution used by FIT files.
+type Speed uint16
+
+// SpeedFromMetersPerSecond rounds to the nearest mm/s. Negative and NaN speeds become
+// zero and speeds above the representable range are clamped.
+func SpeedFromMetersPerSecond(ms float64) Speed {
+	if math.IsNaN(ms) || ms <= 0 {
+		return 0
+	}
+	mms := math.Round(ms * 1000)
+	if mms > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return Speed(mms)
+}
+
+func SpeedFromKilometersPerHour(kmh float64) Speed {
+	return SpeedFromMetersPerSecond(kmh / 3.6)
+}
+
+func SpeedFromMilesPerHour(mph float64) Speed {
+	return SpeedFromMetersPerSecond(mph * metersPerMile / 3600)
+}
+
+func (s Speed) MetersPerSecond() float64 {
+	return float64(s) / 1000
+}
+
+func (s Speed) KilometersPerHour() float64 {
+	return s.MetersPerSecond() * 3.6
+}
+
+func (s Speed) MilesPerHour() float64 {
+	return s.MetersPerSecond() * 3600 / metersPerMile
+}
+
+// Pace returns the time per kilometer at this speed, zero when not moving.
+func (s Speed) Pace() Pace {
+	if s == 0 {
+		return Pace{}
+	}
+	return PaceFromDuration(time.Duration(float64(time.Second) * 1000 / s.MetersPerSecond()))
+}
+
+// Distance is stored in meters.
+type Distance uint32
+
+// DistanceFromMeters rounds to the nearest meter. Negative and NaN distances become zero.
+func DistanceFromMeters(m float64) Distance {
+	if math.IsNaN(m) || m <= 0 {
+		return 0
+	}
+	if m > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return Distance(math.Round(m))
+}
+
+func DistanceFromKilometers(km float64) Distance {
+	return DistanceFromMeters(km * 1000)
+}
+
+func DistanceFromMiles(mi float64) Distance {
+	return DistanceFromMeters(mi * metersPerMile)
+}
+
+func (d Distance) Meters() float64 {
+	return float64(d)
+}
+
+func (d Distance) Kilometers() float64 {
+	return float64(d) / 1000
+}
+
+func (d Distance) Miles() float64 {
+	return float64(d) / metersPerMile
+}
+
+// DistanceUnit selects the unit of distances in exported and imported files.
+type DistanceUnit string
+
+const (
+	DistanceUnitMeters     DistanceUnit = "m"
+	DistanceUnitKilometers DistanceUnit = "km"
+	DistanceUnitMiles      DistanceUnit = "mi"
+)
+
+// From converts the distance to the unit. Unknown units are meters.
+func (u DistanceUnit) From(d Distance) float64 {
+	switch u {
+	case DistanceUnitKilometers:
+		return d.Kilometers()
+	case DistanceUnitMiles:
+		return d.Miles()
+	default:
+		return d.Meters()
+	}
+}
+
+// To converts a value in the unit to a Distance.
+func (u DistanceUnit) To(v float64) Distance {
+	switch u {
+	case DistanceUnitKilometers:
+		return DistanceFromKilometers(v)
+	case DistanceUnitMiles:
+		return DistanceFromMiles(v)
+	default:
+		return DistanceFromMeters(v)
+	}
+}
+
+// SpeedUnit selects the unit of speeds in exported and imported files.
+type SpeedUnit string
+
+const (
+	SpeedUnitMetersPerSecond   SpeedUnit = "m/s"
+	SpeedUnitKilometersPerHour SpeedUnit = "km/h"
+	SpeedUnitMilesPerHour      SpeedUnit = "mph"
+)
+
+// From converts the speed to the unit. Unknown units are meters per second.
+func (u SpeedUnit) From(s Speed) float64 {
+	switch u {
+	case SpeedUnitKilometersPerHour:
+		return s.KilometersPerHour()
+	case SpeedUnitMilesPerHour:
+		return s.MilesPerHour()
+	default:
+		return s.MetersPerSecond()
+	}
+}
+
+// To converts a value in the unit to a Speed.
+func (u SpeedUnit) To(v float64) Speed {
+	switch u {
+	case SpeedUnitKilometersPerHour:
+		return SpeedFromKilometersPerHour(v)
+	case SpeedUnitMilesPerHour:
+		return SpeedFromMilesPerHour(v)
+	default:
+		return SpeedFromMetersPerSecond(v)
+	}
+}
+
+// Pace is the time needed to cover one kilometer.
+type Pace struct {
+	Minutes int
+	Seconds int
+}
+
+// PaceFromDuration truncates the time per kilometer to whole seconds.
+func PaceFromDuration(perKm time.Duration) Pace {
+	seconds := int(perKm / time.Second)
+	return Pace{Minutes: seconds / 60, Seconds: seconds % 60}
+}
+
+func (p Pace) Duration() time.Duration {
+	return time.Duration(p.Minutes)*time.Minute + time.Duration(p.Seconds)*time.Second
+}
+
+// Speed returns the speed matching the pace, zero for an empty pace.
+func (p Pace) Speed() Speed {
+	if p.Duration() <= 0 {
+		return 0
+	}
+	return SpeedFromMetersPerSecond(1000 / p.Duration().Seconds())
+}
+
+// String formats the pace as MM:SS (per kilometer).
+func (p Pace) String() string {
+	return fmt.Sprintf("%d:%02d", p.Minutes, p.Seconds)
+}
@@ -0,0 +1,59 @@
+package stride_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func TestUnits(t *testing.T) {
+	speed := SpeedFromMetersPerSecond(3.3333)
+	assert.Equal(t, Speed(3333), speed)
+	assert.InDelta(t, 12.0, speed.KilometersPerHour(), 0.01)
+	assert.Equal(t, Speed(0), SpeedFromMetersPerSecond(-1))
+	assert.Equal(t, SpeedFromKilometersPerHour(36), SpeedFromMetersPerSecond(10))
+
+	assert.Equal(t, Pace{Minutes: 5, Seconds: 0}, speed.Pace())
+	assert.Equal(t, "5:00", speed.Pace().String())
+	assert.Equal(t, Speed(3333), Pace{Minutes: 5}.Speed())
+	assert.Equal(t, 8*time.Minute, Pace{Minutes: 8}.Duration())
+
+	assert.Equal(t, Distance(1609), DistanceFromMiles(1))
+	assert.Equal(t, Distance(42195), DistanceFromKilometers(42.195))
+	assert.InDelta(t, 42.195, Distance(42195).Kilometers(), 1e-9)
+
+	assert.Equal(t, Distance(1609), DistanceUnitMiles.To(1))
+	assert.InDelta(t, 26.219, DistanceUnitMiles.From(42195), 0.001)
+	assert.Equal(t, Speed(2778), SpeedUnitKilometersPerHour.To(10))
+	assert.InDelta(t, 6.71, SpeedUnitMilesPerHour.From(3000), 0.01)
+	assert.Equal(t, SpeedFromMetersPerSecond(3.0), SpeedUnitMilesPerHour.To(SpeedUnitMilesPerHour.From(3000)))
+}
+
+func TestFITSpeedAndDistanceRoundTrip(t *testing.T) {
+	start := time.Date(2025, 8, 1, 6, 0, 0, 0, time.UTC)
+	ts := steadyRun(start, 600)
+
+	act := &Activity{}
+	AugmentGPXData(act, ts, AugmentConfig{})
+	assert.InDelta(t, 3000, float64(act.AvgSpeed), 5, "3 m/s is stored as mm/s")
+	assert.InDelta(t, 3.0, ts.Data[100].Velocity.Value.MetersPerSecond(), 0.01)
+
+	data, err := CreateFITFileInMemory(act, ts, SportRunning)
+	require.NoError(t, err)
+
+	parsed, err := FITFileToActivityTimeseries(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, ts.Data[600].Distance, parsed.Data[600].Distance)
+	assert.Equal(t, ts.Data[100].Velocity, parsed.Data[100].Velocity)
+
+	sessions, err := FITFileToSessions(data)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, act.AvgSpeed, sessions[0].AvgSpeed)
+	assert.Equal(t, act.Distance, sessions[0].Distance)
+}
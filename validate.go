@@ -0,0 +1,272 @@
+package stride
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var ErrInvalidActivity = errors.New("invalid activity")
+
+// Severity ranks validation findings. Errors mean the data is inconsistent and analyses
+// would be wrong; warnings mean the data is implausible but usable.
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// FindingCode identifies one of the checks of Validate.
+type FindingCode string
+
+const (
+	FindingMovingTimeExceedsElapsed FindingCode = "moving_time_exceeds_elapsed"
+	FindingAvgHeartRateExceedsMax   FindingCode = "avg_heart_rate_exceeds_max"
+	FindingLapOutOfRange            FindingCode = "lap_out_of_range"
+	FindingNonMonotonicOffsets      FindingCode = "non_monotonic_offsets"
+	FindingDuplicateOffsets         FindingCode = "duplicate_offsets"
+	FindingNegativeOffsets          FindingCode = "negative_offsets"
+	FindingHeartRateOutOfRange      FindingCode = "heart_rate_out_of_range"
+	FindingDistanceDecreasing       FindingCode = "distance_decreasing"
+	FindingInvalidPosition          FindingCode = "invalid_position"
+	FindingInvalidPauses            FindingCode = "invalid_pauses"
+	FindingStartTimeMismatch        FindingCode = "start_time_mismatch"
+	FindingElapsedTimeMismatch      FindingCode = "elapsed_time_mismatch"
+	FindingDistanceMismatch         FindingCode = "distance_mismatch"
+)
+
+type ValidationConfig struct {
+	MinHeartRate uint8 // Lower readings are out of range (default: 25)
+	MaxHeartRate uint8 // Higher readings are out of range (default: 250)
+
+	DistanceTolerance     float64       // Allowed relative difference between the activity and timeseries distance (default: 0.05)
+	MinDistanceToleranceM float64       // Allowed difference in meters for short activities (default: 100)
+	TimeTolerance         time.Duration // Allowed difference between the activity and timeseries start and elapsed times (default: 60s)
+}
+
+func (c ValidationConfig) ApplyDefaults() ValidationConfig {
+	config := c
+	if config.MinHeartRate == 0 {
+		config.MinHeartRate = 25
+	}
+	if config.MaxHeartRate == 0 {
+		config.MaxHeartRate = 250
+	}
+	if config.DistanceTolerance == 0 {
+		config.DistanceTolerance = 0.05
+	}
+	if config.MinDistanceToleranceM == 0 {
+		config.MinDistanceToleranceM = 100
+	}
+	if config.TimeTolerance == 0 {
+		config.TimeTolerance = 60 * time.Second
+	}
+	return config
+}
+
+// Finding is the result of a failed check. Indices are the positions in the timeseries
+// Data of the samples involved, empty for findings about the activity summary.
+type Finding struct {
+	Code     FindingCode
+	Severity Severity
+	Message  string
+	Indices  []int
+}
+
+type ValidationReport struct {
+	Findings []Finding
+}
+
+// HasErrors reports whether any finding has SeverityError.
+func (r *ValidationReport) HasErrors() bool {
+	for _, f := range r.Findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the finding with the code, if any.
+func (r *ValidationReport) Find(code FindingCode) (Finding, bool) {
+	for _, f := range r.Findings {
+		if f.Code == code {
+			return f, true
+		}
+	}
+	return Finding{}, false
+}
+
+// Err returns an error wrapping ErrInvalidActivity when the report has errors, so
+// ingestion can reject the activity with a single check. Warnings are not errors.
+func (r *ValidationReport) Err() error {
+	var errs []Finding
+	for _, f := range r.Findings {
+		if f.Severity == SeverityError {
+			errs = append(errs, f)
+		}
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("%w: %s", ErrInvalidActivity, errs[0].Message)
+	default:
+		return fmt.Errorf("%w: %s (and %d more)", ErrInvalidActivity, errs[0].Message, len(errs)-1)
+	}
+}
+
+func (r *ValidationReport) add(code FindingCode, severity Severity, indices []int, format string, args ...any) {
+	r.Findings = append(r.Findings, Finding{
+		Code:     code,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+		Indices:  indices,
+	})
+}
+
+// Validate checks that the activity summary is consistent, that the timeseries is well
+// formed and plausible, and that both agree with each other. Either of them may be nil
+// to validate only the other. Samples failing the same check are reported in a single
+// finding.
+func Validate(act *Activity, ts *ActivityTimeseries, config ValidationConfig) *ValidationReport {
+	config = config.ApplyDefaults()
+	report := &ValidationReport{}
+
+	if act != nil {
+		validateActivity(report, act)
+	}
+
+	if ts != nil {
+		validateTimeseries(report, ts, config)
+	}
+
+	if act != nil && ts != nil && len(ts.Data) > 0 {
+		validateAgreement(report, act, ts, config)
+	}
+
+	return report
+}
+
+func validateActivity(report *ValidationReport, act *Activity) {
+	if act.MovingTime > act.ElapsedTime {
+		report.add(FindingMovingTimeExceedsElapsed, SeverityError, nil,
+			"moving time %ds exceeds elapsed time %ds", act.MovingTime, act.ElapsedTime)
+	}
+
+	if act.AvgHR.Valid && act.MaxHR.Valid && act.AvgHR.Value > act.MaxHR.Value {
+		report.add(FindingAvgHeartRateExceedsMax, SeverityError, nil,
+			"average heart rate %d exceeds max heart rate %d", act.AvgHR.Value, act.MaxHR.Value)
+	}
+
+	for i, lap := range act.Laps {
+		if lap.EndOffset() > act.ElapsedTime {
+			report.add(FindingLapOutOfRange, SeverityWarning, nil,
+				"lap %d ends at %ds, after the activity (%ds)", i+1, lap.EndOffset(), act.ElapsedTime)
+		}
+	}
+}
+
+func validateTimeseries(report *ValidationReport, ts *ActivityTimeseries, config ValidationConfig) {
+	var nonMonotonic, duplicates, negative, heartRate, distance, position []int
+
+	lastDistance := -1
+	for i, entry := range ts.Data {
+		if entry.Offset < 0 {
+			negative = append(negative, i)
+		}
+
+		if i > 0 {
+			switch prev := ts.Data[i-1]; {
+			case entry.Offset < prev.Offset:
+				nonMonotonic = append(nonMonotonic, i)
+			case entry.Offset == prev.Offset:
+				duplicates = append(duplicates, i)
+			}
+		}
+
+		if entry.HeartRate.Valid && (entry.HeartRate.Value < config.MinHeartRate || entry.HeartRate.Value > config.MaxHeartRate) {
+			heartRate = append(heartRate, i)
+		}
+
+		if entry.Distance.Valid {
+			if lastDistance >= 0 && entry.Distance.Value < ts.Data[lastDistance].Distance.Value {
+				distance = append(distance, i)
+			}
+			lastDistance = i
+		}
+
+		if (entry.Latitude.Valid && !validCoordinate(entry.Latitude.Value, 90)) ||
+			(entry.Longitude.Valid && !validCoordinate(entry.Longitude.Value, 180)) {
+			position = append(position, i)
+		}
+	}
+
+	if len(nonMonotonic) > 0 {
+		report.add(FindingNonMonotonicOffsets, SeverityError, nonMonotonic,
+			"%d samples go back in time", len(nonMonotonic))
+	}
+	if len(duplicates) > 0 {
+		report.add(FindingDuplicateOffsets, SeverityWarning, duplicates,
+			"%d samples repeat the offset of the previous one", len(duplicates))
+	}
+	if len(negative) > 0 {
+		report.add(FindingNegativeOffsets, SeverityWarning, negative,
+			"%d samples are before the start time", len(negative))
+	}
+	if len(heartRate) > 0 {
+		report.add(FindingHeartRateOutOfRange, SeverityWarning, heartRate,
+			"%d heart rate samples outside %d-%d bpm", len(heartRate), config.MinHeartRate, config.MaxHeartRate)
+	}
+	if len(distance) > 0 {
+		report.add(FindingDistanceDecreasing, SeverityWarning, distance,
+			"%d distance samples decrease", len(distance))
+	}
+	if len(position) > 0 {
+		report.add(FindingInvalidPosition, SeverityError, position,
+			"%d positions outside valid latitude and longitude", len(position))
+	}
+
+	for i, p := range ts.Pauses {
+		if p.EndOffset <= p.StartOffset || (i > 0 && p.StartOffset < ts.Pauses[i-1].EndOffset) {
+			report.add(FindingInvalidPauses, SeverityError, nil,
+				"pause %d (%d-%d) is empty, reversed or overlaps the previous one", i+1, p.StartOffset, p.EndOffset)
+			break
+		}
+	}
+}
+
+func validCoordinate(v, limit float64) bool {
+	return !math.IsNaN(v) && v >= -limit && v <= limit
+}
+
+func validateAgreement(report *ValidationReport, act *Activity, ts *ActivityTimeseries, config ValidationConfig) {
+	if diff := act.StartTime.Sub(ts.StartTime); diff > config.TimeTolerance || diff < -config.TimeTolerance {
+		report.add(FindingStartTimeMismatch, SeverityWarning, nil,
+			"activity starts at %s, timeseries at %s", act.StartTime.Format(time.RFC3339), ts.StartTime.Format(time.RFC3339))
+	}
+
+	span := ts.MaxOffset() - ts.Data[0].Offset
+	if diff := time.Duration(int(act.ElapsedTime)-span) * time.Second; diff > config.TimeTolerance || diff < -config.TimeTolerance {
+		report.add(FindingElapsedTimeMismatch, SeverityWarning, nil,
+			"activity elapsed time %ds, timeseries spans %ds", act.ElapsedTime, span)
+	}
+
+	last := -1
+	for i, entry := range ts.Data {
+		if entry.Distance.Valid && (last < 0 || entry.Distance.Value >= ts.Data[last].Distance.Value) {
+			last = i
+		}
+	}
+	if last >= 0 {
+		recorded := ts.Data[last].Distance.Value.Meters()
+		tolerance := math.Max(config.MinDistanceToleranceM, config.DistanceTolerance*act.Distance.Meters())
+		if math.Abs(act.Distance.Meters()-recorded) > tolerance {
+			report.add(FindingDistanceMismatch, SeverityWarning, []int{last},
+				"activity distance %dm, timeseries reaches %dm", act.Distance, ts.Data[last].Distance.Value)
+		}
+	}
+}
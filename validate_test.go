@@ -0,0 +1,104 @@
+package stride_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func TestValidate(t *testing.T) {
+	start := time.Date(2025, 12, 1, 7, 0, 0, 0, time.UTC)
+	ts := steadyRun(start, 600)
+	act := &Activity{}
+	require.NoError(t, RecomputeSummary(act, ts, AugmentConfig{}))
+	act.Laps = []Lap{{StartOffset: 0, Duration: 300}, {StartOffset: 300, Duration: 300}}
+
+	report := Validate(act, ts, ValidationConfig{})
+	assert.Empty(t, report.Findings)
+	assert.NoError(t, report.Err())
+
+	t.Run("Activity", func(t *testing.T) {
+		bad := *act
+		bad.MovingTime = bad.ElapsedTime + 10
+		bad.AvgHR = Optional[uint8]{Value: 170, Valid: true}
+		bad.MaxHR = Optional[uint8]{Value: 160, Valid: true}
+		bad.Laps = append(bad.Laps, Lap{StartOffset: 600, Duration: 300})
+
+		report := Validate(&bad, nil, ValidationConfig{})
+		codes := findingCodes(report)
+		assert.Equal(t, []FindingCode{FindingMovingTimeExceedsElapsed, FindingAvgHeartRateExceedsMax, FindingLapOutOfRange}, codes)
+		assert.True(t, report.HasErrors())
+
+		err := report.Err()
+		assert.ErrorIs(t, err, ErrInvalidActivity)
+		assert.ErrorContains(t, err, "(and 1 more)")
+	})
+
+	t.Run("Timeseries", func(t *testing.T) {
+		bad := steadyRun(start, 600)
+		bad.Data[10].Offset = 8
+		bad.Data[20].Offset = 19
+		bad.Data[30].HeartRate = Optional[uint8]{Value: 255, Valid: true}
+		bad.Data[31].HeartRate = Optional[uint8]{Value: 10, Valid: true}
+		bad.Data[40].Distance = Optional[Distance]{Value: 50, Valid: true}
+		bad.Data[41].Distance = Optional[Distance]{}
+		bad.Data[50].Latitude = Optional[float64]{Value: 95, Valid: true}
+		bad.Pauses = []Pause{{StartOffset: 100, EndOffset: 120}, {StartOffset: 110, EndOffset: 130}}
+
+		report := Validate(nil, bad, ValidationConfig{})
+
+		expected := map[FindingCode][]int{
+			FindingNonMonotonicOffsets: {10},
+			FindingDuplicateOffsets:    {20},
+			FindingHeartRateOutOfRange: {30, 31},
+			FindingDistanceDecreasing:  {40},
+			FindingInvalidPosition:     {50},
+			FindingInvalidPauses:       nil,
+		}
+		assert.Len(t, report.Findings, len(expected))
+		for code, indices := range expected {
+			finding, ok := report.Find(code)
+			require.True(t, ok, code)
+			assert.Equal(t, indices, finding.Indices, code)
+		}
+
+		finding, _ := report.Find(FindingHeartRateOutOfRange)
+		assert.Equal(t, SeverityWarning, finding.Severity)
+		assert.Equal(t, "2 heart rate samples outside 25-250 bpm", finding.Message)
+
+		finding, _ = report.Find(FindingNonMonotonicOffsets)
+		assert.Equal(t, SeverityError, finding.Severity)
+	})
+
+	t.Run("Agreement", func(t *testing.T) {
+		bad := *act
+		bad.StartTime = start.Add(time.Hour)
+		bad.ElapsedTime = 3600
+		bad.MovingTime = 3600
+		bad.Laps = nil
+		bad.Distance = 5000
+
+		report := Validate(&bad, ts, ValidationConfig{})
+		assert.Equal(t, []FindingCode{FindingStartTimeMismatch, FindingElapsedTimeMismatch, FindingDistanceMismatch}, findingCodes(report))
+		assert.False(t, report.HasErrors())
+
+		finding, _ := report.Find(FindingDistanceMismatch)
+		assert.Equal(t, []int{600}, finding.Indices)
+
+		bad.Distance = act.Distance + 90
+		_, ok := Validate(&bad, ts, ValidationConfig{}).Find(FindingDistanceMismatch)
+		assert.False(t, ok, "within the minimum tolerance")
+	})
+}
+
+func findingCodes(report *ValidationReport) []FindingCode {
+	var codes []FindingCode
+	for _, f := range report.Findings {
+		codes = append(codes, f.Code)
+	}
+	return codes
+}
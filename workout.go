@@ -0,0 +1,440 @@
+package stride
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/muktihari/fit/decoder"
+	"github.com/muktihari/fit/encoder"
+	"github.com/muktihari/fit/profile/basetype"
+	"github.com/muktihari/fit/profile/filedef"
+	"github.com/muktihari/fit/profile/mesgdef"
+	"github.com/muktihari/fit/profile/typedef"
+	"github.com/muktihari/fit/proto"
+)
+
+var (
+	ErrInvalidWorkout        = errors.New("invalid workout")
+	ErrNotFITWorkout         = errors.New("FIT file is not a workout")
+	ErrUnsupportedFITWorkout = errors.New("unsupported FIT workout step")
+)
+
+// Workout is a planned session, made of steps that can be grouped into repeats.
+type Workout struct {
+	Name        string
+	Description string
+	Sport       Sport
+	Steps       []WorkoutStep
+}
+
+type WorkoutIntensity string
+
+const (
+	WorkoutIntensityActive   WorkoutIntensity = "active"
+	WorkoutIntensityWarmup   WorkoutIntensity = "warmup"
+	WorkoutIntensityCooldown WorkoutIntensity = "cooldown"
+	WorkoutIntensityRecovery WorkoutIntensity = "recovery"
+	WorkoutIntensityRest     WorkoutIntensity = "rest"
+	WorkoutIntensityInterval WorkoutIntensity = "interval"
+)
+
+// WorkoutStep is either a single step or, when Repetitions is set, a repeat of Steps.
+type WorkoutStep struct {
+	Name      string
+	Notes     string
+	Intensity WorkoutIntensity // (default: active)
+
+	// The step ends after Duration or Distance, at most one of them, or when the lap
+	// button is pressed if neither is set.
+	Duration time.Duration
+	Distance Distance
+
+	Target Optional[WorkoutTarget]
+
+	Repetitions int
+	Steps       []WorkoutStep
+}
+
+// IsRepeat reports whether the step repeats other steps.
+func (s WorkoutStep) IsRepeat() bool {
+	return s.Repetitions > 0
+}
+
+type WorkoutTargetType string
+
+const (
+	WorkoutTargetHeartRate WorkoutTargetType = "heart_rate"
+	WorkoutTargetSpeed     WorkoutTargetType = "speed"
+	WorkoutTargetPower     WorkoutTargetType = "power"
+	WorkoutTargetCadence   WorkoutTargetType = "cadence"
+)
+
+// WorkoutTarget is the range to stay in during a step. Low and High are in bpm, mm/s
+// (the unit of Speed), watts or rpm depending on Type. Heart rate and power targets can
+// use one of the athlete's zones instead.
+type WorkoutTarget struct {
+	Type WorkoutTargetType
+	Zone uint8 // Zone number, replaces Low and High when set
+	Low  uint32
+	High uint32
+}
+
+func HeartRateTarget(low, high uint8) Optional[WorkoutTarget] {
+	return Optional[WorkoutTarget]{Value: WorkoutTarget{Type: WorkoutTargetHeartRate, Low: uint32(low), High: uint32(high)}, Valid: true}
+}
+
+func HeartRateZoneTarget(zone uint8) Optional[WorkoutTarget] {
+	return Optional[WorkoutTarget]{Value: WorkoutTarget{Type: WorkoutTargetHeartRate, Zone: zone}, Valid: true}
+}
+
+// PaceTarget targets the speeds between a slow and a fast pace.
+func PaceTarget(slow, fast Pace) Optional[WorkoutTarget] {
+	return Optional[WorkoutTarget]{Value: WorkoutTarget{Type: WorkoutTargetSpeed, Low: uint32(slow.Speed()), High: uint32(fast.Speed())}, Valid: true}
+}
+
+func PowerTarget(low, high uint16) Optional[WorkoutTarget] {
+	return Optional[WorkoutTarget]{Value: WorkoutTarget{Type: WorkoutTargetPower, Low: uint32(low), High: uint32(high)}, Valid: true}
+}
+
+func PowerZoneTarget(zone uint8) Optional[WorkoutTarget] {
+	return Optional[WorkoutTarget]{Value: WorkoutTarget{Type: WorkoutTargetPower, Zone: zone}, Valid: true}
+}
+
+// CreateFITWorkoutFileInMemory encodes the workout as a FIT workout file, ready to be
+// copied to a watch. Repeats become FIT repeat steps placed after the steps they repeat.
+func CreateFITWorkoutFileInMemory(workout *Workout, config FITExportConfig) ([]byte, error) {
+	if len(workout.Steps) == 0 {
+		return nil, fmt.Errorf("%w: no steps", ErrInvalidWorkout)
+	}
+
+	fitSport, err := sportToFitSport(workout.Sport)
+	if err != nil {
+		return nil, err
+	}
+
+	device := config.Device
+	if !device.Valid {
+		device = Optional[Device]{Value: defaultFITDevice, Valid: true}
+	}
+
+	file := filedef.NewWorkout()
+
+	file.FileId = *deviceToFitFileId(device.Value).SetType(typedef.FileWorkout)
+	if !config.TimeCreated.IsZero() {
+		file.FileId.SetTimeCreated(config.TimeCreated)
+	}
+
+	steps, err := workoutStepsToFitSteps(workout.Steps, nil)
+	if err != nil {
+		return nil, err
+	}
+	file.WorkoutSteps = steps
+
+	file.Workout = mesgdef.NewWorkout(nil).
+		SetSport(fitSport.Sport).
+		SetSubSport(fitSport.SubSport).
+		SetNumValidSteps(uint16(len(steps)))
+
+	if workout.Name != "" {
+		file.Workout.SetWktName(workout.Name)
+	}
+
+	if workout.Description != "" {
+		file.Workout.SetWktDescription(workout.Description)
+	}
+
+	fit := file.ToFIT(nil)
+
+	buf := new(bytes.Buffer)
+
+	enc := encoder.New(buf, encoder.WithProtocolVersion(proto.V2))
+	if err := enc.Encode(&fit); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func workoutStepsToFitSteps(steps []WorkoutStep, fitSteps []*mesgdef.WorkoutStep) ([]*mesgdef.WorkoutStep, error) {
+	for _, step := range steps {
+		first := len(fitSteps)
+
+		if step.IsRepeat() {
+			if len(step.Steps) == 0 {
+				return nil, fmt.Errorf("%w: repeat of no steps", ErrInvalidWorkout)
+			}
+
+			var err error
+			fitSteps, err = workoutStepsToFitSteps(step.Steps, fitSteps)
+			if err != nil {
+				return nil, err
+			}
+
+			fitStep := mesgdef.NewWorkoutStep(nil).
+				SetMessageIndex(typedef.MessageIndex(len(fitSteps))).
+				SetDurationType(typedef.WktStepDurationRepeatUntilStepsCmplt).
+				SetDurationValue(uint32(first)).
+				SetTargetType(typedef.WktStepTargetOpen).
+				SetTargetValue(uint32(step.Repetitions))
+
+			if step.Name != "" {
+				fitStep.SetWktStepName(step.Name)
+			}
+
+			fitSteps = append(fitSteps, fitStep)
+			continue
+		}
+
+		fitStep, err := workoutStepToFitStep(step)
+		if err != nil {
+			return nil, err
+		}
+
+		fitSteps = append(fitSteps, fitStep.SetMessageIndex(typedef.MessageIndex(first)))
+	}
+
+	return fitSteps, nil
+}
+
+func workoutStepToFitStep(step WorkoutStep) (*mesgdef.WorkoutStep, error) {
+	fitStep := mesgdef.NewWorkoutStep(nil).
+		SetIntensity(workoutIntensityToFitIntensity(step.Intensity))
+
+	if step.Name != "" {
+		fitStep.SetWktStepName(step.Name)
+	}
+
+	if step.Notes != "" {
+		fitStep.SetNotes(step.Notes)
+	}
+
+	switch {
+	case step.Duration > 0 && step.Distance > 0:
+		return nil, fmt.Errorf("%w: step %q has both a duration and a distance", ErrInvalidWorkout, step.Name)
+
+	case step.Duration > 0:
+		fitStep.SetDurationType(typedef.WktStepDurationTime).
+			SetDurationValue(uint32(step.Duration.Milliseconds()))
+
+	case step.Distance > 0:
+		fitStep.SetDurationType(typedef.WktStepDurationDistance).
+			SetDurationValue(uint32(step.Distance) * 100)
+
+	default:
+		fitStep.SetDurationType(typedef.WktStepDurationOpen)
+	}
+
+	if !step.Target.Valid {
+		fitStep.SetTargetType(typedef.WktStepTargetOpen)
+		return fitStep, nil
+	}
+
+	target := step.Target.Value
+	if target.Zone == 0 && target.Low > target.High {
+		return nil, fmt.Errorf("%w: step %q target low %d above high %d", ErrInvalidWorkout, step.Name, target.Low, target.High)
+	}
+
+	// FIT offsets custom heart rate and power values to tell them apart from zones
+	var offset uint32
+	switch target.Type {
+	case WorkoutTargetHeartRate:
+		fitStep.SetTargetType(typedef.WktStepTargetHeartRate)
+		offset = 100
+
+	case WorkoutTargetSpeed:
+		fitStep.SetTargetType(typedef.WktStepTargetSpeed)
+
+	case WorkoutTargetPower:
+		fitStep.SetTargetType(typedef.WktStepTargetPower)
+		offset = 1000
+
+	case WorkoutTargetCadence:
+		fitStep.SetTargetType(typedef.WktStepTargetCadence)
+
+	default:
+		return nil, fmt.Errorf("%w: step %q has unknown target %q", ErrInvalidWorkout, step.Name, target.Type)
+	}
+
+	if target.Zone > 0 {
+		if target.Type != WorkoutTargetHeartRate && target.Type != WorkoutTargetPower {
+			return nil, fmt.Errorf("%w: step %q has a zone for a %s target", ErrInvalidWorkout, step.Name, target.Type)
+		}
+		fitStep.SetTargetValue(uint32(target.Zone))
+		return fitStep, nil
+	}
+
+	fitStep.SetTargetValue(0).
+		SetCustomTargetValueLow(target.Low + offset).
+		SetCustomTargetValueHigh(target.High + offset)
+
+	return fitStep, nil
+}
+
+// ParseFITWorkoutFile decodes a FIT workout file, rebuilding repeats from the FIT repeat
+// steps. Steps ending on other conditions than time, distance or the lap button are not
+// supported.
+func ParseFITWorkoutFile(data []byte) (*Workout, error) {
+	dec := decoder.New(bytes.NewReader(data))
+
+	fit, err := dec.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToParseFITFile, err)
+	}
+
+	file := filedef.NewWorkout(fit.Messages...)
+	if file.FileId.Type != typedef.FileWorkout || file.Workout == nil {
+		return nil, fmt.Errorf("%w: file type %s", ErrNotFITWorkout, file.FileId.Type)
+	}
+
+	workout := &Workout{
+		Name:        file.Workout.WktName,
+		Description: file.Workout.WktDescription,
+		Sport:       fitSportToSport(FITSport{Sport: file.Workout.Sport, SubSport: file.Workout.SubSport}),
+	}
+
+	// Each decoded step remembers the index of its first FIT step, which repeats refer to
+	type indexedStep struct {
+		first int
+		step  WorkoutStep
+	}
+	var steps []indexedStep
+
+	for i, fitStep := range file.WorkoutSteps {
+		if fitStep.DurationType != typedef.WktStepDurationRepeatUntilStepsCmplt {
+			step, err := fitStepToWorkoutStep(fitStep)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, indexedStep{first: i, step: step})
+			continue
+		}
+
+		from := int(fitStep.DurationValue)
+		if from >= i {
+			return nil, fmt.Errorf("%w: step %d repeats from step %d", ErrInvalidWorkout, i, from)
+		}
+
+		repeat := WorkoutStep{
+			Name:        fitStep.WktStepName,
+			Repetitions: int(fitStep.TargetValue),
+		}
+
+		split := len(steps)
+		for split > 0 && steps[split-1].first >= from {
+			split--
+		}
+		for _, s := range steps[split:] {
+			repeat.Steps = append(repeat.Steps, s.step)
+		}
+
+		steps = append(steps[:split], indexedStep{first: from, step: repeat})
+	}
+
+	for _, s := range steps {
+		workout.Steps = append(workout.Steps, s.step)
+	}
+
+	return workout, nil
+}
+
+func fitStepToWorkoutStep(fitStep *mesgdef.WorkoutStep) (WorkoutStep, error) {
+	step := WorkoutStep{
+		Name:      fitStep.WktStepName,
+		Notes:     fitStep.Notes,
+		Intensity: fitIntensityToWorkoutIntensity(fitStep.Intensity),
+	}
+
+	switch fitStep.DurationType {
+	case typedef.WktStepDurationTime:
+		step.Duration = time.Duration(fitStep.DurationValue) * time.Millisecond
+
+	case typedef.WktStepDurationDistance:
+		step.Distance = Distance(fitStep.DurationValue / 100)
+
+	case typedef.WktStepDurationOpen:
+
+	default:
+		return WorkoutStep{}, fmt.Errorf("%w: duration type %s", ErrUnsupportedFITWorkout, fitStep.DurationType)
+	}
+
+	var target WorkoutTarget
+	var offset uint32
+	switch fitStep.TargetType {
+	case typedef.WktStepTargetOpen, typedef.WktStepTargetInvalid:
+		return step, nil
+
+	case typedef.WktStepTargetHeartRate:
+		target.Type = WorkoutTargetHeartRate
+		offset = 100
+
+	case typedef.WktStepTargetSpeed:
+		target.Type = WorkoutTargetSpeed
+
+	case typedef.WktStepTargetPower:
+		target.Type = WorkoutTargetPower
+		offset = 1000
+
+	case typedef.WktStepTargetCadence:
+		target.Type = WorkoutTargetCadence
+
+	default:
+		return WorkoutStep{}, fmt.Errorf("%w: target type %s", ErrUnsupportedFITWorkout, fitStep.TargetType)
+	}
+
+	if fitStep.TargetValue != 0 && fitStep.TargetValue != basetype.Uint32Invalid {
+		target.Zone = uint8(fitStep.TargetValue)
+	} else {
+		target.Low = fitStep.CustomTargetValueLow - min(offset, fitStep.CustomTargetValueLow)
+		target.High = fitStep.CustomTargetValueHigh - min(offset, fitStep.CustomTargetValueHigh)
+	}
+
+	step.Target = Optional[WorkoutTarget]{Value: target, Valid: true}
+
+	return step, nil
+}
+
+func workoutIntensityToFitIntensity(intensity WorkoutIntensity) typedef.Intensity {
+	switch intensity {
+	case WorkoutIntensityWarmup:
+		return typedef.IntensityWarmup
+
+	case WorkoutIntensityCooldown:
+		return typedef.IntensityCooldown
+
+	case WorkoutIntensityRecovery:
+		return typedef.IntensityRecovery
+
+	case WorkoutIntensityRest:
+		return typedef.IntensityRest
+
+	case WorkoutIntensityInterval:
+		return typedef.IntensityInterval
+
+	default:
+		return typedef.IntensityActive
+	}
+}
+
+func fitIntensityToWorkoutIntensity(intensity typedef.Intensity) WorkoutIntensity {
+	switch intensity {
+	case typedef.IntensityWarmup:
+		return WorkoutIntensityWarmup
+
+	case typedef.IntensityCooldown:
+		return WorkoutIntensityCooldown
+
+	case typedef.IntensityRecovery:
+		return WorkoutIntensityRecovery
+
+	case typedef.IntensityRest:
+		return WorkoutIntensityRest
+
+	case typedef.IntensityInterval:
+		return WorkoutIntensityInterval
+
+	default:
+		return WorkoutIntensityActive
+	}
+}
@@ -0,0 +1,111 @@
+package stride_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/muktihari/fit/decoder"
+	"github.com/muktihari/fit/profile/filedef"
+	"github.com/muktihari/fit/profile/typedef"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/gabrieleangeletti/stride"
+)
+
+func TestFITWorkoutRoundTrip(t *testing.T) {
+	workout := &Workout{
+		Name:        "Threshold intervals",
+		Description: "5x1km at threshold, then hill sprints",
+		Sport:       SportRunning,
+		Steps: []WorkoutStep{
+			{Name: "Warm up", Intensity: WorkoutIntensityWarmup, Duration: 10 * time.Minute, Target: HeartRateZoneTarget(2)},
+			{
+				Name:        "Main set",
+				Repetitions: 5,
+				Steps: []WorkoutStep{
+					{Intensity: WorkoutIntensityActive, Distance: 1000, Target: PaceTarget(Pace{Minutes: 4, Seconds: 10}, Pace{Minutes: 4})},
+					{Intensity: WorkoutIntensityRecovery, Duration: 2 * time.Minute, Target: HeartRateTarget(100, 140)},
+				},
+			},
+			{
+				Repetitions: 2,
+				Steps: []WorkoutStep{
+					{
+						Repetitions: 3,
+						Steps: []WorkoutStep{
+							{Intensity: WorkoutIntensityInterval, Duration: 30 * time.Second, Target: PowerTarget(300, 350), Notes: "Uphill"},
+							{Intensity: WorkoutIntensityRest, Duration: 30 * time.Second},
+						},
+					},
+					{Intensity: WorkoutIntensityRest, Duration: 3 * time.Minute, Target: PowerZoneTarget(1)},
+				},
+			},
+			{Name: "Cool down", Intensity: WorkoutIntensityCooldown},
+		},
+	}
+
+	created := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	data, err := CreateFITWorkoutFileInMemory(workout, FITExportConfig{TimeCreated: created})
+	require.NoError(t, err)
+
+	file := decodeFITWorkout(t, data)
+	assert.Equal(t, typedef.FileWorkout, file.FileId.Type)
+	assert.Equal(t, created, file.FileId.TimeCreated)
+	require.Len(t, file.WorkoutSteps, 10)
+	assert.Equal(t, uint16(10), file.Workout.NumValidSteps)
+
+	// Repeats follow the steps they repeat and point back to the first of them
+	mainSet := file.WorkoutSteps[3]
+	assert.Equal(t, typedef.WktStepDurationRepeatUntilStepsCmplt, mainSet.DurationType)
+	assert.Equal(t, uint32(1), mainSet.DurationValue)
+	assert.Equal(t, uint32(5), mainSet.TargetValue)
+	assert.Equal(t, uint32(1000*100), file.WorkoutSteps[1].DurationValue)
+	assert.Equal(t, uint32(240), file.WorkoutSteps[2].CustomTargetValueHigh)
+	assert.Equal(t, uint32(4), file.WorkoutSteps[6].DurationValue, "inner repeat")
+	assert.Equal(t, uint32(4), file.WorkoutSteps[8].DurationValue, "outer repeat")
+
+	parsed, err := ParseFITWorkoutFile(data)
+	require.NoError(t, err)
+	assert.Equal(t, workout, parsed)
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := CreateFITWorkoutFileInMemory(&Workout{Sport: SportRunning}, FITExportConfig{})
+		assert.ErrorIs(t, err, ErrInvalidWorkout)
+
+		_, err = CreateFITWorkoutFileInMemory(&Workout{Sport: SportRunning, Steps: []WorkoutStep{
+			{Duration: time.Minute, Distance: 200},
+		}}, FITExportConfig{})
+		assert.ErrorIs(t, err, ErrInvalidWorkout)
+
+		_, err = CreateFITWorkoutFileInMemory(&Workout{Sport: SportRunning, Steps: []WorkoutStep{
+			{Duration: time.Minute, Target: HeartRateTarget(160, 150)},
+		}}, FITExportConfig{})
+		assert.ErrorIs(t, err, ErrInvalidWorkout)
+
+		_, err = CreateFITWorkoutFileInMemory(&Workout{Sport: SportRunning, Steps: []WorkoutStep{
+			{Repetitions: 3},
+		}}, FITExportConfig{})
+		assert.ErrorIs(t, err, ErrInvalidWorkout)
+
+		start := time.Date(2025, 6, 1, 7, 0, 0, 0, time.UTC)
+		activity, err := CreateFITFileInMemory(&Activity{Sport: SportRunning, StartTime: start, ElapsedTime: 60}, steadyRun(start, 60), SportRunning)
+		require.NoError(t, err)
+
+		_, err = ParseFITWorkoutFile(activity)
+		assert.ErrorIs(t, err, ErrNotFITWorkout)
+
+		_, err = ParseFITWorkoutFile([]byte("garbage"))
+		assert.ErrorIs(t, err, ErrFailedToParseFITFile)
+	})
+}
+
+func decodeFITWorkout(t *testing.T, data []byte) *filedef.Workout {
+	t.Helper()
+
+	fit, err := decoder.New(bytes.NewReader(data)).Decode()
+	require.NoError(t, err)
+
+	return filedef.NewWorkout(fit.Messages...)
+}